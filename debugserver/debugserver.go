@@ -0,0 +1,254 @@
+// Package debugserver 启动内部诊断用的 http 服务, 各服务以相同的路径提供 pprof、expvar、日志等级和版本信息
+// 只允许本机、AllowIPs 中的来源 IP 或带有正确 Token 的请求访问
+//
+//	srv, err := debugserver.Start(&debugserver.Config{Addr: ":6060", Token: os.Getenv("DEBUG_TOKEN")})
+//	if err != nil {
+//		log.Logger().Errorw("start debug server", "err", err)
+//	}
+//	defer srv.Close()
+//
+//	go tool pprof http://127.0.0.1:6060/debug/pprof/heap
+//	curl -H "Authorization: Bearer $DEBUG_TOKEN" http://10.0.0.8:6060/buildinfo
+//	curl -X PUT -d '{"level":"debug"}' http://127.0.0.1:6060/log/level
+package debugserver
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"net/netip"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	log "basic-middle/logger"
+)
+
+// DefaultAddr 未配置 Addr 时的监听地址
+const DefaultAddr = "127.0.0.1:6060"
+
+// Config 诊断服务的配置
+type Config struct {
+	Addr     string   `json:"addr"`      //监听地址, 默认 127.0.0.1:6060
+	AllowIPs []string `json:"allow_ips"` //除本机外允许访问的来源 IP 或 CIDR, 如 10.0.0.0/8, 为空时只允许本机, 0.0.0.0/0 和 ::/0 允许所有
+	Token    string   `json:"token"`     //访问令牌, 来源 IP 不在 AllowIPs 中时请求需带有 Authorization: Bearer <token>, 不支持 URL 参数以免令牌出现在访问日志中
+}
+
+var startTime = time.Now()
+
+// Server 已启动的诊断服务
+type Server struct {
+	srv *http.Server
+	ln  net.Listener
+	mux *http.ServeMux
+}
+
+// Start 在 conf.Addr 上监听并在后台提供诊断服务, 监听失败或 AllowIPs 格式错误时返回错误
+func Start(conf *Config) (*Server, error) {
+	if conf == nil {
+		conf = &Config{}
+	}
+	g, err := newGuard(conf)
+	if err != nil {
+		return nil, err
+	}
+	addr := conf.Addr
+	if addr == "" {
+		addr = DefaultAddr
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("debugserver: %w", err)
+	}
+
+	mux := newMux()
+	s := &Server{
+		srv: &http.Server{Handler: g.wrap(mux), ReadHeaderTimeout: 10 * time.Second},
+		ln:  ln,
+		mux: mux,
+	}
+	if l := logger(); l != nil {
+		l.Infow("debug server started", "addr", ln.Addr().String())
+	}
+	go func() {
+		if err := s.srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			if l := logger(); l != nil {
+				l.Errorw("debug server stopped", "addr", ln.Addr().String(), "err", err)
+			}
+		}
+	}()
+	return s, nil
+}
+
+// Addr 实际监听的地址, Addr 配置为 :0 时用于获取分配的端口
+func (s *Server) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// Handle 注册额外的诊断接口, 与内置接口一样受 AllowIPs 和 Token 限制
+func (s *Server) Handle(pattern string, h http.Handler) {
+	s.mux.Handle(pattern, h)
+}
+
+// Close 关闭监听和所有连接, 正在进行的 profile 采集会被中断
+func (s *Server) Close() error {
+	return s.srv.Close()
+}
+
+// Handler 返回提供诊断接口并按 conf 限制访问的 http.Handler, 用于挂载到已有的 http 服务上
+// 服务在本机的反向代理之后时来源均为本机, 不要挂载到对外的服务上
+func Handler(conf *Config) (http.Handler, error) {
+	if conf == nil {
+		conf = &Config{}
+	}
+	g, err := newGuard(conf)
+	if err != nil {
+		return nil, err
+	}
+	return g.wrap(newMux()), nil
+}
+
+// newMux 内置的诊断接口
+//
+//	/debug/pprof/  pprof 的各项 profile
+//	/debug/vars    expvar 发布的变量
+//	/log/level     查看和修改全局日志等级, 同 log.LevelHandler
+//	/buildinfo     版本、Go 版本、启动时间等
+func newMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	// 请求时再取, 允许在 log.Init 之前启动, 初始化之前返回 503
+	mux.HandleFunc("/log/level", func(w http.ResponseWriter, r *http.Request) {
+		if !log.Initialized() {
+			http.Error(w, "logger not initialized", http.StatusServiceUnavailable)
+			return
+		}
+		log.LevelHandler().ServeHTTP(w, r)
+	})
+	mux.HandleFunc("/buildinfo", serveBuildInfo)
+	return mux
+}
+
+type buildInfo struct {
+	Version   string `json:"version,omitempty"`
+	Commit    string `json:"commit,omitempty"`
+	BuildTime string `json:"build_time,omitempty"`
+	GoVersion string `json:"go_version"`
+	Path      string `json:"path,omitempty"`
+	Hostname  string `json:"hostname,omitempty"`
+	PID       int    `json:"pid"`
+	StartTime string `json:"start_time"`
+	Uptime    string `json:"uptime"`
+}
+
+func serveBuildInfo(w http.ResponseWriter, r *http.Request) {
+	info := buildInfo{
+		GoVersion: runtime.Version(),
+		PID:       os.Getpid(),
+		StartTime: startTime.Format(time.RFC3339),
+		Uptime:    time.Since(startTime).Truncate(time.Second).String(),
+	}
+	info.Version, info.Commit, info.BuildTime = log.BuildInfo()
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		info.Path = bi.Path
+	}
+	info.Hostname, _ = os.Hostname()
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(info)
+}
+
+// logger log.Init 之前返回 nil, 此时不记录日志
+func logger() *zap.SugaredLogger {
+	if !log.Initialized() {
+		return nil
+	}
+	return log.Named("debugserver")
+}
+
+// guard 按来源 IP 和令牌限制访问
+type guard struct {
+	prefixes []netip.Prefix
+	token    string
+}
+
+func newGuard(conf *Config) (*guard, error) {
+	g := &guard{token: conf.Token}
+	for _, s := range conf.AllowIPs {
+		s = strings.TrimSpace(s)
+		if strings.Contains(s, "/") {
+			p, err := netip.ParsePrefix(s)
+			if err != nil {
+				return nil, fmt.Errorf("debugserver: invalid allow_ips %q: %w", s, err)
+			}
+			g.prefixes = append(g.prefixes, p.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return nil, fmt.Errorf("debugserver: invalid allow_ips %q: %w", s, err)
+		}
+		addr = addr.Unmap()
+		g.prefixes = append(g.prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return g, nil
+}
+
+func (g *guard) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !g.allowIP(r.RemoteAddr) && !g.allowToken(r) {
+			if l := logger(); l != nil {
+				l.Warnw("debug request denied", "remote_addr", r.RemoteAddr, "path", r.URL.Path)
+			}
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowIP 本机始终允许, 如 kubectl port-forward; 只使用连接的来源地址, 不信任 X-Forwarded-For
+func (g *guard) allowIP(remoteAddr string) bool {
+	ap, err := netip.ParseAddrPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+	addr := ap.Addr().Unmap()
+	if addr.IsLoopback() {
+		return true
+	}
+	for _, p := range g.prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *guard) allowToken(r *http.Request) bool {
+	if g.token == "" {
+		return false
+	}
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+	got := strings.TrimPrefix(auth, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(got), []byte(g.token)) == 1
+}
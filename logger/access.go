@@ -0,0 +1,183 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"basic-middle/logger/requestid"
+)
+
+// AccessConfig HTTP 访问日志中间件的配置, HTTPMiddleware 以及 logger/middleware 下的 gin、echo 中间件共用
+type AccessConfig struct {
+	SkipPaths       []string `json:"skip_paths"`        //不记录访问日志的路径, 如 /health, 以 * 结尾时按前缀匹配
+	SlowThresholdMs int      `json:"slow_threshold_ms"` //耗时超过该值的请求以 warn 等级记录, 为 0 时不区分
+	CaptureBody     bool     `json:"capture_body"`      //访问日志附带请求体, 用于排查问题, 注意请求体中可能有敏感信息
+	MaxBodyBytes    int      `json:"max_body_bytes"`    //附带的请求体最大字节数, 默认 4096
+	TrustedProxies  []string `json:"trusted_proxies"`   //可信的反向代理 IP 或 CIDR, 如 10.0.0.0/8, 只有连接来自这些地址时才采信 X-Forwarded-For、X-Real-Ip, 为空时 client_ip 为连接的对端地址
+}
+
+// AccessEntry 一次请求的访问日志, client_ip、method、route 已由 RequestContext 加在 logger 上
+type AccessEntry struct {
+	Method    string
+	Path      string
+	Query     string
+	UserAgent string
+	Status    int
+	Latency   time.Duration
+	Bytes     int // 响应体字节数
+	Body      string
+	Err       error
+}
+
+// Skip path 是否不记录访问日志
+func (c *AccessConfig) Skip(path string) bool {
+	for _, p := range c.SkipPaths {
+		if p == path || (strings.HasSuffix(p, "*") && strings.HasPrefix(path, strings.TrimSuffix(p, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadBody CaptureBody 开启时读取最多 MaxBodyBytes 字节的请求体, 读取的部分放回 r.Body 供处理函数使用
+func (c *AccessConfig) ReadBody(r *http.Request) string {
+	if !c.CaptureBody || r.Body == nil || r.Body == http.NoBody {
+		return ""
+	}
+	max := c.MaxBodyBytes
+	if max <= 0 {
+		max = 4096
+	}
+	buf, _ := io.ReadAll(io.LimitReader(r.Body, int64(max)))
+	r.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(buf), r.Body), Closer: r.Body}
+	return string(buf)
+}
+
+// ClientIP 请求的客户端 IP, 默认为连接的对端地址
+// 对端地址在 TrustedProxies 中时, 从右向左取 X-Forwarded-For 中第一个不可信的地址, 没有 X-Forwarded-For 时取 X-Real-Ip
+// 客户端可以任意设置这两个请求头, 只有经过可信代理追加的部分才可靠, 格式错误的 TrustedProxies 项被忽略
+func (c *AccessConfig) ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if len(c.TrustedProxies) == 0 {
+		return host
+	}
+	trusted := parsePrefixes(c.TrustedProxies)
+	if !containsAddr(trusted, host) {
+		return host
+	}
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	xff := false
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		addr, err := netip.ParseAddr(hop)
+		if err != nil {
+			// 无法解析的一跳之前的内容都不可信
+			return host
+		}
+		if !containsAddr(trusted, addr.String()) {
+			return addr.Unmap().String()
+		}
+		// 全部为可信代理时取最左侧的一跳
+		host = addr.Unmap().String()
+		xff = true
+	}
+	if !xff {
+		if addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-Ip"))); err == nil {
+			return addr.Unmap().String()
+		}
+	}
+	return host
+}
+
+// parsePrefixes 解析 IP 或 CIDR 列表, 单个 IP 视为完整长度的前缀
+func parsePrefixes(list []string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(list))
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if strings.Contains(s, "/") {
+			if p, err := netip.ParsePrefix(s); err == nil {
+				prefixes = append(prefixes, p.Masked())
+			}
+			continue
+		}
+		if addr, err := netip.ParseAddr(s); err == nil {
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+		}
+	}
+	return prefixes
+}
+
+func containsAddr(prefixes []netip.Prefix, ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// RequestContext 返回请求范围的 context: 携带请求 ID(取 X-Request-Id, 没有时生成)、开始时间, logger 带有 client_ip、method 和 route 字段
+// route 为匹配的路由模板, 如 /users/:id, 为空时使用请求的路径
+func RequestContext(r *http.Request, route, clientIP string) context.Context {
+	ctx := WithStart(requestid.FromHTTP(r))
+	if route == "" {
+		route = r.URL.Path
+	}
+	return WithFieldsCtx(ctx, "client_ip", clientIP, "method", r.Method, "route", route)
+}
+
+// Log 记录访问日志, 5xx 或处理出错但状态码不是 4xx 时为 error 等级, 超过 SlowThresholdMs 时为 warn 等级
+func (c *AccessConfig) Log(ctx context.Context, e *AccessEntry) {
+	fields := []interface{}{
+		zap.String("path", e.Path),
+		zap.Int("status", e.Status),
+		zap.Duration("latency", e.Latency),
+		zap.Int("bytes", e.Bytes),
+		zap.String("user_agent", e.UserAgent),
+	}
+	if e.Query != "" {
+		fields = append(fields, zap.String("query", e.Query))
+	}
+	if e.Body != "" {
+		fields = append(fields, zap.String("body", e.Body))
+	}
+	if e.Err != nil {
+		fields = append(fields, zap.Error(e.Err))
+	}
+
+	l := FromContext(ctx).Named("access")
+	slow := c.SlowThresholdMs > 0 && e.Latency >= time.Duration(c.SlowThresholdMs)*time.Millisecond
+	switch {
+	case e.Status >= http.StatusInternalServerError || (e.Err != nil && e.Status < http.StatusBadRequest):
+		l.Errorw(e.Method+" "+e.Path, fields...)
+	case slow:
+		l.Warnw(e.Method+" "+e.Path, append(fields, zap.Bool("slow", true))...)
+	default:
+		l.Infow(e.Method+" "+e.Path, fields...)
+	}
+}
@@ -0,0 +1,67 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// ArchiveConfig 轮转后的日志文件归档配置, 只支持按时间轮转
+// 具体的归档实现在 logger/archive 下的子包中, 通过空导入注册:
+//
+//	import _ "basic-middle/logger/archive/s3"
+type ArchiveConfig struct {
+	Type        string          `json:"type"`         //归档类型, 如 s3
+	Gzip        bool            `json:"gzip"`         //上传前 gzip 压缩, 与 Compress 效果相同
+	DeleteLocal bool            `json:"delete_local"` //上传成功后删除本地文件, 否则按 MaxAgeDays 或 MaxRotatedFiles 保留
+	Options     json.RawMessage `json:"options"`      //归档自身的配置, 由各实现解析
+}
+
+// Decode 将 Options 解析到 v, 未配置 Options 时保持 v 的默认值
+func (ac ArchiveConfig) Decode(v interface{}) error {
+	if len(ac.Options) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(ac.Options, v); err != nil {
+		return fmt.Errorf("invalid %s archive options: %w", ac.Type, err)
+	}
+	return nil
+}
+
+// Archiver 上传一个已经轮转完成的本地文件, 在 rotatelogs 的回调 goroutine 中调用
+type Archiver interface {
+	Archive(path string) error
+}
+
+// ArchiverFactory 根据 logger 的配置和归档配置创建 Archiver
+type ArchiverFactory func(conf *LoggerConfig, ac ArchiveConfig) (Archiver, error)
+
+var (
+	archiverFactoriesMu sync.RWMutex
+	archiverFactories   = make(map[string]ArchiverFactory)
+)
+
+// RegisterArchiver 注册归档类型, 一般在归档包的 init 中调用, 同名注册会覆盖
+func RegisterArchiver(typ string, factory ArchiverFactory) {
+	archiverFactoriesMu.Lock()
+	archiverFactories[typ] = factory
+	archiverFactoriesMu.Unlock()
+}
+
+// newArchiver 按 conf.Archive 创建 Archiver, 未配置时返回 nil
+func newArchiver(conf *LoggerConfig) (Archiver, error) {
+	if conf.Archive == nil {
+		return nil, nil
+	}
+	archiverFactoriesMu.RLock()
+	factory, ok := archiverFactories[conf.Archive.Type]
+	archiverFactoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown log archive type: %q, forgot to import basic-middle/logger/archive/%s?", conf.Archive.Type, conf.Archive.Type)
+	}
+	a, err := factory(conf, *conf.Archive)
+	if err != nil {
+		return nil, fmt.Errorf("create %s archiver: %w", conf.Archive.Type, err)
+	}
+	return a, nil
+}
@@ -0,0 +1,102 @@
+// Package s3 将轮转后的日志文件上传到 S3 兼容的对象存储, 如 AWS S3、阿里云 OSS、MinIO
+// 空导入后在 LoggerConfig.Archive 中配置 type 为 s3, 只支持按时间轮转
+//
+//	archive:
+//	  type: s3
+//	  gzip: true
+//	  delete_local: true
+//	  options: {endpoint: oss-cn-hangzhou.aliyuncs.com, bucket: logs, access_key: xxx, secret_key: xxx}
+package s3
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+
+	log "basic-middle/logger"
+)
+
+func init() {
+	log.RegisterArchiver("s3", New)
+}
+
+// Options s3 归档的配置
+type Options struct {
+	Endpoint     string `json:"endpoint"`      //地址, 不带协议, 默认 s3.amazonaws.com
+	Bucket       string `json:"bucket"`        //存储桶, 必填
+	Prefix       string `json:"prefix"`        //对象名前缀, 默认 <namespace>/<project>/<hostname>/
+	Region       string `json:"region"`        //区域, 为空时由服务端查询
+	AccessKey    string `json:"access_key"`    //为空时从环境变量 AWS_ACCESS_KEY_ID 等获取
+	SecretKey    string `json:"secret_key"`    //为空时从环境变量 AWS_SECRET_ACCESS_KEY 等获取
+	Insecure     bool   `json:"insecure"`      //使用 http 而不是 https, 如内网 MinIO
+	StorageClass string `json:"storage_class"` //存储类型, 如 STANDARD_IA
+	TimeoutMs    int    `json:"timeout_ms"`    //单个文件的上传超时, 默认 10 分钟
+}
+
+// New 按 ac.Options 创建 s3 归档
+func New(conf *log.LoggerConfig, ac log.ArchiveConfig) (log.Archiver, error) {
+	var opts Options
+	if err := ac.Decode(&opts); err != nil {
+		return nil, err
+	}
+	if opts.Bucket == "" {
+		return nil, fmt.Errorf("s3 archive bucket is required")
+	}
+	if opts.Endpoint == "" {
+		opts.Endpoint = "s3.amazonaws.com"
+	}
+	if opts.Prefix == "" {
+		host, _ := os.Hostname()
+		opts.Prefix = strings.Join([]string{conf.Namespace, conf.Project, host}, "/") + "/"
+	}
+	if opts.TimeoutMs <= 0 {
+		opts.TimeoutMs = 600000
+	}
+
+	creds := credentials.NewChainCredentials([]credentials.Provider{
+		&credentials.EnvAWS{},
+		&credentials.EnvMinio{},
+	})
+	if opts.AccessKey != "" {
+		creds = credentials.NewStaticV4(opts.AccessKey, opts.SecretKey, "")
+	}
+	client, err := minio.New(opts.Endpoint, &minio.Options{
+		Creds:  creds,
+		Secure: !opts.Insecure,
+		Region: opts.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &archiver{client: client, opts: opts}, nil
+}
+
+type archiver struct {
+	client *minio.Client
+	opts   Options
+}
+
+// Archive 以 Prefix + 文件名作为对象名上传, 同名对象会被覆盖
+func (a *archiver) Archive(path string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(a.opts.TimeoutMs)*time.Millisecond)
+	defer cancel()
+
+	put := minio.PutObjectOptions{
+		ContentType:  "text/plain; charset=utf-8",
+		StorageClass: a.opts.StorageClass,
+	}
+	if strings.HasSuffix(path, ".gz") {
+		put.ContentType = "application/gzip"
+	}
+	key := a.opts.Prefix + filepath.Base(path)
+	if _, err := a.client.FPutObject(ctx, a.opts.Bucket, key, path, put); err != nil {
+		return fmt.Errorf("upload %s to s3://%s/%s: %w", path, a.opts.Bucket, key, err)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package log
+
+import (
+	"bufio"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// AsyncConfig 异步写入配置, 日志先进入内存队列, 由后台 goroutine 批量写入输出
+type AsyncConfig struct {
+	QueueSize       int  `json:"queue_size"`        //队列长度(条), 默认 4096
+	BufferSize      int  `json:"buffer_size"`       //写缓冲区字节数, 默认 256KB
+	FlushIntervalMs int  `json:"flush_interval_ms"` //定时刷新间隔毫秒, 默认 1000
+	DropWhenFull    bool `json:"drop_when_full"`    //队列满时丢弃而不是阻塞调用方, 丢弃条数见 AsyncDropped
+}
+
+var asyncDropped uint64
+
+// AsyncDropped 异步模式下因队列已满被丢弃的日志条数
+func AsyncDropped() uint64 {
+	return atomic.LoadUint64(&asyncDropped)
+}
+
+type asyncMsg struct {
+	data []byte
+	done chan error // 不为空时表示 Sync 请求
+}
+
+// asyncWriter 异步写入的 WriteSyncer, Sync 会等待队列中已有的日志全部写出
+// panic/fatal 等级的日志由 zap 在写入后调用 Sync, 不会丢失
+type asyncWriter struct {
+	ws       zapcore.WriteSyncer
+	buf      *bufio.Writer
+	queue    chan asyncMsg
+	drop     bool
+	interval time.Duration
+
+	mu     sync.RWMutex
+	closed bool
+	exited chan struct{}
+}
+
+func newAsyncWriter(ws zapcore.WriteSyncer, conf *AsyncConfig) *asyncWriter {
+	queueSize := conf.QueueSize
+	if queueSize <= 0 {
+		queueSize = 4096
+	}
+	bufferSize := conf.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = 256 * 1024
+	}
+	interval := time.Duration(conf.FlushIntervalMs) * time.Millisecond
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	w := &asyncWriter{
+		ws:       ws,
+		buf:      bufio.NewWriterSize(ws, bufferSize),
+		queue:    make(chan asyncMsg, queueSize),
+		drop:     conf.DropWhenFull,
+		interval: interval,
+		exited:   make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+func (w *asyncWriter) run() {
+	defer close(w.exited)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case msg, ok := <-w.queue:
+			if !ok {
+				w.buf.Flush()
+				return
+			}
+			if msg.done != nil {
+				err := w.buf.Flush()
+				if serr := w.ws.Sync(); err == nil {
+					err = serr
+				}
+				msg.done <- err
+				continue
+			}
+			w.buf.Write(msg.data)
+		case <-ticker.C:
+			w.buf.Flush()
+		}
+	}
+}
+
+func (w *asyncWriter) Write(p []byte) (int, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return w.ws.Write(p)
+	}
+
+	// zap 写入后会复用 p, 需要复制一份
+	msg := asyncMsg{data: append([]byte(nil), p...)}
+	if !w.drop {
+		w.queue <- msg
+		return len(p), nil
+	}
+	select {
+	case w.queue <- msg:
+	default:
+		atomic.AddUint64(&asyncDropped, 1)
+	}
+	return len(p), nil
+}
+
+func (w *asyncWriter) Sync() error {
+	w.mu.RLock()
+	if w.closed {
+		w.mu.RUnlock()
+		return w.ws.Sync()
+	}
+	done := make(chan error, 1)
+	w.queue <- asyncMsg{done: done}
+	w.mu.RUnlock()
+	return <-done
+}
+
+// Close 写出队列中剩余的日志并停止后台 goroutine, 之后的写入直接同步写到输出
+func (w *asyncWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.queue)
+	w.mu.Unlock()
+
+	<-w.exited
+	return w.ws.Sync()
+}
@@ -0,0 +1,102 @@
+package log
+
+import (
+	"errors"
+
+	"go.uber.org/zap"
+)
+
+// auditName 审计 logger 在注册表中的名称, 随全局 logger 一起 Sync 和 Close
+const auditName = "audit"
+
+// ErrAuditNotConfigured 未配置 LoggerConfig.Audit 或审计 logger 创建失败时 Record 返回的错误
+// 全局 logger 受等级、采样和合并影响, 不能保证审计日志不丢, 因此不写入全局 logger
+var ErrAuditNotConfigured = errors.New("log: audit logger not configured")
+
+// AuditEvent 一条审计日志的必填字段
+type AuditEvent struct {
+	Actor    string // 操作人, 如用户 id 或服务名
+	Action   string // 操作, 如 delete_user
+	Resource string // 操作对象, 如 user:1001
+	Result   string // 结果, 如 success、denied、failed
+}
+
+// AuditLogger 审计日志, 只能通过 Record 写入, 保证必填字段齐全
+type AuditLogger struct {
+	l *zap.SugaredLogger // 未配置审计 logger 时为 nil
+}
+
+// Audit 获取审计 logger, 写入 LoggerConfig.Audit 配置的独立文件
+// 审计日志不受日志等级、模块等级、采样和合并的影响
+// 未配置 Audit 时 Record 返回 ErrAuditNotConfigured
+func Audit() *AuditLogger {
+	registryMu.RLock()
+	ins, ok := registry[auditName]
+	registryMu.RUnlock()
+	if ok {
+		return &AuditLogger{l: ins.sugar}
+	}
+	return &AuditLogger{}
+}
+
+// Record 写入一条审计日志, kv 为附加字段; 必填字段为空时返回错误且不写入, 未配置审计 logger 时返回 ErrAuditNotConfigured
+//
+//	log.Audit().Record(log.AuditEvent{Actor: uid, Action: "delete_user", Resource: "user:1001", Result: "success"}, "ip", ip)
+func (a *AuditLogger) Record(e AuditEvent, kv ...interface{}) error {
+	if e.Actor == "" || e.Action == "" || e.Resource == "" || e.Result == "" {
+		return errors.New("log: audit event requires actor, action, resource and result")
+	}
+	if a.l == nil {
+		return ErrAuditNotConfigured
+	}
+
+	fields := append([]interface{}{
+		"actor", e.Actor,
+		"action", e.Action,
+		"resource", e.Resource,
+		"result", e.Result,
+	}, kv...)
+	a.l.Desugar().WithOptions(zap.AddCallerSkip(1)).Sugar().Infow("audit", fields...)
+	return nil
+}
+
+// auditConfig 审计 logger 的配置, 未设置的 namespace、project、输出目录沿用全局配置
+// 审计日志不做任何等级过滤和采样
+func auditConfig(conf *LoggerConfig) *LoggerConfig {
+	c := *conf.Audit
+	if c.Namespace == "" {
+		c.Namespace = conf.Namespace
+	}
+	if c.Project == "" {
+		c.Project = conf.Project
+	}
+	if c.OutPutDir == "" {
+		c.OutPutDir = conf.OutPutDir
+	}
+	c.Level = "debug"
+	c.Levels = nil
+	c.Sampling = nil
+	c.Dedup = nil
+	c.Audit = nil
+	return &c
+}
+
+// initAudit 按全局配置中的 Audit 创建审计 logger
+func initAudit(conf *LoggerConfig) error {
+	if conf.Audit == nil {
+		return nil
+	}
+	_, err := New(auditName, auditConfig(conf))
+	return err
+}
+
+// reloadAudit Reload 时重新打开审计日志文件, 审计日志的等级固定为 debug, 全局 level 为 off 时不关闭
+func reloadAudit(conf *LoggerConfig) error {
+	registryMu.RLock()
+	ins, ok := registry[auditName]
+	registryMu.RUnlock()
+	if !ok || conf.Audit == nil {
+		return nil
+	}
+	return ins.reload(auditConfig(conf))
+}
@@ -0,0 +1,86 @@
+package log
+
+import (
+	"runtime/debug"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+var (
+	buildMu    sync.RWMutex
+	buildSet   bool
+	buildValue buildInfo
+)
+
+type buildInfo struct {
+	version   string
+	commit    string
+	buildTime string
+}
+
+// SetBuildInfo 设置版本信息, 之后创建的 logger 每条日志附加 version、commit、build_time 字段
+// 需在 Init 之前调用, 一般通过 -ldflags "-X main.version=..." 注入后传入
+//
+//	log.SetBuildInfo(version, commit, buildTime)
+func SetBuildInfo(version, commit, buildTime string) {
+	buildMu.Lock()
+	buildSet = true
+	buildValue = buildInfo{version: version, commit: commit, buildTime: buildTime}
+	buildMu.Unlock()
+}
+
+// buildFields 版本字段, 优先使用 SetBuildInfo 设置的值
+// 未调用 SetBuildInfo 且配置了 BuildInfo 时从 debug.ReadBuildInfo 读取模块版本和 vcs 信息
+func buildFields(conf *LoggerConfig) []zap.Field {
+	buildMu.RLock()
+	info, ok := buildValue, buildSet
+	buildMu.RUnlock()
+	if !ok {
+		if !conf.BuildInfo {
+			return nil
+		}
+		info = readBuildInfo()
+	}
+
+	var fields []zap.Field
+	if info.version != "" {
+		fields = append(fields, zap.String("version", info.version))
+	}
+	if info.commit != "" {
+		fields = append(fields, zap.String("commit", info.commit))
+	}
+	if info.buildTime != "" {
+		fields = append(fields, zap.String("build_time", info.buildTime))
+	}
+	return fields
+}
+
+func readBuildInfo() buildInfo {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return buildInfo{}
+	}
+
+	info := buildInfo{version: bi.Main.Version}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.commit = s.Value
+		case "vcs.time":
+			info.buildTime = s.Value
+		}
+	}
+	return info
+}
+
+// BuildInfo 返回日志中使用的版本信息, 优先使用 SetBuildInfo 设置的值, 未设置时从 debug.ReadBuildInfo 读取
+func BuildInfo() (version, commit, buildTime string) {
+	buildMu.RLock()
+	info, ok := buildValue, buildSet
+	buildMu.RUnlock()
+	if !ok {
+		info = readBuildInfo()
+	}
+	return info.version, info.commit, info.buildTime
+}
@@ -0,0 +1,38 @@
+package log
+
+import (
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// CallerOutside 返回调用栈中第一个业务代码的位置, 如 /app/dao/user.go:42
+// 跳过调用 CallerOutside 的函数所在的包目录以及路径包含 skipPaths 任一项的调用, 用于第三方库的日志适配包
+//
+//	log.FromContext(ctx).WithOptions(zap.WithCaller(false)).With("source", log.CallerOutside("gorm.io/"))
+func CallerOutside(skipPaths ...string) string {
+	pcs := make([]uintptr, 32)
+	// 跳过 runtime.Callers 和 CallerOutside
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	frame, more := frames.Next()
+	dir := filepath.Dir(frame.File) + "/"
+	for more {
+		frame, more = frames.Next()
+		if frame.File == "" || strings.HasPrefix(frame.File, dir) || containsAny(frame.File, skipPaths) {
+			continue
+		}
+		return frame.File + ":" + strconv.Itoa(frame.Line)
+	}
+	return ""
+}
+
+func containsAny(s string, subs []string) bool {
+	for _, sub := range subs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package log
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	rotatelogs "github.com/lestrrat-go/file-rotatelogs"
+)
+
+var strftimeVerb = regexp.MustCompile(`(%[%+A-Za-z])+`)
+
+// rotateHandler rotatelogs 轮转后处理上一个文件: 按配置压缩为 .gz、上传归档、删除本地文件
+// rotatelogs 自带的清理匹配不到 .gz 文件, 压缩后按相同的保留策略清理压缩文件
+type rotateHandler struct {
+	compress    bool
+	archiver    Archiver
+	deleteLocal bool
+	glob        string
+	maxAge      time.Duration
+	maxCount    int
+}
+
+func newRotateHandler(pattern string, conf *LoggerConfig, archiver Archiver) *rotateHandler {
+	h := &rotateHandler{
+		compress: conf.Compress,
+		archiver: archiver,
+		glob:     strftimeVerb.ReplaceAllString(pattern, "*") + "*.gz",
+		maxAge:   maxAge(conf),
+		maxCount: conf.MaxRotatedFiles,
+	}
+	if conf.Archive != nil {
+		h.compress = h.compress || conf.Archive.Gzip
+		h.deleteLocal = conf.Archive.DeleteLocal
+	}
+	return h
+}
+
+// Handle rotatelogs 已经在单独的 goroutine 中回调, 这里直接同步处理
+func (h *rotateHandler) Handle(e rotatelogs.Event) {
+	ev, ok := e.(*rotatelogs.FileRotatedEvent)
+	if !ok || ev.PreviousFile() == "" {
+		return
+	}
+
+	path := ev.PreviousFile()
+	if h.compress {
+		if err := gzipFile(path); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to compress log file %s: %v\n", path, err)
+			return
+		}
+		path += ".gz"
+		defer h.cleanup()
+	}
+
+	if h.archiver == nil {
+		return
+	}
+	if err := h.archive(path); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to archive log file %s: %v\n", path, err)
+		return
+	}
+	if h.deleteLocal {
+		os.Remove(path)
+	}
+}
+
+// archive 上传失败时间隔递增重试, 最多 3 次
+func (h *rotateHandler) archive(path string) error {
+	var err error
+	for i := 0; i < 3; i++ {
+		if i > 0 {
+			time.Sleep(time.Duration(i) * 5 * time.Second)
+		}
+		if err = h.archiver.Archive(path); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func (h *rotateHandler) cleanup() {
+	matches, err := filepath.Glob(h.glob)
+	if err != nil {
+		return
+	}
+
+	type file struct {
+		path    string
+		modTime time.Time
+	}
+	files := make([]file, 0, len(matches))
+	for _, path := range matches {
+		if fi, err := os.Stat(path); err == nil {
+			files = append(files, file{path: path, modTime: fi.ModTime()})
+		}
+	}
+	// 新文件在前
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.After(files[j].modTime) })
+
+	cutoff := time.Now().Add(-h.maxAge)
+	for i, f := range files {
+		if h.maxCount > 0 && i < h.maxCount {
+			continue
+		}
+		if h.maxCount <= 0 && f.modTime.After(cutoff) {
+			continue
+		}
+		os.Remove(f.path)
+	}
+}
+
+// gzipFile 压缩 path 为 path.gz, 保留原文件的修改时间以便按时长清理
+func gzipFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	fi, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".gz.tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fi.Mode())
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Chtimes(tmp, fi.ModTime(), fi.ModTime()); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path+".gz"); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Remove(path)
+}
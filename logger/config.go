@@ -0,0 +1,52 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// InitFromFile 读取配置文件并初始化全局 logger, 行为与 Init 一致
+func InitFromFile(path string) error {
+	return initStd(func() (*LoggerConfig, error) { return LoadConfig(path) })
+}
+
+// LoadConfig 按扩展名解析 .json、.yaml/.yml、.toml 配置文件
+// 各格式的字段名统一使用 LoggerConfig 的 json tag, 如 out_put_dir、max_age_days
+func LoadConfig(path string) (*LoggerConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// yaml/toml 先解析为通用结构再转成 json, 避免为每个字段重复声明 yaml/toml tag
+	var raw map[string]interface{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("unsupported log config file: %s", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse log config %s: %w", path, err)
+	}
+	if raw != nil {
+		if data, err = json.Marshal(raw); err != nil {
+			return nil, fmt.Errorf("parse log config %s: %w", path, err)
+		}
+	}
+
+	conf := &LoggerConfig{}
+	if err := json.Unmarshal(data, conf); err != nil {
+		return nil, fmt.Errorf("parse log config %s: %w", path, err)
+	}
+	return conf, nil
+}
@@ -0,0 +1,47 @@
+package log
+
+import (
+	"io"
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// CoreFactory 根据 logger 的配置创建额外的 core, 返回 nil 表示不启用
+type CoreFactory func(conf *LoggerConfig) zapcore.Core
+
+var (
+	coreFactoriesMu sync.RWMutex
+	coreFactories   []CoreFactory
+)
+
+// RegisterCore 注册额外的 core, 之后创建的每个 logger 都会把它加入输出
+// 额外的 core 同样受全局等级、模块等级、采样和脱敏的控制
+// core 实现了 io.Closer 时会在 Close 中关闭, 需在 Init 之前注册
+func RegisterCore(factory CoreFactory) {
+	coreFactoriesMu.Lock()
+	coreFactories = append(coreFactories, factory)
+	coreFactoriesMu.Unlock()
+}
+
+// registeredCores 调用已注册的 CoreFactory, 返回创建的 core 及需要关闭的部分
+func registeredCores(conf *LoggerConfig) ([]zapcore.Core, []io.Closer) {
+	coreFactoriesMu.RLock()
+	defer coreFactoriesMu.RUnlock()
+
+	var (
+		cores   []zapcore.Core
+		closers []io.Closer
+	)
+	for _, factory := range coreFactories {
+		core := factory(conf)
+		if core == nil {
+			continue
+		}
+		cores = append(cores, core)
+		if c, ok := core.(io.Closer); ok {
+			closers = append(closers, c)
+		}
+	}
+	return cores, closers
+}
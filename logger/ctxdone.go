@@ -0,0 +1,52 @@
+package log
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type startKey struct{}
+
+// WithStart 在 ctx 中记录开始时间供 CtxDone 计算耗时, ctx 中已有开始时间时原样返回
+func WithStart(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(startKey{}).(time.Time); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, startKey{}, time.Now())
+}
+
+// CtxDone ctx 已取消或超时时以 warn 等级记录原因并返回 true, 否则不记录并返回 false
+//
+//	ctx_err     context.Canceled 或 context.DeadlineExceeded
+//	ctx_cause   context.Cause, 与 ctx_err 不同时输出, 如 context.WithCancelCause 传入的错误
+//	elapsed     距 WithStart 记录的开始时间的耗时, 没有记录时不输出
+//	deadline    ctx 的截止时间, 没有截止时间时不输出
+//	remaining   距截止时间的剩余时长, 已超时为负数
+//
+//	if err := db.QueryContext(ctx, query); err != nil && log.CtxDone(ctx, "query aborted", "sql", query) {
+//		return err
+//	}
+func CtxDone(ctx context.Context, msg string, kv ...interface{}) bool {
+	err := ctx.Err()
+	if err == nil {
+		return false
+	}
+
+	now := time.Now()
+	fields := []interface{}{zap.NamedError("ctx_err", err)}
+	if cause := context.Cause(ctx); cause != nil && cause != err {
+		fields = append(fields, zap.NamedError("ctx_cause", cause))
+	}
+	if start, ok := ctx.Value(startKey{}).(time.Time); ok {
+		fields = append(fields, zap.Duration("elapsed", now.Sub(start)))
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		fields = append(fields, zap.Time("deadline", deadline), zap.Duration("remaining", deadline.Sub(now)))
+	}
+
+	l := FromContext(ctx).Desugar().WithOptions(zap.AddCallerSkip(1)).Sugar()
+	l.Warnw(msg, append(fields, kv...)...)
+	return true
+}
@@ -0,0 +1,162 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"basic-middle/logger/requestid"
+)
+
+// FromContext 从 ctx 中取出并输出的字段名
+const (
+	TraceIDKey   = "trace_id"
+	SpanIDKey    = "span_id"
+	RequestIDKey = "request_id"
+)
+
+// ctxFields ctx 中需要输出为日志字段的值, key 相同时字段相同
+type ctxFields struct {
+	fields []zapcore.Field
+	key    strings.Builder
+}
+
+func (f *ctxFields) add(k, v string) {
+	f.fields = append(f.fields, zap.String(k, v))
+	f.key.WriteString(k)
+	f.key.WriteByte('=')
+	f.key.WriteString(v)
+	f.key.WriteByte(0)
+}
+
+// appendedFields AppendCtxFields 追加的字段, 每次追加生成一个指向上一层的节点, 各层的 ctx 互不影响
+type appendedFields struct {
+	parent *appendedFields
+	fields []zapcore.Field
+}
+
+type appendedFieldsKey struct{}
+
+// AppendCtxFields 在 ctx 中追加字段, 之后经由该 ctx 的 FromContext 都输出各层追加的所有字段, 同名字段以后追加的为准
+// 与 WithFieldsCtx 不同, 字段保存在 ctx 中而不是 logger 上, 下游通过 NewContext 替换了 logger 时依然输出
+// kv 与 SugaredLogger.With 相同, 可以是 key/value 对或 zap.Field, 不成对的 key 忽略
+//
+//	ctx = log.AppendCtxFields(ctx, "order_id", orderID) // handler
+//	ctx = log.AppendCtxFields(ctx, "shard", shard)      // repo
+//	log.FromContext(ctx).Info("query")                  // 同时带有 order_id 和 shard
+func AppendCtxFields(ctx context.Context, kv ...interface{}) context.Context {
+	fields := make([]zapcore.Field, 0, len(kv)/2)
+	for i := 0; i < len(kv); i++ {
+		if f, ok := kv[i].(zapcore.Field); ok {
+			fields = append(fields, f)
+			continue
+		}
+		k, ok := kv[i].(string)
+		if !ok || i+1 >= len(kv) {
+			continue
+		}
+		fields = append(fields, zap.Any(k, kv[i+1]))
+		i++
+	}
+	if len(fields) == 0 {
+		return ctx
+	}
+	parent, _ := ctx.Value(appendedFieldsKey{}).(*appendedFields)
+	return context.WithValue(ctx, appendedFieldsKey{}, &appendedFields{parent: parent, fields: fields})
+}
+
+// addAppended 按追加的顺序输出各层的字段, 同名字段只保留最后追加的值
+func (f *ctxFields) addAppended(node *appendedFields) {
+	var layers []*appendedFields
+	for n := node; n != nil; n = n.parent {
+		layers = append(layers, n)
+	}
+	seen := make(map[string]int)
+	start := len(f.fields)
+	for i := len(layers) - 1; i >= 0; i-- {
+		for _, field := range layers[i].fields {
+			if j, ok := seen[field.Key]; ok {
+				f.fields[j] = field
+				continue
+			}
+			seen[field.Key] = len(f.fields)
+			f.fields = append(f.fields, field)
+		}
+	}
+	if len(f.fields) > start {
+		// 节点创建后不再修改, 以地址区分
+		fmt.Fprintf(&f.key, "fields=%p", node)
+	}
+}
+
+func fieldsFromContext(ctx context.Context) *ctxFields {
+	f := &ctxFields{}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		f.add(TraceIDKey, sc.TraceID().String())
+		f.add(SpanIDKey, sc.SpanID().String())
+		// 供配置了 SpanEventLevel 的 logger 写入 span event, key 中的 span_id 已区分 span
+		if span := trace.SpanFromContext(ctx); span.IsRecording() {
+			f.fields = append(f.fields, spanField(span))
+		}
+	}
+	if id := requestid.FromContext(ctx); id != "" {
+		f.add(RequestIDKey, id)
+	}
+	if keys, _ := baggageKeys.Load().([]string); len(keys) > 0 {
+		bag := baggage.FromContext(ctx)
+		for _, k := range keys {
+			if m := bag.Member(k); m.Key() != "" {
+				f.add(k, m.Value())
+			}
+		}
+	}
+	if node, ok := ctx.Value(appendedFieldsKey{}).(*appendedFields); ok {
+		f.addAppended(node)
+	}
+	return f
+}
+
+// baggageKeys LoggerConfig.BaggageKeys, 全局 logger 初始化和 Reload 时设置
+var baggageKeys atomic.Value // []string
+
+func setBaggageKeys(keys []string) {
+	baggageKeys.Store(append([]string(nil), keys...))
+}
+
+// withCtxFields 返回带有 ctx 中 trace_id、span_id、request_id、baggage 和 AppendCtxFields 字段的 logger
+// l 已带有从其他 ctx 取出的字段时替换为当前的值, 不会重复输出; ctx 中没有这些值时保留 l 已有的字段
+func withCtxFields(ctx context.Context, l *zap.SugaredLogger) *zap.SugaredLogger {
+	f := fieldsFromContext(ctx)
+	if len(f.fields) == 0 {
+		// 如 gin.Context 取不到 request context 中的值, 保留 logger 已有的字段
+		return l
+	}
+	zl := l.Desugar()
+	base := zl.Core()
+	if cc, ok := base.(*ctxCore); ok {
+		if cc.key == f.key.String() {
+			return l
+		}
+		base = cc.base
+	}
+
+	core := &ctxCore{Core: base.With(f.fields), base: base, key: f.key.String()}
+	return zl.WithOptions(zap.WrapCore(func(zapcore.Core) zapcore.Core { return core })).Sugar()
+}
+
+// ctxCore Core 带有从 ctx 取出的字段, base 为不带这些字段的同一 core, 用于切换 ctx
+type ctxCore struct {
+	zapcore.Core
+	base zapcore.Core
+	key  string
+}
+
+func (c *ctxCore) With(fields []zapcore.Field) zapcore.Core {
+	return &ctxCore{Core: c.Core.With(fields), base: c.base.With(fields), key: c.key}
+}
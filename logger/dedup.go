@@ -0,0 +1,128 @@
+package log
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// DedupConfig 重复日志合并配置
+// 窗口内连续出现的同名 logger、同等级、同内容的日志只输出第一条,
+// 其余在重复结束、超出窗口或 Sync 时合并为一条带 repeat_count 字段的日志
+type DedupConfig struct {
+	WindowMs int `json:"window_ms"` //合并窗口毫秒数, 默认 1000
+}
+
+type dedupKey struct {
+	name  string
+	level zapcore.Level
+	msg   string
+}
+
+// dedupState 同一 logger 派生出的所有 core 共享, 只记录最近一条日志
+type dedupState struct {
+	mu     sync.Mutex
+	window time.Duration
+	key    dedupKey
+	first  time.Time
+	last   zapcore.Entry
+	core   zapcore.Core // 最近一条重复日志所在的 core, 合并后的日志带上它的字段
+	repeat int
+	timer  *time.Timer // 第一次重复时启动, 窗口结束时输出合并日志
+	gen    uint64      // 每次 take 递增, 过期的 timer 不再输出
+}
+
+type dedupCore struct {
+	zapcore.Core
+	state *dedupState
+}
+
+func newDedupCore(core zapcore.Core, conf *DedupConfig) zapcore.Core {
+	if conf == nil {
+		return core
+	}
+	window := time.Duration(conf.WindowMs) * time.Millisecond
+	if window <= 0 {
+		window = time.Second
+	}
+	return &dedupCore{Core: core, state: &dedupState{window: window}}
+}
+
+func (c *dedupCore) With(fields []zapcore.Field) zapcore.Core {
+	return &dedupCore{Core: c.Core.With(fields), state: c.state}
+}
+
+func (c *dedupCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.Core.Enabled(ent.Level) {
+		return ce
+	}
+
+	key := dedupKey{name: ent.LoggerName, level: ent.Level, msg: ent.Message}
+	s := c.state
+	s.mu.Lock()
+	if key == s.key && ent.Time.Sub(s.first) < s.window {
+		s.last, s.core = ent, c.Core
+		s.repeat++
+		if s.repeat == 1 {
+			s.arm()
+		}
+		s.mu.Unlock()
+		return ce
+	}
+	flush := s.take()
+	s.key, s.first = key, ent.Time
+	s.mu.Unlock()
+
+	flush()
+	return c.Core.Check(ent, ce)
+}
+
+func (c *dedupCore) Sync() error {
+	c.state.mu.Lock()
+	flush := c.state.take()
+	c.state.mu.Unlock()
+
+	flush()
+	return c.Core.Sync()
+}
+
+// arm 在窗口结束时输出合并日志, 之后没有新日志也不会丢失计数, 调用方持有锁
+func (s *dedupState) arm() {
+	delay := time.Until(s.first.Add(s.window))
+	if delay < 0 {
+		delay = 0
+	}
+	gen := s.gen
+	s.timer = time.AfterFunc(delay, func() {
+		s.mu.Lock()
+		if s.gen != gen {
+			s.mu.Unlock()
+			return
+		}
+		flush := s.take()
+		s.mu.Unlock()
+
+		flush()
+	})
+}
+
+// take 取出待输出的合并日志并清空计数, 调用方持有锁, 在释放锁之后执行返回的函数
+func (s *dedupState) take() func() {
+	if s.repeat == 0 {
+		return func() {}
+	}
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	s.gen++
+	ent, core, repeat := s.last, s.core, s.repeat
+	s.repeat, s.core = 0, nil
+	return func() {
+		if ce := core.Check(ent, nil); ce != nil {
+			ce.Write(zap.Int("repeat_count", repeat))
+		}
+	}
+}
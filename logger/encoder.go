@@ -0,0 +1,177 @@
+package log
+
+import (
+	"os"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// 日志输出格式
+const (
+	FormatConsole = "console"
+	FormatJSON    = "json"
+)
+
+// EncoderKeys 日志中各字段的名称, 用于对齐公司统一的日志规范
+// 为空使用默认名称, 设置为 "-" 则不输出该字段
+type EncoderKeys struct {
+	Time       string `json:"time"`       //默认 time
+	Level      string `json:"level"`      //默认 level
+	Name       string `json:"name"`       //默认 log
+	Caller     string `json:"caller"`     //默认 file
+	Message    string `json:"message"`    //默认 msg
+	Stacktrace string `json:"stacktrace"` //默认 stacktrace
+}
+
+// 预置的时间格式, 其余值按 Go 的时间 layout 处理
+const (
+	TimeFormatDefault     = "2006-01-02 15:04:05"
+	TimeFormatMillis      = "millis"       //2006-01-02 15:04:05.000
+	TimeFormatRFC3339     = "rfc3339"      //2006-01-02T15:04:05Z07:00
+	TimeFormatRFC3339Nano = "rfc3339nano"  //2006-01-02T15:04:05.999999999Z07:00
+	TimeFormatEpoch       = "epoch"        //秒级时间戳, 浮点数
+	TimeFormatEpochMillis = "epoch_millis" //毫秒级时间戳, 整数
+)
+
+// newTimeEncoder 按 conf.TimeFormat 和 conf.TimeUTC 编码时间
+func newTimeEncoder(conf *LoggerConfig) zapcore.TimeEncoder {
+	var enc zapcore.TimeEncoder
+	switch conf.TimeFormat {
+	case TimeFormatEpoch:
+		enc = zapcore.EpochTimeEncoder
+	case TimeFormatEpochMillis:
+		enc = func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+			enc.AppendInt64(t.UnixNano() / int64(time.Millisecond))
+		}
+	default:
+		layout := conf.TimeFormat
+		switch layout {
+		case "":
+			layout = TimeFormatDefault
+		case TimeFormatMillis:
+			layout = "2006-01-02 15:04:05.000"
+		case TimeFormatRFC3339:
+			layout = time.RFC3339
+		case TimeFormatRFC3339Nano:
+			layout = time.RFC3339Nano
+		}
+		enc = zapcore.TimeEncoderOfLayout(layout)
+	}
+
+	if !conf.TimeUTC {
+		return enc
+	}
+	return func(t time.Time, pae zapcore.PrimitiveArrayEncoder) {
+		enc(t.UTC(), pae)
+	}
+}
+
+// 日志等级的输出格式
+const (
+	LevelFormatLower   = "lower"   //debug、info、warn, 默认
+	LevelFormatUpper   = "upper"   //DEBUG、INFO、WARN
+	LevelFormatColor   = "color"   //大写并着色, 适合终端
+	LevelFormatNumeric = "numeric" //syslog severity, 如 debug 为 7、error 为 3
+)
+
+// syslogSeverity 各等级对应的 syslog severity
+var syslogSeverity = map[zapcore.Level]int64{
+	zapcore.DebugLevel:  7,
+	zapcore.InfoLevel:   6,
+	zapcore.WarnLevel:   4,
+	zapcore.ErrorLevel:  3,
+	zapcore.DPanicLevel: 2,
+	zapcore.PanicLevel:  1,
+	zapcore.FatalLevel:  0,
+}
+
+// newLevelEncoder 按 conf.LevelFormat 编码等级, conf.LevelLabels 中配置的等级优先使用自定义文本
+func newLevelEncoder(conf *LoggerConfig) zapcore.LevelEncoder {
+	var enc zapcore.LevelEncoder
+	switch conf.LevelFormat {
+	case LevelFormatUpper:
+		enc = zapcore.CapitalLevelEncoder
+	case LevelFormatColor:
+		enc = zapcore.CapitalColorLevelEncoder
+	case LevelFormatNumeric:
+		enc = func(l zapcore.Level, pae zapcore.PrimitiveArrayEncoder) {
+			pae.AppendInt64(syslogSeverity[l])
+		}
+	default:
+		enc = zapcore.LowercaseLevelEncoder
+	}
+	if len(conf.LevelLabels) == 0 {
+		return enc
+	}
+
+	labels := make(map[zapcore.Level]string, len(conf.LevelLabels))
+	for level, label := range conf.LevelLabels {
+		labels[ZapLevel(level)] = label
+	}
+	return func(l zapcore.Level, pae zapcore.PrimitiveArrayEncoder) {
+		if label, ok := labels[l]; ok {
+			pae.AppendString(label)
+			return
+		}
+		enc(l, pae)
+	}
+}
+
+func encoderKey(key, def string) string {
+	switch key {
+	case "":
+		return def
+	case "-":
+		return ""
+	}
+	return key
+}
+
+// WithDefaults 返回实际使用的字段名, 未设置的使用默认名称, 不输出的字段为空
+// 供 sink 从 JSON 编码的日志中识别各字段
+func (k EncoderKeys) WithDefaults() EncoderKeys {
+	return EncoderKeys{
+		Time:       encoderKey(k.Time, "time"),
+		Level:      encoderKey(k.Level, "level"),
+		Name:       encoderKey(k.Name, "log"),
+		Caller:     encoderKey(k.Caller, "file"),
+		Message:    encoderKey(k.Message, "msg"),
+		Stacktrace: encoderKey(k.Stacktrace, "stacktrace"),
+	}
+}
+
+func newEncoderConfig(conf *LoggerConfig) zapcore.EncoderConfig {
+	keys := conf.Keys.WithDefaults()
+	return zapcore.EncoderConfig{
+		TimeKey:       keys.Time,
+		LevelKey:      keys.Level,
+		NameKey:       keys.Name,
+		CallerKey:     keys.Caller,
+		MessageKey:    keys.Message,
+		StacktraceKey: keys.Stacktrace,
+		LineEnding:    zapcore.DefaultLineEnding,
+		EncodeLevel:   newLevelEncoder(conf),
+		EncodeCaller:  zapcore.ShortCallerEncoder,
+		EncodeTime:    newTimeEncoder(conf),
+		EncodeName:    zapcore.FullNameEncoder,
+		EncodeDuration: func(d time.Duration, enc zapcore.PrimitiveArrayEncoder) {
+			enc.AppendInt64(int64(d) / 1000000)
+		},
+	}
+}
+
+// newEncoder 根据 conf.Format 选择编码器, 未知格式按 console 处理
+func newEncoder(conf *LoggerConfig) zapcore.Encoder {
+	if conf.Format == FormatJSON {
+		return zapcore.NewJSONEncoder(newEncoderConfig(conf))
+	}
+	return zapcore.NewConsoleEncoder(newEncoderConfig(conf))
+}
+
+// newDevCore 本地开发使用的 stdout 输出, 等级大写并着色, 便于在终端中阅读
+func newDevCore(conf *LoggerConfig) zapcore.Core {
+	ec := newEncoderConfig(conf)
+	ec.EncodeLevel = zapcore.CapitalColorLevelEncoder
+	return zapcore.NewCore(zapcore.NewConsoleEncoder(ec), zapcore.Lock(stdWriter{os.Stdout}), zapcore.DebugLevel)
+}
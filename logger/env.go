@@ -0,0 +1,115 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// 可覆盖 LoggerConfig 的环境变量, 同一个二进制在不同环境下无需改配置即可调整日志
+const (
+	EnvNamespace       = "LOG_NAMESPACE"
+	EnvProject         = "LOG_PROJECT"
+	EnvLevel           = "LOG_LEVEL"
+	EnvEnvironment     = "LOG_ENVIRONMENT"
+	EnvDir             = "LOG_DIR"
+	EnvFilename        = "LOG_FILENAME"
+	EnvErrorFilename   = "LOG_ERROR_FILENAME"
+	EnvFormat          = "LOG_FORMAT"
+	EnvOutputs         = "LOG_OUTPUTS" //逗号分隔, 如 file,stdout
+	EnvStacktraceLevel = "LOG_STACKTRACE_LEVEL"
+	EnvRotateBy        = "LOG_ROTATE_BY"
+	EnvRotationHours   = "LOG_ROTATION_HOURS"
+	EnvMaxSizeMB       = "LOG_MAX_SIZE_MB"
+	EnvMaxBackups      = "LOG_MAX_BACKUPS"
+	EnvMaxAgeDays      = "LOG_MAX_AGE_DAYS"
+	EnvMaxRotatedFiles = "LOG_MAX_ROTATED_FILES"
+	EnvCompress        = "LOG_COMPRESS"
+	EnvDev             = "LOG_DEV"
+	EnvTimeFormat      = "LOG_TIME_FORMAT"
+	EnvTimeUTC         = "LOG_TIME_UTC"
+)
+
+// applyEnv 返回被环境变量覆盖后的配置副本, 不修改传入的 conf
+func applyEnv(conf *LoggerConfig) (*LoggerConfig, error) {
+	c := *conf
+	envString(EnvNamespace, &c.Namespace)
+	envString(EnvProject, &c.Project)
+	envString(EnvLevel, &c.Level)
+	envString(EnvEnvironment, &c.Environment)
+	envString(EnvDir, &c.OutPutDir)
+	envString(EnvFilename, &c.Filename)
+	envString(EnvErrorFilename, &c.ErrorFilename)
+	envString(EnvFormat, &c.Format)
+	envString(EnvStacktraceLevel, &c.StacktraceLevel)
+	envString(EnvRotateBy, &c.RotateBy)
+	envString(EnvTimeFormat, &c.TimeFormat)
+	if v, ok := os.LookupEnv(EnvOutputs); ok {
+		c.Outputs = splitList(v)
+	}
+
+	for key, p := range map[string]*int{
+		EnvRotationHours:   &c.RotationHours,
+		EnvMaxSizeMB:       &c.MaxSizeMB,
+		EnvMaxBackups:      &c.MaxBackups,
+		EnvMaxAgeDays:      &c.MaxAgeDays,
+		EnvMaxRotatedFiles: &c.MaxRotatedFiles,
+	} {
+		if err := envInt(key, p); err != nil {
+			return nil, err
+		}
+	}
+	if err := envBool(EnvCompress, &c.Compress); err != nil {
+		return nil, err
+	}
+	if err := envBool(EnvDev, &c.Dev); err != nil {
+		return nil, err
+	}
+	if err := envBool(EnvTimeUTC, &c.TimeUTC); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func envString(key string, p *string) {
+	if v, ok := os.LookupEnv(key); ok {
+		*p = v
+	}
+}
+
+func envInt(key string, p *int) error {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %q", key, v)
+	}
+	*p = n
+	return nil
+}
+
+func envBool(key string, p *bool) error {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %q", key, v)
+	}
+	*p = b
+	return nil
+}
+
+func splitList(v string) []string {
+	var list []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			list = append(list, s)
+		}
+	}
+	return list
+}
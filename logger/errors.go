@@ -0,0 +1,65 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	pkgerrors "github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// stackTracer github.com/pkg/errors 创建的错误携带的堆栈
+type stackTracer interface {
+	StackTrace() pkgerrors.StackTrace
+}
+
+// causer github.com/pkg/errors 包装的错误, 旧版本未实现 Unwrap
+type causer interface {
+	Cause() error
+}
+
+// Error 使用 ctx 中的 logger 记录 error 等级的日志, 并将 err 拆解为结构化字段:
+//
+//	error        err.Error()
+//	error_cause  沿 errors.Unwrap 或 Cause 找到的最内层错误
+//	stack        错误链中最内层携带的堆栈(pkg/errors 创建的错误)
+func Error(ctx context.Context, err error, msg string, kv ...interface{}) {
+	l := FromContext(ctx).Desugar().WithOptions(zap.AddCallerSkip(1)).Sugar()
+	l.Errorw(msg, append(errorFields(err), kv...)...)
+}
+
+func errorFields(err error) []interface{} {
+	if err == nil {
+		return nil
+	}
+
+	cause := err
+	var stack pkgerrors.StackTrace
+	for e := err; e != nil; e = unwrap(e) {
+		cause = e
+		if st, ok := e.(stackTracer); ok {
+			stack = st.StackTrace()
+		}
+	}
+
+	fields := []interface{}{zap.String("error", err.Error())}
+	if cause != err {
+		fields = append(fields, zap.String("error_cause", cause.Error()))
+	}
+	if stack != nil {
+		fields = append(fields, zap.String("stack", strings.TrimPrefix(fmt.Sprintf("%+v", stack), "\n")))
+	}
+	return fields
+}
+
+func unwrap(err error) error {
+	if e := errors.Unwrap(err); e != nil {
+		return e
+	}
+	if c, ok := err.(causer); ok {
+		return c.Cause()
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package log
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	everyMu    sync.Mutex
+	everyState = make(map[string]*everyEntry)
+	nopSugar   = zap.NewNop().Sugar()
+)
+
+type everyEntry struct {
+	last       time.Time
+	interval   time.Duration
+	suppressed int
+}
+
+// 超过该数量时清理已过间隔且没有被抑制计数的 key, 避免 key 中带变量时 map 无限增长, 同 notify 的 maxLimitKeys
+const maxEveryKeys = 1024
+
+// Every 限制同一 key 的日志在 interval 内最多输出一条, 用于下游故障时的重复报错
+// 被抑制的次数在下一次输出时以 suppressed_count 字段带上
+//
+//	log.Every("redis-down", time.Minute).Warnw("redis unavailable", "err", err)
+func Every(key string, interval time.Duration) *zap.SugaredLogger {
+	now := time.Now()
+
+	everyMu.Lock()
+	e, ok := everyState[key]
+	if !ok {
+		if len(everyState) >= maxEveryKeys {
+			pruneEvery(now)
+		}
+		e = &everyEntry{}
+		everyState[key] = e
+	}
+	if ok && now.Sub(e.last) < interval {
+		e.suppressed++
+		everyMu.Unlock()
+		return nopSugar
+	}
+	suppressed := e.suppressed
+	e.last, e.interval, e.suppressed = now, interval, 0
+	everyMu.Unlock()
+
+	if suppressed > 0 {
+		return Logger().With("suppressed_count", suppressed)
+	}
+	return Logger()
+}
+
+// pruneEvery 调用时需持有 everyMu
+func pruneEvery(now time.Time) {
+	for k, e := range everyState {
+		if e.suppressed == 0 && now.Sub(e.last) >= e.interval {
+			delete(everyState, k)
+		}
+	}
+}
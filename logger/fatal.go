@@ -0,0 +1,16 @@
+package log
+
+import (
+	"os"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// fatalHook Fatal 日志写入后先刷盘, 再调用 LoggerConfig.OnFatal, 返回后以状态码 1 退出
+type fatalHook func(ent zapcore.Entry)
+
+func (h fatalHook) OnWrite(ce *zapcore.CheckedEntry, _ []zapcore.Field) {
+	Sync()
+	h(ce.Entry)
+	os.Exit(1)
+}
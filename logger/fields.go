@@ -0,0 +1,64 @@
+package log
+
+import (
+	"net"
+	"os"
+	"sort"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// globalFields 附加到每条日志的公共字段: namespace、project、主机信息、版本信息以及 conf.Fields, 空值不输出
+func globalFields(conf *LoggerConfig) []zap.Field {
+	fields := make([]zap.Field, 0, len(conf.Fields)+5)
+	if conf.Namespace != "" {
+		fields = append(fields, zap.String("namespace", conf.Namespace))
+	}
+	if conf.Project != "" {
+		fields = append(fields, zap.String("project", conf.Project))
+	}
+	if conf.HostFields {
+		fields = append(fields, hostFields()...)
+	}
+	fields = append(fields, buildFields(conf)...)
+
+	keys := make([]string, 0, len(conf.Fields))
+	for k := range conf.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fields = append(fields, zap.String(k, conf.Fields[k]))
+	}
+	return fields
+}
+
+var (
+	hostOnce   sync.Once
+	hostCached []zap.Field
+)
+
+// hostFields hostname、pid 以及出口 ip, 进程内只解析一次
+func hostFields() []zap.Field {
+	hostOnce.Do(func() {
+		if name, err := os.Hostname(); err == nil {
+			hostCached = append(hostCached, zap.String("hostname", name))
+		}
+		hostCached = append(hostCached, zap.Int("pid", os.Getpid()))
+		if ip := outboundIP(); ip != "" {
+			hostCached = append(hostCached, zap.String("ip", ip))
+		}
+	})
+	return hostCached
+}
+
+// outboundIP 默认路由使用的本机 ip, udp 的 Dial 只选择路由不会发送数据
+func outboundIP() string {
+	conn, err := net.Dial("udp", "8.8.8.8:80")
+	if err != nil {
+		return ""
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP.String()
+}
@@ -0,0 +1,148 @@
+// Package gormlog 将 gorm 的日志写入 logger, 记录 SQL、影响行数和耗时, 超过慢查询阈值的以 warn 等级记录
+// 日志使用 ctx 中的 logger, 通过 db.WithContext(ctx) 执行的语句带有请求 ID、trace_id 等字段
+//
+//	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
+//		Logger: gormlog.New(gormlog.Config{SlowThresholdMs: 200, IgnoreRecordNotFoundError: true}),
+//	})
+package gormlog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	log "basic-middle/logger"
+)
+
+// Config gorm 日志的配置
+type Config struct {
+	Level                     string `json:"level"`                         //gorm 的日志等级: silent、error、warn、info, 默认 warn, info 时记录所有 SQL
+	SlowThresholdMs           int    `json:"slow_threshold_ms"`             //耗时超过该值的 SQL 以 warn 等级记录, 默认 200, 小于 0 时不区分
+	IgnoreRecordNotFoundError bool   `json:"ignore_record_not_found_error"` //不记录 gorm.ErrRecordNotFound
+	ParameterizedQueries      bool   `json:"parameterized_queries"`         //SQL 中不填入参数, 参数中可能有敏感信息
+}
+
+// Logger 实现 gorm 的 logger.Interface
+type Logger struct {
+	level                logger.LogLevel
+	slow                 time.Duration
+	ignoreNotFound       bool
+	parameterizedQueries bool
+}
+
+// New 按配置创建 gorm 的 logger, 作为 gorm.Config.Logger 使用
+func New(conf Config) *Logger {
+	l := &Logger{
+		level:                parseLevel(conf.Level),
+		ignoreNotFound:       conf.IgnoreRecordNotFoundError,
+		parameterizedQueries: conf.ParameterizedQueries,
+	}
+	switch {
+	case conf.SlowThresholdMs == 0:
+		l.slow = 200 * time.Millisecond
+	case conf.SlowThresholdMs > 0:
+		l.slow = time.Duration(conf.SlowThresholdMs) * time.Millisecond
+	}
+	return l
+}
+
+func parseLevel(level string) logger.LogLevel {
+	switch level {
+	case "silent":
+		return logger.Silent
+	case "error":
+		return logger.Error
+	case "info":
+		return logger.Info
+	default:
+		return logger.Warn
+	}
+}
+
+// LogMode 返回指定等级的副本, 如 db.Debug() 使用 logger.Info
+func (l *Logger) LogMode(level logger.LogLevel) logger.Interface {
+	nl := *l
+	nl.level = level
+	return &nl
+}
+
+// from 返回 ctx 中的 logger, 日志的调用位置在 gorm 内部, 以 source 字段记录业务代码中的位置
+func from(ctx context.Context) *zap.SugaredLogger {
+	return log.FromContext(ctx).Named("gorm").
+		WithOptions(zap.WithCaller(false)).
+		With("source", log.CallerOutside("gorm.io/"))
+}
+
+func (l *Logger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Info {
+		from(ctx).Info(fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *Logger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Warn {
+		from(ctx).Warn(fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *Logger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Error {
+		from(ctx).Error(fmt.Sprintf(msg, args...))
+	}
+}
+
+// Trace 记录一条 SQL, 出错时为 error 等级, 慢查询为 warn 等级, 其余在 info 等级下记录
+func (l *Logger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if l.level <= logger.Silent {
+		return
+	}
+	elapsed := time.Since(begin)
+	failed := err != nil && !(l.ignoreNotFound && errors.Is(err, gorm.ErrRecordNotFound))
+	slow := l.slow > 0 && elapsed >= l.slow
+	var level zapcore.Level
+	switch {
+	case failed && l.level >= logger.Error:
+		level = zapcore.ErrorLevel
+	case slow && l.level >= logger.Warn:
+		level = zapcore.WarnLevel
+	case l.level >= logger.Info:
+		level = zapcore.InfoLevel
+	default:
+		return
+	}
+
+	sql, rows := fc()
+	fields := []interface{}{zap.String("sql", sql), zap.Duration("latency", elapsed)}
+	// rows 为 -1 时没有影响行数, 如 Row、Rows 查询
+	if rows >= 0 {
+		fields = append(fields, zap.Int64("rows", rows))
+	}
+	if err != nil {
+		fields = append(fields, zap.Error(err))
+	}
+	if slow {
+		fields = append(fields, zap.Bool("slow", true))
+	}
+	switch zl := from(ctx); level {
+	case zapcore.ErrorLevel:
+		zl.Errorw("gorm query", fields...)
+	case zapcore.WarnLevel:
+		zl.Warnw("gorm slow query", fields...)
+	default:
+		zl.Infow("gorm query", fields...)
+	}
+}
+
+// ParamsFilter 开启 ParameterizedQueries 时不将参数填入 SQL
+func (l *Logger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	if l.parameterizedQueries {
+		return sql, nil
+	}
+	return sql, params
+}
@@ -0,0 +1,33 @@
+package log
+
+import (
+	"sync"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+)
+
+var (
+	hooksMu sync.RWMutex
+	hooks   []func(zapcore.Entry) error
+)
+
+// AddHook 注册日志钩子, 每条实际写出的日志都会回调, 可用于统计指标、Fatal 时告警等
+// 对所有 logger 生效, Init 前后注册均可
+func AddHook(hook func(zapcore.Entry) error) {
+	hooksMu.Lock()
+	hooks = append(hooks, hook)
+	hooksMu.Unlock()
+}
+
+// runHooks 通过 zap.Hooks 挂载到每个 logger 上
+func runHooks(ent zapcore.Entry) error {
+	hooksMu.RLock()
+	defer hooksMu.RUnlock()
+
+	var err error
+	for _, hook := range hooks {
+		err = multierr.Append(err, hook(ent))
+	}
+	return err
+}
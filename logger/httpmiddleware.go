@@ -0,0 +1,120 @@
+package log
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"basic-middle/logger/requestid"
+)
+
+// HTTPMiddleware 使用默认 AccessConfig 的 NewHTTPMiddleware, 用于直接使用 net/http 的服务
+//
+//	http.ListenAndServe(":8080", log.HTTPMiddleware(mux))
+func HTTPMiddleware(next http.Handler) http.Handler {
+	return NewHTTPMiddleware(AccessConfig{})(next)
+}
+
+// NewHTTPMiddleware 为每个请求创建带请求 ID、client_ip 字段的 logger 并放入 r.Context(), 处理函数中通过 FromContext 取出
+// 处理函数 panic 时记录 panic 值和堆栈并返回 500, 请求结束后记录访问日志
+func NewHTTPMiddleware(conf AccessConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			body := conf.ReadBody(r)
+			ctx := RequestContext(r, "", conf.ClientIP(r))
+			r = r.WithContext(ctx)
+			requestid.SetHeader(ctx, w.Header())
+
+			sw := &statusWriter{ResponseWriter: w}
+			defer func() {
+				var err error
+				if p := recover(); p != nil {
+					if p == http.ErrAbortHandler {
+						// net/http 约定的中止请求, 交给 server 处理
+						panic(p)
+					}
+					// 调用栈为 defer 的函数 <- runtime.gopanic <- 发生 panic 的函数, 跳过前两层
+					l := FromContext(ctx).Desugar().WithOptions(zap.AddCallerSkip(2))
+					l.Error("panic recovered", zap.Any("panic", p), zap.StackSkip("stack", 2))
+					if !sw.wrote {
+						sw.WriteHeader(http.StatusInternalServerError)
+					}
+					err = errors.New("panic recovered")
+				}
+				if conf.Skip(r.URL.Path) {
+					return
+				}
+				conf.Log(ctx, &AccessEntry{
+					Method:    r.Method,
+					Path:      r.URL.Path,
+					Query:     r.URL.RawQuery,
+					UserAgent: r.UserAgent(),
+					Status:    sw.status(),
+					Latency:   time.Since(start),
+					Bytes:     sw.bytes,
+					Body:      body,
+					Err:       err,
+				})
+			}()
+			next.ServeHTTP(sw, r)
+		})
+	}
+}
+
+// statusWriter 记录状态码和响应体字节数
+type statusWriter struct {
+	http.ResponseWriter
+	code  int
+	bytes int
+	wrote bool
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if !w.wrote {
+		w.code, w.wrote = code, true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	if !w.wrote {
+		w.code, w.wrote = http.StatusOK, true
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += n
+	return n, err
+}
+
+func (w *statusWriter) status() int {
+	if !w.wrote {
+		return http.StatusOK
+	}
+	return w.code
+}
+
+// Unwrap 供 http.ResponseController 取得底层的 ResponseWriter
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if !w.wrote {
+			w.code, w.wrote = http.StatusOK, true
+		}
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("http.Hijacker not supported")
+	}
+	return h.Hijack()
+}
@@ -0,0 +1,131 @@
+// Package jobs 包装定时任务, 记录每次执行的开始、结束、错误和 panic, 上次执行未结束时跳过本次
+// 任务函数的 ctx 中带有请求 ID(ctx 中没有时每次执行生成)和 job 字段, 任务中通过 log.FromContext(ctx) 记录的日志可以按次关联
+//
+//	job := jobs.New("sync_users", syncUsers, jobs.Config{TimeoutMs: 60000})
+//	c := cron.New()
+//	c.AddJob("*/5 * * * *", job)
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	log "basic-middle/logger"
+	"basic-middle/logger/requestid"
+)
+
+// ErrOverlap 上次执行未结束, 本次跳过
+var ErrOverlap = errors.New("job is already running")
+
+// Config 任务的配置
+type Config struct {
+	TimeoutMs       int  `json:"timeout_ms"`        //每次执行的超时毫秒数, 通过 ctx 传给任务函数, 为 0 时不限制
+	SlowThresholdMs int  `json:"slow_threshold_ms"` //耗时超过该值时以 warn 等级记录结束, 为 0 时不区分
+	AllowOverlap    bool `json:"allow_overlap"`     //上次执行未结束时仍然执行, 默认跳过本次
+}
+
+// Job 包装后的任务, Run 实现 robfig/cron 的 cron.Job
+type Job struct {
+	name string
+	fn   func(ctx context.Context) error
+	conf Config
+
+	mu      sync.Mutex
+	running int       // 正在执行的次数
+	since   time.Time // 本轮连续执行的开始时间, running 从 0 变为 1 时更新
+}
+
+// New 包装任务函数, name 为日志中的 job 字段
+func New(name string, fn func(ctx context.Context) error, conf Config) *Job {
+	return &Job{name: name, fn: fn, conf: conf}
+}
+
+// Func 包装没有返回值的任务函数
+func Func(name string, fn func(ctx context.Context), conf Config) *Job {
+	return New(name, func(ctx context.Context) error {
+		fn(ctx)
+		return nil
+	}, conf)
+}
+
+// Run 执行一次任务, 用于 cron.AddJob 或 cron.AddFunc(spec, job.Run)
+func (j *Job) Run() {
+	j.RunContext(context.Background())
+}
+
+// RunContext 以 ctx 执行一次任务, 返回任务的错误, panic 时返回包含 panic 值的错误, 跳过时返回 ErrOverlap
+func (j *Job) RunContext(ctx context.Context) (err error) {
+	ctx = requestid.WithRequestID(ctx)
+	ctx = log.WithFieldsCtx(ctx, "job", j.name)
+	l := log.FromContext(ctx)
+
+	start := time.Now()
+	if since, running := j.begin(start); running > 0 {
+		if !j.conf.AllowOverlap {
+			l.Warnw("job skipped, previous run not finished", "running_since", since, "running", running)
+			return ErrOverlap
+		}
+		l.Warnw("job overlaps previous run", "running_since", since, "running", running)
+	}
+	defer j.end()
+
+	if j.conf.TimeoutMs > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(j.conf.TimeoutMs)*time.Millisecond)
+		defer cancel()
+	}
+
+	l.Infow("job started")
+	defer func() {
+		latency := time.Since(start)
+		if p := recover(); p != nil {
+			// 调用栈为 defer 的函数 <- runtime.gopanic <- 发生 panic 的函数, 跳过前两层
+			l.Desugar().WithOptions(zap.AddCallerSkip(2)).Error("job panicked",
+				zap.Any("panic", p), zap.Duration("latency", latency), zap.StackSkip("stack", 2))
+			err = fmt.Errorf("job %s panic: %v", j.name, p)
+			return
+		}
+		switch {
+		case err != nil:
+			l.Errorw("job failed", zap.Duration("latency", latency), zap.Error(err))
+		case j.conf.SlowThresholdMs > 0 && latency >= time.Duration(j.conf.SlowThresholdMs)*time.Millisecond:
+			l.Warnw("job finished", zap.Duration("latency", latency), zap.Bool("slow", true))
+		default:
+			l.Infow("job finished", zap.Duration("latency", latency))
+		}
+	}()
+	return j.fn(ctx)
+}
+
+// begin 记录一次执行, 返回之前仍在执行的次数和本轮连续执行的开始时间, 不允许重叠时不记录
+func (j *Job) begin(now time.Time) (time.Time, int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	since, running := j.since, j.running
+	if running > 0 && !j.conf.AllowOverlap {
+		return since, running
+	}
+	if running == 0 {
+		j.since = now
+	}
+	j.running++
+	return since, running
+}
+
+func (j *Job) end() {
+	j.mu.Lock()
+	j.running--
+	j.mu.Unlock()
+}
+
+// Running 正在执行的次数
+func (j *Job) Running() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.running
+}
@@ -0,0 +1,46 @@
+package log
+
+import "go.uber.org/zap/zapcore"
+
+// LazyFunc 延迟生成日志内容, 只在对应等级开启时调用
+type LazyFunc func() (msg string, kv []interface{})
+
+// DebugFn 仅在全局 logger 开启 debug 时才调用 fn, 避免为不输出的日志序列化大对象
+//
+//	log.DebugFn(func() (string, []interface{}) {
+//		b, _ := json.Marshal(req)
+//		return "request", []interface{}{"body", string(b)}
+//	})
+func DebugFn(fn LazyFunc) { logFn(zapcore.DebugLevel, fn) }
+
+// InfoFn 仅在全局 logger 开启 info 时才调用 fn
+func InfoFn(fn LazyFunc) { logFn(zapcore.InfoLevel, fn) }
+
+// WarnFn 仅在全局 logger 开启 warn 时才调用 fn
+func WarnFn(fn LazyFunc) { logFn(zapcore.WarnLevel, fn) }
+
+// ErrorFn 仅在全局 logger 开启 error 时才调用 fn
+func ErrorFn(fn LazyFunc) { logFn(zapcore.ErrorLevel, fn) }
+
+func logFn(lvl zapcore.Level, fn LazyFunc) {
+	if std == nil {
+		panic("nil logger")
+	}
+	// 全局 logger 没有名称, 只受全局等级控制
+	if !std.level.Enabled(lvl) {
+		return
+	}
+
+	msg, kv := fn()
+	l := AddCallerSkip(2)
+	switch lvl {
+	case zapcore.DebugLevel:
+		l.Debugw(msg, kv...)
+	case zapcore.InfoLevel:
+		l.Infow(msg, kv...)
+	case zapcore.WarnLevel:
+		l.Warnw(msg, kv...)
+	default:
+		l.Errorw(msg, kv...)
+	}
+}
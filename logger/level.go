@@ -0,0 +1,133 @@
+package log
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// SetLevel 运行时调整全局 logger 的日志等级, 无需重启服务
+// 未知 level 按 info 处理, 与 ZapLevel 保持一致
+func SetLevel(level string) {
+	if std == nil {
+		panic("nil logger")
+	}
+
+	std.level.SetLevel(ZapLevel(level))
+}
+
+// GetLevel 获取全局 logger 当前的日志等级
+func GetLevel() string {
+	if std == nil {
+		panic("nil logger")
+	}
+
+	if std.level.Level() > zapcore.FatalLevel {
+		return LevelOff
+	}
+	return std.level.String()
+}
+
+// LevelHandler 查看和修改全局日志等级的 http.Handler
+//
+//	GET  返回 {"level":"info"}
+//	PUT  请求体 {"level":"debug"}
+func LevelHandler() http.Handler {
+	if std == nil {
+		panic("nil logger")
+	}
+
+	return std.level
+}
+
+// ServeLevelHTTP 在 addr 上启动一个只提供 LevelHandler 的 http 服务, 阻塞直到服务退出
+//
+//	go log.ServeLevelHTTP("127.0.0.1:9091")
+//	curl -X PUT -d '{"level":"debug"}' http://127.0.0.1:9091/log/level
+func ServeLevelHTTP(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/log/level", LevelHandler())
+	return http.ListenAndServe(addr, mux)
+}
+
+// Module 获取指定模块的 logger, 等级优先使用 LoggerConfig.Levels 中该模块的配置
+// 模块名支持层级, 如 Module("dao").Named("user") 未单独配置时沿用 dao 的等级
+func Module(name string) *zap.SugaredLogger {
+	return Logger().Named(name)
+}
+
+// moduleLevels 各模块的日志等级, Reload 时整体替换
+type moduleLevels struct {
+	v atomic.Value // map[string]zapcore.Level
+}
+
+func newModuleLevels(levels map[string]string) *moduleLevels {
+	m := &moduleLevels{}
+	m.set(levels)
+	return m
+}
+
+func (m *moduleLevels) set(levels map[string]string) {
+	modules := make(map[string]zapcore.Level, len(levels))
+	for name, l := range levels {
+		modules[name] = ZapLevel(l)
+	}
+	m.v.Store(modules)
+}
+
+func (m *moduleLevels) get() map[string]zapcore.Level {
+	return m.v.Load().(map[string]zapcore.Level)
+}
+
+// levelCore 按 logger 名称选择等级, 未单独配置的模块使用全局等级
+type levelCore struct {
+	zapcore.Core
+	level   zap.AtomicLevel
+	modules *moduleLevels
+}
+
+func newLevelCore(core zapcore.Core, level zap.AtomicLevel, modules *moduleLevels) *levelCore {
+	return &levelCore{Core: core, level: level, modules: modules}
+}
+
+func (c *levelCore) enabler(name string) zapcore.LevelEnabler {
+	modules := c.modules.get()
+	for name != "" {
+		if l, ok := modules[name]; ok {
+			return l
+		}
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+	return c.level
+}
+
+// Enabled 不知道具体模块, 只要全局或任一模块开启该等级即返回 true, 精确判断在 Check 中进行
+func (c *levelCore) Enabled(lvl zapcore.Level) bool {
+	if c.level.Enabled(lvl) {
+		return true
+	}
+	for _, l := range c.modules.get() {
+		if l.Enabled(lvl) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), level: c.level, modules: c.modules}
+}
+
+func (c *levelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.enabler(ent.LoggerName).Enabled(ent.Level) {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
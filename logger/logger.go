@@ -3,91 +3,265 @@ package log
 import (
 	"context"
 	"io"
+	"os"
 	"sync"
-	"time"
 
-	rotatelogs "github.com/lestrrat-go/file-rotatelogs"
+	"go.uber.org/multierr"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
 var (
-	once   sync.Once
-	logger *zap.SugaredLogger
+	once    sync.Once
+	initErr error
+	std     *instance
 )
 
+// instance newLogger 构建的结果, 保存 logger 及其运行时可调整的部分
+type instance struct {
+	base    *zap.Logger
+	sugar   *zap.SugaredLogger
+	typed   *zap.Logger // 跳过一层栈帧, 供 DebugF 等包级函数使用
+	level   zap.AtomicLevel
+	modules *moduleLevels
+	files   []*reopenWriter
+	closers []io.Closer // 在关闭文件之前关闭, 如异步写入的 goroutine
+}
+
+func newInstance(base *zap.Logger, level zap.AtomicLevel, modules *moduleLevels, files []*reopenWriter, closers []io.Closer) *instance {
+	return &instance{
+		base:    base,
+		sugar:   base.Sugar(),
+		typed:   base.WithOptions(zap.AddCallerSkip(1)),
+		level:   level,
+		modules: modules,
+		files:   files,
+		closers: closers,
+	}
+}
+
+func (ins *instance) sync() error {
+	return ins.base.Sync()
+}
+
+// close 先刷盘再关闭输出文件, 关闭后不应再使用该 logger
+func (ins *instance) close() error {
+	err := multierr.Append(ins.sync(), closeAll(ins.closers))
+	return multierr.Append(err, closeFiles(ins.files))
+}
+
 type LoggerConfig struct {
-	Namespace string `json:"namespace"`   //命名空间
-	Project   string `json:"project"`     //项目名称
-	Level     string `json:"level"`       //日志等级
-	OutPutDir string `json:"out_put_dir"` //输出的目录
-	Filename  string `json:"filename"`    //指定生成的文件
+	Namespace   string            `json:"namespace"`    //命名空间
+	Project     string            `json:"project"`      //项目名称
+	Level       string            `json:"level"`        //日志等级, off 表示不输出任何日志
+	Environment string            `json:"environment"`  //运行环境 development|production, 默认 production; development 下 DPanic 会 panic
+	OutPutDir   string            `json:"out_put_dir"`  //输出的目录
+	Filename    string            `json:"filename"`     //指定生成的文件
+	Format      string            `json:"format"`       //输出格式 console|json, 默认 console
+	Keys        EncoderKeys       `json:"keys"`         //自定义日志字段名
+	TimeFormat  string            `json:"time_format"`  //时间格式 millis|rfc3339|rfc3339nano|epoch|epoch_millis 或 Go 的时间 layout, 默认 2006-01-02 15:04:05
+	TimeUTC     bool              `json:"time_utc"`     //时间使用 UTC, 默认本地时区
+	LevelFormat string            `json:"level_format"` //等级格式 lower|upper|color|numeric, 默认 lower
+	LevelLabels map[string]string `json:"level_labels"` //自定义等级文本, 优先于 LevelFormat, 如 {"warn":"WARNING"}
+	Outputs     []string          `json:"outputs"`      //输出目标 file|stdout|stderr、其他文件名或 URL, 如 file:///var/log/app/、kafka://broker/topic, 默认只输出到文件
+	Routes      []Route           `json:"routes"`       //按等级路由到不同输出, 配置后替代 Outputs 和 ErrorFilename
+	Sinks       []SinkConfig      `json:"sinks"`        //远程日志输出, 如 kafka、loki, 与文件等输出同时生效
+	Dev         bool              `json:"dev"`          //本地开发时额外以彩色 console 格式输出到 stdout, 此时 Outputs 中无需再配置 stdout
+
+	RotateBy        string `json:"rotate_by"`         //轮转方式 time|size, 默认 time
+	RotationHours   int    `json:"rotation_hours"`    //按时间轮转的间隔小时数, 默认 24
+	MaxSizeMB       int    `json:"max_size_mb"`       //按大小轮转时单个文件的最大 MB, 默认 100
+	MaxBackups      int    `json:"max_backups"`       //按大小轮转时保留的旧文件数, 为 0 时使用 MaxRotatedFiles
+	MaxAgeDays      int    `json:"max_age_days"`      //日志文件保留天数, 默认 7
+	MaxRotatedFiles int    `json:"max_rotated_files"` //保留的轮转文件个数, 按时间轮转时设置后替代 MaxAgeDays
+	Compress        bool   `json:"compress"`          //轮转后的旧文件是否 gzip 压缩
+
+	Archive *ArchiveConfig `json:"archive"` //轮转后上传到 S3、OSS、MinIO 等对象存储, 为空时不归档
+
+	CallerSkip      int    `json:"caller_skip"`      //调用方跳过的栈帧数, 对 logger 再做一层封装时设为 1
+	StrictKV        bool   `json:"strict_kv"`        //key/value 参数不成对或 key 不是字符串时输出 logging_bug 警告并记录真实调用位置
+	StacktraceLevel string `json:"stacktrace_level"` //该等级及以上附带堆栈, 如 error, 为空或 off 不采集
+
+	ErrorFilename string            `json:"error_filename"` //warn 及以上等级额外单独写入的文件, 为空则不单独输出
+	Levels        map[string]string `json:"levels"`         //按模块覆盖日志等级, 如 {"dao":"debug","http":"warn"}, 配合 Module 使用
+
+	Sampling map[string]SamplingConfig `json:"sampling"` //按等级配置采样, 如 {"debug":{"initial":100,"thereafter":100}}, 未配置的等级不采样
+
+	Fields     map[string]string `json:"fields"`      //附加到每条日志的字段, 如 region、env、instance_id
+	HostFields bool              `json:"host_fields"` //附加 hostname、pid 和出口 ip 字段, 初始化时解析一次
+	BuildInfo  bool              `json:"build_info"`  //未调用 SetBuildInfo 时从二进制的构建信息中读取 version、commit、build_time
+
+	BaggageKeys    []string `json:"baggage_keys"`     //FromContext 将 ctx 中 OTel baggage 的这些成员输出为同名字段, 如 tenant、user_tier, 以全局 logger 的配置为准
+	SpanEventLevel string   `json:"span_event_level"` //该等级及以上且 ctx 中有正在记录的 OTel span 时, 经由 FromContext 记录的日志同时写入 span event, 如 warn, 为空或 off 不写入
+
+	Async *AsyncConfig `json:"async"` //异步写入, 为空时同步写入
+
+	Dedup *DedupConfig `json:"dedup"` //合并窗口内连续重复的日志, 为空时不合并
+
+	ReloadOnSIGHUP bool `json:"reload_on_sighup"` //收到 SIGHUP 时重新读取配置并重新打开日志文件, 仅对全局 logger 生效
+
+	RedactKeys []string                          `json:"redact_keys"` //需要脱敏的字段名, 不区分大小写, 如 password、token、id_card、phone
+	RedactFunc func(zapcore.Field) zapcore.Field `json:"-"`           //自定义脱敏规则, 每个字段编码前调用
+
+	ExtraWriters []io.Writer         `json:"-"` //额外输出到调用方提供的 writer, 如内存 buffer, 不受 Routes 影响
+	OnFatal      func(zapcore.Entry) `json:"-"` //Fatal 日志写入并刷盘后调用, 可用于优雅退出和告警, 返回后进程以状态码 1 退出
+
+	Audit *LoggerConfig `json:"audit"` //审计日志的独立输出和保留配置, 通过 Audit() 写入, 为空时 Audit().Record 返回 ErrAuditNotConfigured
 }
 
 // Init 初始化日志
-func Init(conf *LoggerConfig) {
+// conf 中的配置可以被 LOG_LEVEL、LOG_DIR、LOG_FORMAT 等环境变量覆盖, 见 env.go
+// 输出文件无法创建时返回错误, 此时全局 logger 退化为输出到 stdout, 保证 Logger() 依然可用
+func Init(conf *LoggerConfig) error {
+	return initStd(func() (*LoggerConfig, error) { return conf, nil })
+}
+
+// initStd 只初始化一次全局 logger, 任一步骤失败都退化为 stdout 输出
+func initStd(load func() (*LoggerConfig, error)) error {
 	once.Do(func() {
-		logger = newLogger(conf)
+		var conf *LoggerConfig
+		if conf, initErr = load(); initErr == nil {
+			if conf == nil {
+				conf = &LoggerConfig{}
+			}
+			if conf, initErr = applyEnv(conf); initErr == nil {
+				std, initErr = newLogger(conf)
+			}
+		}
+		if initErr != nil {
+			std = newFallbackLogger()
+			return
+		}
+
+		stdLoad = load
+		setBaggageKeys(conf.BaggageKeys)
+		if conf.ReloadOnSIGHUP {
+			watchSIGHUP()
+		}
+		// 审计日志创建失败不影响全局 logger, 此时 Audit().Record 返回 ErrAuditNotConfigured
+		initErr = initAudit(conf)
 	})
+	return initErr
 }
 
-func newLogger(conf *LoggerConfig) *zap.SugaredLogger {
-	encoder := zapcore.NewConsoleEncoder(zapcore.EncoderConfig{
-		TimeKey:       "time",
-		LevelKey:      "level",
-		NameKey:       "log",
-		CallerKey:     "file",
-		MessageKey:    "msg",
-		StacktraceKey: "stacktrace",
-		LineEnding:    zapcore.DefaultLineEnding,
-		EncodeLevel:   zapcore.LowercaseLevelEncoder,
-		EncodeCaller:  zapcore.ShortCallerEncoder,
-		EncodeTime: func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
-			enc.AppendString(t.Format("2006-01-02 15:04:05"))
-		},
-		EncodeName: zapcore.FullNameEncoder,
-		EncodeDuration: func(d time.Duration, enc zapcore.PrimitiveArrayEncoder) {
-			enc.AppendInt64(int64(d) / 1000000)
-		},
+// InitNop 将全局 logger 初始化为不输出任何内容的 logger, 不会创建日志文件
+// 用于基准测试以及引用了调用 Logger() 的包的命令行工具
+func InitNop() {
+	once.Do(func() {
+		std = newNopLogger()
 	})
+}
 
-	// 实现两个判断日志等级的interface
-	infoLevel := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
-		return true
-	})
-	warnLevel := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
-		return lvl >= zapcore.WarnLevel
-	})
-	infoHook := getWriter(conf.OutPutDir, conf.Filename)
-	// 最后创建具体的Logger
+func newLogger(conf *LoggerConfig) (*instance, error) {
+	if conf.Level == LevelOff {
+		return newNopLogger(), nil
+	}
+
+	encoder := newEncoder(conf)
 	atomicLevel := zap.NewAtomicLevel()
 	atomicLevel.SetLevel(ZapLevel(conf.Level))
-	core := zapcore.NewTee(
-		zapcore.NewCore(encoder, zapcore.AddSync(infoHook), infoLevel),
-		zapcore.NewCore(encoder, zapcore.AddSync(infoHook), warnLevel),
-	)
-	logs := zap.New(core, zap.AddCaller(), zap.Development(), zap.AddCallerSkip(0)).Sugar()
-	logs.With("namespace", conf.Namespace, "project", conf.Project)
-	return logs
-}
-
-func getWriter(outputDir, filename string) io.Writer {
-	// 生成rotatelogs的Logger 实际生成的文件名 demo.log.YYmmddHH
-	// demo.log是指向最新日志的链接
-	// 保存7天内的日志，每1小时(整点)分割一次日志
-	hook, err := rotatelogs.New(
-		// 没有使用go风格反人类的format格式
-		outputDir+"%Y-%m-%d"+filename,
-		rotatelogs.WithLinkName(filename),
-		rotatelogs.WithMaxAge(time.Hour*24*7),
-		rotatelogs.WithRotationTime(time.Hour*24),
-	)
+
+	outs := newOutputSet(conf)
+	cores, err := newCores(conf, encoder, outs)
 	if err != nil {
-		panic(err)
+		outs.close()
+		return nil, err
+	}
+	sinkCores, sinkClosers, err := newSinkCores(conf)
+	if err != nil {
+		outs.close()
+		return nil, err
+	}
+	cores = append(cores, sinkCores...)
+	outs.closers = append(outs.closers, sinkClosers...)
+	if conf.Dev {
+		cores = append(cores, newDevCore(conf))
+	}
+	if len(conf.ExtraWriters) > 0 {
+		syncers := make([]zapcore.WriteSyncer, 0, len(conf.ExtraWriters))
+		for _, w := range conf.ExtraWriters {
+			syncers = append(syncers, zapcore.AddSync(w))
+		}
+		ws := zapcore.Lock(zapcore.NewMultiWriteSyncer(syncers...))
+		cores = append(cores, zapcore.NewCore(encoder, outs.async(ws), zapcore.DebugLevel))
+	}
+	extraCores, extraClosers := registeredCores(conf)
+	cores = append(cores, extraCores...)
+	outs.closers = append(outs.closers, extraClosers...)
+	if c := newSpanEventCore(conf); c != nil {
+		cores = append(cores, c)
+	}
+
+	// 敏感字段在每个输出 core 编码前脱敏
+	if r := newRedactor(conf); r != nil {
+		for i := range cores {
+			cores[i] = wrapRedact(cores[i], r)
+		}
+	}
+
+	// 最后创建具体的Logger
+	modules := newModuleLevels(conf.Levels)
+	core := newSamplerCore(newProviderCore(zapcore.NewTee(cores...)), conf.Sampling)
+	core = newStrictKVCore(newDedupCore(core, conf.Dedup), conf.StrictKV)
+	core = newLevelCore(core, atomicLevel, modules)
+	opts := []zap.Option{zap.AddCaller(), zap.AddCallerSkip(conf.CallerSkip), zap.Hooks(runHooks)}
+	if conf.Environment == EnvironmentDevelopment {
+		opts = append(opts, zap.Development())
 	}
-	return hook
+	if conf.OnFatal != nil {
+		opts = append(opts, zap.WithFatalHook(fatalHook(conf.OnFatal)))
+	}
+	if conf.StacktraceLevel != "" && conf.StacktraceLevel != "off" {
+		opts = append(opts, zap.AddStacktrace(ZapLevel(conf.StacktraceLevel)))
+	}
+	logs := zap.New(core, opts...).With(globalFields(conf)...)
+	return newInstance(logs, atomicLevel, modules, outs.files, outs.closers), nil
 }
 
+// newCores 按 Routes 或 Outputs/ErrorFilename 为各输出创建 core
+// 等级统一由外层的 levelCore 判断, 这里的 core 只按输出目标区分等级
+func newCores(conf *LoggerConfig, encoder zapcore.Encoder, outs *outputSet) ([]zapcore.Core, error) {
+	if len(conf.Routes) > 0 {
+		return newRouteCores(conf.Routes, encoder, outs)
+	}
+
+	cores, err := outputCores(conf.Outputs, encoder, zapcore.DebugLevel, outs)
+	if err != nil {
+		return nil, err
+	}
+
+	// warn 及以上等级单独输出一份到错误日志文件, 使用独立的轮转
+	if conf.ErrorFilename != "" {
+		ws, err := outs.openFile(":error_filename", func(c *LoggerConfig) string { return c.ErrorFilename })
+		if err != nil {
+			return nil, err
+		}
+		cores = append(cores, zapcore.NewCore(encoder, outs.async(ws), zapcore.WarnLevel))
+	}
+	return cores, nil
+}
+
+// newNopLogger 丢弃所有日志
+func newNopLogger() *instance {
+	return newInstance(zap.NewNop(), zap.NewAtomicLevelAt(zapcore.FatalLevel), newModuleLevels(nil), nil, nil)
+}
+
+// newFallbackLogger 初始化失败时使用的兜底 logger, 直接输出到 stdout
+func newFallbackLogger() *instance {
+	atomicLevel := zap.NewAtomicLevelAt(zapcore.DebugLevel)
+	core := zapcore.NewCore(newEncoder(&LoggerConfig{}), zapcore.Lock(stdWriter{os.Stdout}), atomicLevel)
+	return newInstance(zap.New(core, zap.AddCaller()), atomicLevel, newModuleLevels(nil), nil, nil)
+}
+
+// 运行环境
+const (
+	EnvironmentDevelopment = "development"
+	EnvironmentProduction  = "production"
+)
+
+// LevelOff 关闭日志输出, 只能在配置中使用
+const LevelOff = "off"
+
 var zapLevelMap = map[string]zapcore.Level{
 	"debug":  zapcore.DebugLevel,
 	"info":   zapcore.InfoLevel,
@@ -106,31 +280,95 @@ func ZapLevel(level string) zapcore.Level {
 	return zap.InfoLevel
 }
 
+// Initialized 全局 logger 是否已经初始化, 未初始化时 Logger() 等函数会 panic
+// 用于可能在 Init 之前调用的库代码
+func Initialized() bool {
+	return std != nil
+}
+
 // Logger 获取全局logger 对象
 func Logger() *zap.SugaredLogger {
-	if logger == nil {
+	if std == nil {
+		panic("nil logger")
+	}
+
+	return std.sugar
+}
+
+// Desugared 获取全局 logger 底层的 *zap.Logger
+// 热点路径可直接使用强类型的 zap.Field 避免 sugar 接口带来的内存分配
+func Desugared() *zap.Logger {
+	if std == nil {
 		panic("nil logger")
 	}
 
-	return logger
+	return std.base
 }
 
+// With 基于全局 logger 创建带有固定字段的子 logger, 用于请求或组件级别的日志
+//
+//	l := log.With("order_id", id)
+func With(kv ...interface{}) *zap.SugaredLogger {
+	return Logger().With(kv...)
+}
+
+// Named 基于全局 logger 创建指定名称的子 logger
+func Named(name string) *zap.SugaredLogger {
+	return Logger().Named(name)
+}
+
+// AddCallerSkip 基于全局 logger 创建一个额外跳过 skip 层栈帧的子 logger
+// 在封装的辅助函数中使用, 使日志记录真实的调用位置
+func AddCallerSkip(skip int) *zap.SugaredLogger {
+	return Desugared().WithOptions(zap.AddCallerSkip(skip)).Sugar()
+}
+
+// ctxKey context 中存放 logger 的 key, 不导出的类型不会与其他包的 key 冲突
+type ctxKey struct{}
+
 const loggerCtxKey = "Ctx-Key-Logger"
 
+// LoggerCtxKey 旧版本存放 logger 的字符串 key, FromContext 仍然兼容以该 key 存放的 logger
+//
+// Deprecated: 字符串 key 可能与其他包冲突, 使用 NewContext 存放 logger
 func LoggerCtxKey() string {
 	return loggerCtxKey
 }
 
-// FromContext 日志上下文承接
+// NewContext 返回携带 l 的 context, 之后通过 FromContext 取出
+func NewContext(ctx context.Context, l *zap.SugaredLogger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// WithLogger 同 NewContext, 返回携带 l 的 context
+func WithLogger(ctx context.Context, l *zap.SugaredLogger) context.Context {
+	return NewContext(ctx, l)
+}
+
+// WithFieldsCtx 在 ctx 中的 logger 上追加字段, 之后经由该 context 的 FromContext 都带有这些字段
+// 用于在中间件或处理函数中设置 user_id、order_id 等字段, 下游无需再传递
+func WithFieldsCtx(ctx context.Context, kv ...interface{}) context.Context {
+	if len(kv) == 0 {
+		return ctx
+	}
+	return NewContext(ctx, FromContext(ctx).With(kv...))
+}
+
+// FromContext 日志上下文承接, ctx 中没有 logger 时返回全局 logger
+// ctx 中有 OTel span 或请求 ID 时返回的 logger 带有 trace_id、span_id、request_id 字段
 func FromContext(ctx context.Context) *zap.SugaredLogger {
 	if ctx == nil {
 		panic("nil ctx")
 	}
+	return withCtxFields(ctx, loggerFromContext(ctx))
+}
 
-	val := ctx.Value(LoggerCtxKey())
-	if v, ok := val.(*zap.SugaredLogger); ok {
+func loggerFromContext(ctx context.Context) *zap.SugaredLogger {
+	if v, ok := ctx.Value(ctxKey{}).(*zap.SugaredLogger); ok {
+		return v
+	}
+	if v, ok := ctx.Value(loggerCtxKey).(*zap.SugaredLogger); ok {
 		return v
 	}
-
 	return Logger()
 }
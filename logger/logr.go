@@ -0,0 +1,21 @@
+package log
+
+import (
+	"github.com/go-logr/logr"
+	"github.com/go-logr/zapr"
+	"go.uber.org/zap"
+)
+
+// Logr 返回基于全局 logger 的 logr.Logger, 供内嵌的 client-go、controller-runtime 等组件使用
+// logr 的 V(n) 对应 zap 等级 -n, 即 V(1) 为 debug, 更高的 verbosity 在 debug 等级下也不会输出
+//
+//	ctrl.SetLogger(log.Logr())
+//	klog.SetLogger(log.Logr())
+func Logr() logr.Logger {
+	return NewLogr(Desugared())
+}
+
+// NewLogr 返回写入 l 的 logr.Logger
+func NewLogr(l *zap.Logger) logr.Logger {
+	return zapr.NewLogger(l)
+}
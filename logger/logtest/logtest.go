@@ -0,0 +1,26 @@
+// Package logtest 以 testing.TB 为参数创建单元测试用的 logger, 见 log.NewTestLogger
+//
+//	func TestCreateOrder(t *testing.T) {
+//		_, logs := logtest.New(t)
+//		createOrder(ctx)
+//		if logs.FilterMessage("order created").Len() != 1 {
+//			t.Fatal("missing log")
+//		}
+//	}
+package logtest
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	log "basic-middle/logger"
+)
+
+// New 同 log.NewTestLogger, 参数为 testing.TB
+// 测试期间替换全局 logger, 调用了 t.Parallel() 的测试不要使用
+func New(t testing.TB) (*zap.SugaredLogger, *observer.ObservedLogs) {
+	t.Helper()
+	return log.NewTestLogger(t)
+}
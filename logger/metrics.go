@@ -0,0 +1,47 @@
+package log
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// SinkMetrics 接收各 sink 的统计, 由 logger/metrics 通过 SetSinkMetrics 设置, 默认不统计
+// name 为 sink 类型, 同类型的多个 sink 合并统计; 实现需要并发安全且不能阻塞
+type SinkMetrics interface {
+	Written(name string, n, bytes int)         // sink 接收的日志条数和字节数
+	Failed(name string, n int)                 // 写入或重试后仍发送失败的日志条数
+	Dropped(name string, n int)                // 队列满等原因被丢弃的日志条数
+	QueueDepth(name string, depth int)         // 待发送队列的长度
+	FlushLatency(name string, d time.Duration) // 一次发送的耗时
+}
+
+type nopSinkMetrics struct{}
+
+func (nopSinkMetrics) Written(string, int, int)           {}
+func (nopSinkMetrics) Failed(string, int)                 {}
+func (nopSinkMetrics) Dropped(string, int)                {}
+func (nopSinkMetrics) QueueDepth(string, int)             {}
+func (nopSinkMetrics) FlushLatency(string, time.Duration) {}
+
+// sinkMetrics 存储 sinkMetricsHolder, atomic.Value 要求每次存储的类型相同
+var sinkMetrics atomic.Value
+
+type sinkMetricsHolder struct {
+	SinkMetrics
+}
+
+// SetSinkMetrics 设置 sink 的统计, 传入 nil 时不再统计
+func SetSinkMetrics(m SinkMetrics) {
+	if m == nil {
+		m = nopSinkMetrics{}
+	}
+	sinkMetrics.Store(sinkMetricsHolder{m})
+}
+
+// GetSinkMetrics 当前的 sink 统计, sink 实现在丢弃、发送失败等时上报
+func GetSinkMetrics() SinkMetrics {
+	if h, ok := sinkMetrics.Load().(sinkMetricsHolder); ok {
+		return h.SinkMetrics
+	}
+	return nopSinkMetrics{}
+}
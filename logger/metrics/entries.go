@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap/zapcore"
+
+	log "basic-middle/logger"
+)
+
+var entriesByLevel = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "log_entries_total",
+	Help: "Log entries written, by level.",
+}, []string{"level", "project"})
+
+// RegisterEntries 注册 log_entries_total{level,project} 并通过 log.RegisterCore 统计每条日志, 需在 log.Init 之前调用
+// 统计的是经过全局等级、模块等级和采样之后实际写出的日志
+func RegisterEntries(reg prometheus.Registerer) error {
+	if err := reg.Register(entriesByLevel); err != nil {
+		return err
+	}
+	log.RegisterCore(func(conf *log.LoggerConfig) zapcore.Core {
+		return newEntryCore(conf.Project)
+	})
+	return nil
+}
+
+// entryCore 只计数不输出的 core
+type entryCore struct {
+	counters [zapcore.FatalLevel - zapcore.DebugLevel + 1]prometheus.Counter
+}
+
+func newEntryCore(project string) *entryCore {
+	c := &entryCore{}
+	for l := zapcore.DebugLevel; l <= zapcore.FatalLevel; l++ {
+		c.counters[l-zapcore.DebugLevel] = entriesByLevel.WithLabelValues(l.String(), project)
+	}
+	return c
+}
+
+func (c *entryCore) Enabled(zapcore.Level) bool { return true }
+
+func (c *entryCore) With([]zapcore.Field) zapcore.Core { return c }
+
+func (c *entryCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return ce.AddCore(ent, c)
+}
+
+func (c *entryCore) Write(ent zapcore.Entry, _ []zapcore.Field) error {
+	if i := int(ent.Level - zapcore.DebugLevel); i >= 0 && i < len(c.counters) {
+		c.counters[i].Inc()
+	}
+	return nil
+}
+
+func (c *entryCore) Sync() error { return nil }
@@ -0,0 +1,94 @@
+// Package metrics 以 prometheus 指标暴露日志 sink 的统计, 用于日志发送静默失败时告警
+// Register 之后各 sink 开始上报, 指标以 sink 类型为 sink 标签:
+//
+//	log_sink_entries_total            sink 接收的日志条数
+//	log_sink_bytes_total              sink 接收的日志字节数
+//	log_sink_failed_total             写入或重试后仍发送失败的日志条数
+//	log_sink_dropped_total            队列满等原因被丢弃的日志条数
+//	log_sink_queue_depth              待发送队列的长度
+//	log_sink_flush_duration_seconds   一次发送的耗时
+//
+// RegisterEntries 另外按等级统计写出的日志条数 log_entries_total{level,project}, 可直接配置错误率告警
+//
+//	if err := metrics.Register(prometheus.DefaultRegisterer); err != nil {
+//		// 重复注册
+//	}
+//	metrics.RegisterEntries(prometheus.DefaultRegisterer)
+//	http.Handle("/metrics", promhttp.Handler())
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	log "basic-middle/logger"
+)
+
+var (
+	entries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "log_sink_entries_total",
+		Help: "Log entries accepted by the sink.",
+	}, []string{"sink"})
+	bytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "log_sink_bytes_total",
+		Help: "Bytes of encoded log entries accepted by the sink.",
+	}, []string{"sink"})
+	failed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "log_sink_failed_total",
+		Help: "Log entries the sink failed to write or deliver after retries.",
+	}, []string{"sink"})
+	dropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "log_sink_dropped_total",
+		Help: "Log entries dropped by the sink, e.g. when its queue is full.",
+	}, []string{"sink"})
+	queueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "log_sink_queue_depth",
+		Help: "Log entries waiting in the sink queue.",
+	}, []string{"sink"})
+	flushDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "log_sink_flush_duration_seconds",
+		Help:    "Time taken by the sink to send one batch.",
+		Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"sink"})
+)
+
+// Collectors 所有指标, 用于自行注册到其他 Registerer
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{entries, bytes, failed, dropped, queueDepth, flushDuration}
+}
+
+// Register 将指标注册到 reg 并开始统计
+func Register(reg prometheus.Registerer) error {
+	for _, c := range Collectors() {
+		if err := reg.Register(c); err != nil {
+			return err
+		}
+	}
+	log.SetSinkMetrics(sinkMetrics{})
+	return nil
+}
+
+// sinkMetrics 实现 log.SinkMetrics
+type sinkMetrics struct{}
+
+func (sinkMetrics) Written(name string, n, size int) {
+	entries.WithLabelValues(name).Add(float64(n))
+	bytes.WithLabelValues(name).Add(float64(size))
+}
+
+func (sinkMetrics) Failed(name string, n int) {
+	failed.WithLabelValues(name).Add(float64(n))
+}
+
+func (sinkMetrics) Dropped(name string, n int) {
+	dropped.WithLabelValues(name).Add(float64(n))
+}
+
+func (sinkMetrics) QueueDepth(name string, depth int) {
+	queueDepth.WithLabelValues(name).Set(float64(depth))
+}
+
+func (sinkMetrics) FlushLatency(name string, d time.Duration) {
+	flushDuration.WithLabelValues(name).Observe(d.Seconds())
+}
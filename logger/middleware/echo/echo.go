@@ -0,0 +1,59 @@
+// Package echo echo 的日志中间件, 与 logger/middleware/gin 使用相同的 log.AccessConfig
+// 为每个请求创建带请求 ID、client_ip、route 字段的 logger 并记录访问日志, 处理函数中通过 FromContext(c) 取出
+//
+//	e := echo.New()
+//	e.Use(echolog.New(log.AccessConfig{SkipPaths: []string{"/health"}, SlowThresholdMs: 500}))
+package echo
+
+import (
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	log "basic-middle/logger"
+	"basic-middle/logger/requestid"
+)
+
+// New 返回 echo 中间件, 应在其他中间件之前注册
+// 处理函数返回的错误交给 echo 的 HTTPErrorHandler 写入响应后再记录, 以便记录实际的状态码
+func New(conf log.AccessConfig) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			req := c.Request()
+			body := conf.ReadBody(req)
+
+			ctx := log.RequestContext(req, c.Path(), conf.ClientIP(req))
+			c.SetRequest(req.WithContext(ctx))
+			requestid.SetHeader(ctx, c.Response().Header())
+
+			err := next(c)
+			if err != nil {
+				c.Error(err)
+			}
+
+			if conf.Skip(req.URL.Path) {
+				return nil
+			}
+			res := c.Response()
+			conf.Log(ctx, &log.AccessEntry{
+				Method:    req.Method,
+				Path:      req.URL.Path,
+				Query:     req.URL.RawQuery,
+				UserAgent: req.UserAgent(),
+				Status:    res.Status,
+				Latency:   time.Since(start),
+				Bytes:     int(res.Size),
+				Body:      body,
+				Err:       err,
+			})
+			return nil
+		}
+	}
+}
+
+// FromContext 取出中间件为请求创建的 logger, 未使用中间件时返回全局 logger
+func FromContext(c echo.Context) *zap.SugaredLogger {
+	return log.FromContext(c.Request().Context())
+}
@@ -0,0 +1,53 @@
+// Package gin gin 的日志中间件: 为每个请求创建带请求 ID、client_ip、route 字段的 logger 并记录访问日志
+// 处理函数中通过 log.FromContext(c) 或 log.FromContext(c.Request.Context()) 取出 logger
+//
+//	r := gin.New()
+//	r.Use(ginlog.New(log.AccessConfig{SkipPaths: []string{"/health"}, SlowThresholdMs: 500}))
+package gin
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	log "basic-middle/logger"
+	"basic-middle/logger/requestid"
+)
+
+// New 返回 gin 中间件, 应在其他中间件之前注册, 使后续的中间件也能取到请求范围的 logger
+func New(conf log.AccessConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		body := conf.ReadBody(c.Request)
+
+		ctx := log.RequestContext(c.Request, c.FullPath(), conf.ClientIP(c.Request))
+		c.Request = c.Request.WithContext(ctx)
+		// gin.Context 的 Value 以字符串 key 读取 Keys, 使 log.FromContext(c) 同样取到该 logger
+		c.Set(log.LoggerCtxKey(), log.FromContext(ctx))
+		requestid.SetHeader(ctx, c.Writer.Header())
+
+		c.Next()
+
+		if conf.Skip(c.Request.URL.Path) {
+			return
+		}
+		bytes := c.Writer.Size()
+		if bytes < 0 {
+			bytes = 0
+		}
+		e := &log.AccessEntry{
+			Method:    c.Request.Method,
+			Path:      c.Request.URL.Path,
+			Query:     c.Request.URL.RawQuery,
+			UserAgent: c.Request.UserAgent(),
+			Status:    c.Writer.Status(),
+			Latency:   time.Since(start),
+			Bytes:     bytes,
+			Body:      body,
+		}
+		if len(c.Errors) > 0 {
+			e.Err = c.Errors.Last()
+		}
+		conf.Log(c.Request.Context(), e)
+	}
+}
@@ -0,0 +1,129 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"basic-middle/logger/requestid"
+)
+
+// propagator 以 W3C traceparent 和 baggage 传递 trace, 下游服务的日志可以取到相同的 trace_id
+var propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// mdCarrier 将 metadata 适配为 propagation.TextMapCarrier
+type mdCarrier metadata.MD
+
+func (c mdCarrier) Get(key string) string {
+	if v := metadata.MD(c).Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (c mdCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c mdCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// outgoingContext 将 ctx 中的请求 ID、trace 和 baggage 加入发出请求的 metadata
+func outgoingContext(ctx context.Context) context.Context {
+	ctx = requestid.AppendOutgoing(ctx)
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+	propagator.Inject(ctx, mdCarrier(md))
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// UnaryClientInterceptor 客户端一元调用拦截器, 传递请求 ID 和 trace, 结束后记录目标地址、状态码和耗时
+func UnaryClientInterceptor(conf Config) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		ctx = outgoingContext(ctx)
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		if !conf.skip(method) {
+			conf.logCall(ctx, "grpc client call", err, time.Since(start),
+				zap.String("target", cc.Target()), zap.String("grpc_method", method),
+				zap.Int("req_bytes", size(req)), zap.Int("resp_bytes", size(reply)))
+		}
+		return err
+	}
+}
+
+// StreamClientInterceptor 客户端流式调用拦截器, 流结束(RecvMsg 返回错误或 io.EOF)时记录
+// 调用方不读取到流结束时不会记录
+func StreamClientInterceptor(conf Config) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		start := time.Now()
+		ctx = outgoingContext(ctx)
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		done := func(ws *clientStream, err error) {
+			if conf.skip(method) {
+				return
+			}
+			fields := []interface{}{zap.String("target", cc.Target()), zap.String("grpc_method", method)}
+			if ws != nil {
+				fields = append(fields, ws.stats.fields()...)
+			}
+			conf.logCall(ctx, "grpc client stream", err, time.Since(start), fields...)
+		}
+		if err != nil {
+			done(nil, err)
+			return nil, err
+		}
+		return &clientStream{ClientStream: cs, done: done}, nil
+	}
+}
+
+// clientStream 统计收发的消息, 结束时调用一次 done
+type clientStream struct {
+	grpc.ClientStream
+	stats streamStats
+	once  sync.Once
+	done  func(ws *clientStream, err error)
+}
+
+func (s *clientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		s.stats.sent(m)
+	} else if !errors.Is(err, io.EOF) {
+		s.finish(err)
+	}
+	return err
+}
+
+func (s *clientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == nil:
+		s.stats.recv(m)
+	case errors.Is(err, io.EOF):
+		s.finish(nil)
+	default:
+		s.finish(err)
+	}
+	return err
+}
+
+func (s *clientStream) finish(err error) {
+	s.once.Do(func() { s.done(s, err) })
+}
@@ -0,0 +1,89 @@
+// Package grpc gRPC 的日志拦截器
+// 服务端为每个请求创建带请求 ID、grpc_method、peer 字段的 logger 放入 ctx, 处理函数中通过 log.FromContext(ctx) 取出, 请求结束后记录一条日志
+// 客户端将 ctx 中的请求 ID、trace 和 baggage 加入 metadata 传给下游, 调用结束后记录一条日志
+//
+//	s := grpc.NewServer(
+//		grpc.ChainUnaryInterceptor(grpclog.UnaryServerInterceptor(grpclog.Config{SlowThresholdMs: 500})),
+//		grpc.ChainStreamInterceptor(grpclog.StreamServerInterceptor(grpclog.Config{})),
+//	)
+//	conn, err := grpc.Dial(target,
+//		grpc.WithChainUnaryInterceptor(grpclog.UnaryClientInterceptor(grpclog.Config{})),
+//		grpc.WithChainStreamInterceptor(grpclog.StreamClientInterceptor(grpclog.Config{})),
+//	)
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	log "basic-middle/logger"
+)
+
+// Config 拦截器配置, 服务端和客户端共用
+type Config struct {
+	SkipMethods     []string `json:"skip_methods"`      //不记录的方法全名, 如 /grpc.health.v1.Health/Check
+	SlowThresholdMs int      `json:"slow_threshold_ms"` //耗时超过该值的成功调用以 warn 等级记录, 为 0 时不区分
+}
+
+func (c *Config) skip(method string) bool {
+	for _, m := range c.SkipMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// level 按状态码区分日志等级, 调用方的错误如 InvalidArgument、NotFound 记为 info
+func (c *Config) level(code codes.Code, latency time.Duration) zapcore.Level {
+	switch code {
+	case codes.OK:
+		if c.SlowThresholdMs > 0 && latency >= time.Duration(c.SlowThresholdMs)*time.Millisecond {
+			return zapcore.WarnLevel
+		}
+		return zapcore.InfoLevel
+	case codes.Canceled, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists, codes.Unauthenticated:
+		return zapcore.InfoLevel
+	case codes.DeadlineExceeded, codes.PermissionDenied, codes.ResourceExhausted, codes.FailedPrecondition,
+		codes.Aborted, codes.OutOfRange, codes.Unavailable:
+		return zapcore.WarnLevel
+	default:
+		return zapcore.ErrorLevel
+	}
+}
+
+// logCall 记录一次调用的结果
+func (c *Config) logCall(ctx context.Context, msg string, err error, latency time.Duration, fields ...interface{}) {
+	code := status.Code(err)
+	fields = append(fields, zap.String("code", code.String()), zap.Duration("latency", latency))
+	if err != nil {
+		fields = append(fields, zap.Error(err))
+	}
+	if c.SlowThresholdMs > 0 && latency >= time.Duration(c.SlowThresholdMs)*time.Millisecond {
+		fields = append(fields, zap.Bool("slow", true))
+	}
+
+	l := log.FromContext(ctx)
+	switch c.level(code, latency) {
+	case zapcore.InfoLevel:
+		l.Infow(msg, fields...)
+	case zapcore.WarnLevel:
+		l.Warnw(msg, fields...)
+	default:
+		l.Errorw(msg, fields...)
+	}
+}
+
+// size 消息编码后的字节数, 不是 protobuf 消息时为 0
+func size(msg interface{}) int {
+	if m, ok := msg.(proto.Message); ok {
+		return proto.Size(m)
+	}
+	return 0
+}
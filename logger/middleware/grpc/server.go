@@ -0,0 +1,111 @@
+package grpc
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	log "basic-middle/logger"
+	"basic-middle/logger/requestid"
+)
+
+// serverContext 返回请求范围的 ctx: 取 metadata 中的请求 ID(没有时生成)并通过响应 header 返回, logger 带有 grpc_method 和 peer 字段
+// ctx 中还没有 span 时(如未使用 otelgrpc)取 metadata 中的 traceparent, 日志带有上游的 trace_id
+func serverContext(ctx context.Context, method string) context.Context {
+	if md, ok := metadata.FromIncomingContext(ctx); ok && !trace.SpanContextFromContext(ctx).IsValid() {
+		ctx = propagator.Extract(ctx, mdCarrier(md))
+	}
+	ctx = log.WithStart(requestid.FromIncoming(ctx))
+	grpc.SetHeader(ctx, metadata.Pairs(requestid.MetadataKey, requestid.FromContext(ctx)))
+
+	kv := []interface{}{"grpc_method", method}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		kv = append(kv, "peer", p.Addr.String())
+	}
+	return log.WithFieldsCtx(ctx, kv...)
+}
+
+// UnaryServerInterceptor 服务端一元调用拦截器, 结束后记录状态码、耗时以及请求和响应的字节数
+func UnaryServerInterceptor(conf Config) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		ctx = serverContext(ctx, info.FullMethod)
+		resp, err := handler(ctx, req)
+		if !conf.skip(info.FullMethod) {
+			conf.logCall(ctx, "grpc server call", err, time.Since(start),
+				zap.Int("req_bytes", size(req)), zap.Int("resp_bytes", size(resp)))
+		}
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor 服务端流式调用拦截器, 结束后记录状态码、耗时以及收发的消息数和字节数
+func StreamServerInterceptor(conf Config) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ws := &serverStream{ServerStream: ss, ctx: serverContext(ss.Context(), info.FullMethod)}
+		err := handler(srv, ws)
+		if !conf.skip(info.FullMethod) {
+			conf.logCall(ws.ctx, "grpc server stream", err, time.Since(start), ws.stats.fields()...)
+		}
+		return err
+	}
+}
+
+// streamStats 流式调用收发的消息数和字节数, 收发可能在不同的 goroutine 中
+type streamStats struct {
+	recvMsgs, recvBytes int64
+	sentMsgs, sentBytes int64
+}
+
+func (s *streamStats) recv(msg interface{}) {
+	atomic.AddInt64(&s.recvMsgs, 1)
+	atomic.AddInt64(&s.recvBytes, int64(size(msg)))
+}
+
+func (s *streamStats) sent(msg interface{}) {
+	atomic.AddInt64(&s.sentMsgs, 1)
+	atomic.AddInt64(&s.sentBytes, int64(size(msg)))
+}
+
+func (s *streamStats) fields() []interface{} {
+	return []interface{}{
+		zap.Int64("recv_msgs", atomic.LoadInt64(&s.recvMsgs)),
+		zap.Int64("recv_bytes", atomic.LoadInt64(&s.recvBytes)),
+		zap.Int64("sent_msgs", atomic.LoadInt64(&s.sentMsgs)),
+		zap.Int64("sent_bytes", atomic.LoadInt64(&s.sentBytes)),
+	}
+}
+
+// serverStream 替换 Context 并统计收发的消息
+type serverStream struct {
+	grpc.ServerStream
+	ctx   context.Context
+	stats streamStats
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *serverStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.stats.recv(m)
+	}
+	return err
+}
+
+func (s *serverStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.stats.sent(m)
+	}
+	return err
+}
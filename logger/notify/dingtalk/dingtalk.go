@@ -0,0 +1,116 @@
+// Package dingtalk 通过钉钉群机器人发送 markdown 告警, 空导入后在 LoggerConfig.Sinks 中配置 type 为 dingtalk
+// 机器人安全设置为加签时需要配置 secret
+//
+//	sinks:
+//	  - type: dingtalk
+//	    options: {webhook: "https://oapi.dingtalk.com/robot/send?access_token=xxx", secret: SECxxx, window_ms: 60000}
+package dingtalk
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	log "basic-middle/logger"
+	"basic-middle/logger/notify"
+	"basic-middle/logger/sink"
+)
+
+func init() {
+	log.RegisterSink("dingtalk", New)
+}
+
+// Options 钉钉告警的配置
+type Options struct {
+	notify.Config
+	Webhook   string   `json:"webhook"`    //机器人的 webhook 地址, 带 access_token
+	Secret    string   `json:"secret"`     //加签密钥, 以 SEC 开头
+	AtMobiles []string `json:"at_mobiles"` //需要 @ 的成员手机号
+	AtAll     bool     `json:"at_all"`     //@ 所有人
+}
+
+// New 按 sc.Options 创建钉钉告警
+func New(conf *log.LoggerConfig, sc log.SinkConfig) (log.Sink, error) {
+	var opts Options
+	if err := sc.Decode(&opts); err != nil {
+		return nil, err
+	}
+	if opts.Webhook == "" {
+		return nil, errors.New("webhook is required")
+	}
+	n := &notifier{client: sink.NewHTTPClient(opts.TimeoutMs), opts: opts}
+	return notify.NewSink("dingtalk", conf, opts.Config, n), nil
+}
+
+type notifier struct {
+	client *http.Client
+	opts   Options
+}
+
+type message struct {
+	MsgType  string   `json:"msgtype"`
+	Markdown markdown `json:"markdown"`
+	At       at       `json:"at"`
+}
+
+type markdown struct {
+	Title string `json:"title"`
+	Text  string `json:"text"`
+}
+
+type at struct {
+	AtMobiles []string `json:"atMobiles,omitempty"`
+	IsAtAll   bool     `json:"isAtAll"`
+}
+
+type result struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+func (n *notifier) Notify(ctx context.Context, a *notify.Alert) error {
+	text := "### " + notify.Title(a) + "\n" + notify.Markdown(a)
+	// 钉钉只有在正文中包含手机号时才会 @ 到对应成员
+	for _, m := range n.opts.AtMobiles {
+		text += " @" + m
+	}
+	msg := message{
+		MsgType:  "markdown",
+		Markdown: markdown{Title: notify.Title(a), Text: text},
+		At:       at{AtMobiles: n.opts.AtMobiles, IsAtAll: n.opts.AtAll},
+	}
+
+	var res result
+	if err := notify.PostJSON(ctx, n.client, n.url(time.Now()), msg, &res); err != nil {
+		return err
+	}
+	if res.ErrCode != 0 {
+		return fmt.Errorf("dingtalk: %d %s", res.ErrCode, res.ErrMsg)
+	}
+	return nil
+}
+
+// url 加签时在 webhook 上附加 timestamp 和 sign, sign = base64(hmac_sha256(secret, timestamp + "\n" + secret))
+func (n *notifier) url(now time.Time) string {
+	if n.opts.Secret == "" {
+		return n.opts.Webhook
+	}
+	ts := strconv.FormatInt(now.UnixNano()/int64(time.Millisecond), 10)
+	mac := hmac.New(sha256.New, []byte(n.opts.Secret))
+	mac.Write([]byte(ts + "\n" + n.opts.Secret))
+	sign := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	sep := "?"
+	if strings.Contains(n.opts.Webhook, "?") {
+		sep = "&"
+	}
+	return n.opts.Webhook + sep + "timestamp=" + ts + "&sign=" + url.QueryEscape(sign)
+}
@@ -0,0 +1,158 @@
+// Package email 在出现 panic、fatal 等严重日志时通过 SMTP 发送告警邮件, 空导入后在 LoggerConfig.Sinks 中配置 type 为 email
+// 邮件正文附带告警之前的最近若干行日志, 便于定位问题
+// log.Recover 记录的 panic 为 error 等级, 需要同时告警时将 threshold 设置为 error
+//
+//	sinks:
+//	  - type: email
+//	    options: {host: smtp.example.com, port: 465, username: alert@example.com, password: xxx, to: [ops@example.com]}
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	log "basic-middle/logger"
+	"basic-middle/logger/notify"
+)
+
+func init() {
+	log.RegisterSink("email", New)
+}
+
+// Options 邮件告警的配置, 未配置 threshold 时只有 dpanic 及以上等级发送邮件, context_lines 默认 50
+type Options struct {
+	notify.Config
+	Host     string   `json:"host"`     //SMTP 服务器地址
+	Port     int      `json:"port"`     //端口, 默认 25; 465 使用 SSL 连接, 其余端口在服务器支持时使用 STARTTLS
+	Username string   `json:"username"` //登录用户名, 为空时不登录
+	Password string   `json:"password"` //登录密码或授权码
+	From     string   `json:"from"`     //发件人, 默认 username
+	To       []string `json:"to"`       //收件人
+	SSL      bool     `json:"ssl"`      //强制使用 SSL 连接, 端口为 465 时默认开启
+}
+
+// New 按 sc.Options 创建邮件告警
+func New(conf *log.LoggerConfig, sc log.SinkConfig) (log.Sink, error) {
+	var opts Options
+	if err := sc.Decode(&opts); err != nil {
+		return nil, err
+	}
+	if opts.Host == "" {
+		return nil, errors.New("host is required")
+	}
+	if len(opts.To) == 0 {
+		return nil, errors.New("to is required")
+	}
+	if opts.Port <= 0 {
+		opts.Port = 25
+	}
+	if opts.Port == 465 {
+		opts.SSL = true
+	}
+	if opts.From == "" {
+		opts.From = opts.Username
+	}
+	if opts.Threshold == "" {
+		opts.Threshold = "dpanic"
+	}
+	if opts.ContextLines == 0 {
+		opts.ContextLines = 50
+	}
+	return notify.NewSink("email", conf, opts.Config, &notifier{opts: opts}), nil
+}
+
+type notifier struct {
+	opts Options
+}
+
+func (n *notifier) Notify(ctx context.Context, a *notify.Alert) error {
+	return n.send(ctx, n.message(a))
+}
+
+// message 纯文本邮件, 主题包含等级、项目和消息
+func (n *notifier) message(a *notify.Alert) []byte {
+	subject := notify.Title(a) + " " + a.Message
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", n.opts.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(n.opts.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+
+	body := notify.Markdown(a)
+	if len(a.Context) > 0 {
+		body += fmt.Sprintf("\n最近的 %d 行日志:\n", len(a.Context))
+		body += strings.Join(a.Context, "\n") + "\n"
+	}
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return b.Bytes()
+}
+
+// send 与 smtp.SendMail 相同, 增加了超时和 SSL 连接
+func (n *notifier) send(ctx context.Context, msg []byte) error {
+	addr := net.JoinHostPort(n.opts.Host, strconv.Itoa(n.opts.Port))
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	tlsConf := &tls.Config{ServerName: n.opts.Host}
+	if n.opts.SSL {
+		conn = tls.Client(conn, tlsConf)
+	}
+
+	c, err := smtp.NewClient(conn, n.opts.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if !n.opts.SSL {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(tlsConf); err != nil {
+				return err
+			}
+		}
+	}
+	// PlainAuth 只允许在加密连接或 localhost 上发送密码
+	if n.opts.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", n.opts.Username, n.opts.Password, n.opts.Host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(n.opts.From); err != nil {
+		return err
+	}
+	for _, to := range n.opts.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,127 @@
+// Package feishu 通过飞书群机器人发送消息卡片告警, 空导入后在 LoggerConfig.Sinks 中配置 type 为 feishu
+// 机器人安全设置为签名校验时需要配置 secret
+//
+//	sinks:
+//	  - type: feishu
+//	    options: {webhook: "https://open.feishu.cn/open-apis/bot/v2/hook/xxx", secret: xxx, window_ms: 60000}
+package feishu
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+
+	log "basic-middle/logger"
+	"basic-middle/logger/notify"
+	"basic-middle/logger/sink"
+)
+
+func init() {
+	log.RegisterSink("feishu", New)
+}
+
+// Options 飞书告警的配置
+type Options struct {
+	notify.Config
+	Webhook string `json:"webhook"` //机器人的 webhook 地址
+	Secret  string `json:"secret"`  //签名密钥
+}
+
+// New 按 sc.Options 创建飞书告警
+func New(conf *log.LoggerConfig, sc log.SinkConfig) (log.Sink, error) {
+	var opts Options
+	if err := sc.Decode(&opts); err != nil {
+		return nil, err
+	}
+	if opts.Webhook == "" {
+		return nil, errors.New("webhook is required")
+	}
+	n := &notifier{client: sink.NewHTTPClient(opts.TimeoutMs), opts: opts}
+	return notify.NewSink("feishu", conf, opts.Config, n), nil
+}
+
+type notifier struct {
+	client *http.Client
+	opts   Options
+}
+
+type message struct {
+	Timestamp string `json:"timestamp,omitempty"`
+	Sign      string `json:"sign,omitempty"`
+	MsgType   string `json:"msg_type"`
+	Card      card   `json:"card"`
+}
+
+type card struct {
+	Header   header    `json:"header"`
+	Elements []element `json:"elements"`
+}
+
+type header struct {
+	Title    text   `json:"title"`
+	Template string `json:"template"`
+}
+
+type text struct {
+	Tag     string `json:"tag"`
+	Content string `json:"content"`
+}
+
+type element struct {
+	Tag     string `json:"tag"`
+	Content string `json:"content"`
+}
+
+type result struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+// 标题颜色: warn 黄色, error 红色, 更高等级紫色
+func template(l zapcore.Level) string {
+	switch {
+	case l < zapcore.ErrorLevel:
+		return "yellow"
+	case l == zapcore.ErrorLevel:
+		return "red"
+	default:
+		return "purple"
+	}
+}
+
+func (n *notifier) Notify(ctx context.Context, a *notify.Alert) error {
+	msg := message{
+		MsgType: "interactive",
+		Card: card{
+			Header:   header{Title: text{Tag: "plain_text", Content: notify.Title(a)}, Template: template(a.Level)},
+			Elements: []element{{Tag: "markdown", Content: notify.Markdown(a)}},
+		},
+	}
+	if n.opts.Secret != "" {
+		msg.Timestamp, msg.Sign = sign(n.opts.Secret, time.Now())
+	}
+
+	var res result
+	if err := notify.PostJSON(ctx, n.client, n.opts.Webhook, msg, &res); err != nil {
+		return err
+	}
+	if res.Code != 0 {
+		return fmt.Errorf("feishu: %d %s", res.Code, res.Msg)
+	}
+	return nil
+}
+
+// sign 飞书以 timestamp + "\n" + secret 作为 hmac 的密钥对空消息签名, timestamp 为秒
+func sign(secret string, now time.Time) (string, string) {
+	ts := strconv.FormatInt(now.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(ts+"\n"+secret))
+	return ts, base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,38 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"basic-middle/logger/sink"
+)
+
+// PostJSON 以 JSON POST body, 非 2xx 的响应返回 *sink.HTTPError, 2xx 时将响应解析到 result
+// 群机器人出错时也返回 200, 需要调用方检查 result 中的错误码
+func PostJSON(ctx context.Context, client *http.Client, url string, body, result interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return &sink.HTTPError{Method: req.Method, URL: req.URL.Redacted(), StatusCode: resp.StatusCode, Status: resp.Status, Body: body}
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
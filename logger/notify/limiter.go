@@ -0,0 +1,55 @@
+package notify
+
+import (
+	"sync"
+	"time"
+)
+
+// limiter 同一 key 在 window 内只放行一次, 期间被拦截的次数在下一次放行时带上
+type limiter struct {
+	window time.Duration
+	mu     sync.Mutex
+	keys   map[string]*limitState
+}
+
+type limitState struct {
+	last       time.Time
+	suppressed int
+}
+
+// 超过该数量时清理已过窗口且没有被拦截计数的 key, 避免消息中带变量时 map 无限增长
+const maxLimitKeys = 1024
+
+func newLimiter(window time.Duration) *limiter {
+	return &limiter{window: window, keys: make(map[string]*limitState)}
+}
+
+// allow 返回是否放行以及上次放行之后被拦截的次数
+func (l *limiter) allow(key string, now time.Time) (int, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	st, ok := l.keys[key]
+	if !ok {
+		if len(l.keys) >= maxLimitKeys {
+			l.prune(now)
+		}
+		l.keys[key] = &limitState{last: now}
+		return 0, true
+	}
+	if now.Sub(st.last) < l.window {
+		st.suppressed++
+		return 0, false
+	}
+	suppressed := st.suppressed
+	st.last, st.suppressed = now, 0
+	return suppressed, true
+}
+
+func (l *limiter) prune(now time.Time) {
+	for k, st := range l.keys {
+		if st.suppressed == 0 && now.Sub(st.last) >= l.window {
+			delete(l.keys, k)
+		}
+	}
+}
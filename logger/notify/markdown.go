@@ -0,0 +1,60 @@
+package notify
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 群机器人的消息长度有限, 堆栈和整条消息超出时截断
+const (
+	maxStackBytes    = 1500
+	maxMarkdownBytes = 4000
+)
+
+// Title 告警标题, 如 [ERROR] ns/demo
+func Title(a *Alert) string {
+	return fmt.Sprintf("[%s] %s/%s", a.Level.CapitalString(), a.Namespace, a.Project)
+}
+
+// Markdown 群机器人使用的 markdown 正文, 包含时间、主机、位置、消息、字段和堆栈, 不含标题
+func Markdown(a *Alert) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "- **时间**: %s\n", a.Time.Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(&b, "- **主机**: %s\n", a.Hostname)
+	if a.Logger != "" {
+		fmt.Fprintf(&b, "- **模块**: %s\n", a.Logger)
+	}
+	if a.Caller != "" {
+		fmt.Fprintf(&b, "- **位置**: %s\n", a.Caller)
+	}
+	fmt.Fprintf(&b, "- **消息**: %s\n", a.Message)
+	if a.Suppressed > 0 {
+		fmt.Fprintf(&b, "- **重复**: 上次告警后又出现 %d 次\n", a.Suppressed)
+	}
+
+	keys := make([]string, 0, len(a.Fields))
+	for k := range a.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "- %s: %v\n", k, a.Fields[k])
+	}
+
+	if a.Stack != "" {
+		fmt.Fprintf(&b, "\n```\n%s\n```\n", truncate(a.Stack, maxStackBytes))
+	}
+	return truncate(b.String(), maxMarkdownBytes)
+}
+
+// truncate 按字节截断, 不截断半个 utf-8 字符
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && s[n]&0xc0 == 0x80 {
+		n--
+	}
+	return s[:n] + "..."
+}
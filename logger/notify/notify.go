@@ -0,0 +1,231 @@
+// Package notify 告警通知的公共实现: 按等级阈值过滤、同一消息限流、异步发送
+// 具体的通知渠道在子包中实现并注册为 sink, 如 notify/webhook
+package notify
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+
+	log "basic-middle/logger"
+	"basic-middle/logger/sink"
+)
+
+// Config 各通知渠道共用的配置, 嵌入到渠道自身的 Options 中
+type Config struct {
+	Threshold string `json:"threshold"`  //触发告警的最低等级, 默认 error
+	WindowMs  int    `json:"window_ms"`  //同一消息的最小告警间隔毫秒数, 默认 60000, 窗口内重复的告警只计数
+	QueueSize int    `json:"queue_size"` //待发送的告警队列长度, 默认 100, 满时丢弃
+	TimeoutMs int    `json:"timeout_ms"` //单次发送的超时毫秒数, 默认 10000
+
+	ContextLines int `json:"context_lines"` //告警中附带之前的日志行数, 默认 0 不附带; 大于 0 时未配置 SinkConfig.Level 的 sink 接收所有等级
+}
+
+// Alert 一条告警
+type Alert struct {
+	Namespace  string                 `json:"namespace"`
+	Project    string                 `json:"project"`
+	Hostname   string                 `json:"hostname"`
+	Level      zapcore.Level          `json:"level"`
+	Logger     string                 `json:"logger,omitempty"`
+	Message    string                 `json:"message"`
+	Caller     string                 `json:"caller,omitempty"`
+	Time       time.Time              `json:"time"`
+	Stack      string                 `json:"stack,omitempty"`
+	Fields     map[string]interface{} `json:"fields,omitempty"`  //除时间、等级、消息等固定字段之外的字段
+	Suppressed int                    `json:"suppressed"`        //上次告警之后被限流的同一消息的告警数
+	Context    []string               `json:"context,omitempty"` //告警之前的日志, 按时间顺序, 见 Config.ContextLines
+}
+
+// Notifier 发送告警, 在 Sink 的发送 goroutine 中串行调用
+type Notifier interface {
+	Notify(ctx context.Context, a *Alert) error
+}
+
+// Sink 将达到阈值的日志转换为 Alert 交给 Notifier, 实现 log.Sink
+type Sink struct {
+	name      string
+	notifier  Notifier
+	keys      log.EncoderKeys
+	namespace string
+	project   string
+	hostname  string
+	threshold zapcore.Level
+	timeout   time.Duration
+	limiter   *limiter
+	ring      *ring
+
+	queue   chan *Alert
+	pending int64
+	dropped int64
+	done    chan struct{}
+	mu      sync.RWMutex
+	closed  bool
+}
+
+// NewSink 创建告警 sink 并启动发送 goroutine, name 用于 log.SinkMetrics
+func NewSink(name string, conf *log.LoggerConfig, c Config, n Notifier) *Sink {
+	threshold := zapcore.ErrorLevel
+	if c.Threshold != "" {
+		threshold = log.ZapLevel(c.Threshold)
+	}
+	if c.WindowMs <= 0 {
+		c.WindowMs = 60000
+	}
+	if c.QueueSize <= 0 {
+		c.QueueSize = 100
+	}
+	if c.TimeoutMs <= 0 {
+		c.TimeoutMs = 10000
+	}
+
+	host, _ := os.Hostname()
+	s := &Sink{
+		name:      name,
+		notifier:  n,
+		keys:      conf.Keys.WithDefaults(),
+		namespace: conf.Namespace,
+		project:   conf.Project,
+		hostname:  host,
+		threshold: threshold,
+		timeout:   time.Duration(c.TimeoutMs) * time.Millisecond,
+		limiter:   newLimiter(time.Duration(c.WindowMs) * time.Millisecond),
+		ring:      newRing(c.ContextLines),
+		queue:     make(chan *Alert, c.QueueSize),
+		done:      make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// DefaultLevel 未配置 SinkConfig.Level 时只接收达到阈值的日志, 需要附带之前的日志时接收所有等级
+func (s *Sink) DefaultLevel() zapcore.Level {
+	if s.ring != nil {
+		return zapcore.DebugLevel
+	}
+	return s.threshold
+}
+
+// Write 低于阈值或被限流的日志直接忽略, 不阻塞写日志的 goroutine
+// Panic 和 Fatal 之后进程通常会退出, 等待告警发送完成后再返回
+func (s *Sink) Write(ent zapcore.Entry, line []byte) error {
+	var recent []string
+	if s.ring != nil {
+		recent = s.ring.push(string(line), ent.Level >= s.threshold)
+	}
+	if ent.Level < s.threshold {
+		return nil
+	}
+	suppressed, ok := s.limiter.allow(ent.LoggerName+"\x00"+ent.Message, ent.Time)
+	if !ok {
+		return nil
+	}
+	a, err := s.alert(ent, line)
+	if err != nil {
+		return err
+	}
+	a.Suppressed = suppressed
+	a.Context = recent
+
+	s.enqueue(a)
+	if ent.Level >= zapcore.PanicLevel {
+		return s.Sync()
+	}
+	return nil
+}
+
+// enqueue 队列已满或 Close 之后丢弃告警
+func (s *Sink) enqueue(a *Alert) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if !s.closed {
+		atomic.AddInt64(&s.pending, 1)
+		select {
+		case s.queue <- a:
+			return
+		default:
+			atomic.AddInt64(&s.pending, -1)
+		}
+	}
+	atomic.AddInt64(&s.dropped, 1)
+	log.GetSinkMetrics().Dropped(s.name, 1)
+}
+
+func (s *Sink) alert(ent zapcore.Entry, line []byte) (*Alert, error) {
+	record, err := sink.DecodeLine(line)
+	if err != nil {
+		return nil, err
+	}
+	a := &Alert{
+		Namespace: s.namespace,
+		Project:   s.project,
+		Hostname:  s.hostname,
+		Level:     ent.Level,
+		Logger:    ent.LoggerName,
+		Message:   ent.Message,
+		Time:      ent.Time,
+		Stack:     ent.Stack,
+		Fields:    make(map[string]interface{}, len(record)),
+	}
+	if ent.Caller.Defined {
+		a.Caller = ent.Caller.TrimmedPath()
+	}
+	for k, v := range record {
+		switch k {
+		case s.keys.Time, s.keys.Level, s.keys.Name, s.keys.Caller, s.keys.Message, s.keys.Stacktrace:
+			continue
+		}
+		a.Fields[k] = v
+	}
+	return a, nil
+}
+
+func (s *Sink) run() {
+	defer close(s.done)
+	for a := range s.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+		start := time.Now()
+		err := s.notifier.Notify(ctx, a)
+		log.GetSinkMetrics().FlushLatency(s.name, time.Since(start))
+		if err != nil {
+			log.GetSinkMetrics().Failed(s.name, 1)
+			fmt.Fprintf(os.Stderr, "failed to send log alert %q: %v\n", a.Message, err)
+		}
+		cancel()
+		atomic.AddInt64(&s.pending, -1)
+	}
+}
+
+// Dropped 队列已满或 Close 之后被丢弃的告警数
+func (s *Sink) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+// Sync 等待已入队的告警发送完成, 保证 Fatal 退出前告警已经发出, 最多等待一次发送超时
+func (s *Sink) Sync() error {
+	deadline := time.Now().Add(s.timeout)
+	for atomic.LoadInt64(&s.pending) > 0 {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("log alerts not sent within %s", s.timeout)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return nil
+}
+
+// Close 发送完队列中剩余的告警后退出发送 goroutine, 之后的告警丢弃
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+	<-s.done
+	return nil
+}
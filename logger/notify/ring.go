@@ -0,0 +1,40 @@
+package notify
+
+import "sync"
+
+// ring 保存最近的 n 行日志, 作为告警的上下文
+type ring struct {
+	mu    sync.Mutex
+	lines []string
+	next  int
+	full  bool
+}
+
+// newRing n <= 0 时返回 nil, 表示不保存
+func newRing(n int) *ring {
+	if n <= 0 {
+		return nil
+	}
+	return &ring{lines: make([]string, n)}
+}
+
+// push 追加一行, snapshot 为 true 时返回追加之前保存的日志, 按时间顺序
+func (r *ring) push(line string, snapshot bool) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var lines []string
+	if snapshot {
+		if r.full {
+			lines = append(lines, r.lines[r.next:]...)
+		}
+		lines = append(lines, r.lines[:r.next]...)
+	}
+
+	r.lines[r.next] = line
+	r.next++
+	if r.next == len(r.lines) {
+		r.next, r.full = 0, true
+	}
+	return lines
+}
@@ -0,0 +1,73 @@
+// Package webhook 将告警以 JSON POST 到指定的 webhook 地址, 空导入后在 LoggerConfig.Sinks 中配置 type 为 webhook
+// 请求体为 notify.Alert 的 JSON 编码
+//
+//	sinks:
+//	  - type: webhook
+//	    options: {urls: ["https://alert.example.com/hook"], threshold: error, window_ms: 60000}
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"go.uber.org/multierr"
+
+	log "basic-middle/logger"
+	"basic-middle/logger/notify"
+	"basic-middle/logger/sink"
+)
+
+func init() {
+	log.RegisterSink("webhook", New)
+}
+
+// Options webhook 告警的配置
+type Options struct {
+	notify.Config
+	URLs    []string          `json:"urls"`    //webhook 地址, 每条告警发送到所有地址
+	Headers map[string]string `json:"headers"` //附加的请求头, 如 Authorization
+}
+
+// New 按 sc.Options 创建 webhook 告警
+func New(conf *log.LoggerConfig, sc log.SinkConfig) (log.Sink, error) {
+	var opts Options
+	if err := sc.Decode(&opts); err != nil {
+		return nil, err
+	}
+	if len(opts.URLs) == 0 {
+		return nil, errors.New("urls is required")
+	}
+	n := &notifier{client: sink.NewHTTPClient(opts.TimeoutMs), urls: opts.URLs, headers: opts.Headers}
+	return notify.NewSink("webhook", conf, opts.Config, n), nil
+}
+
+type notifier struct {
+	client  *http.Client
+	urls    []string
+	headers map[string]string
+}
+
+func (n *notifier) Notify(ctx context.Context, a *notify.Alert) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+
+	var errs error
+	for _, url := range n.urls {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			errs = multierr.Append(errs, err)
+			continue
+		}
+		req.Header.Set("Content-Type", "application/json")
+		for k, v := range n.headers {
+			req.Header.Set(k, v)
+		}
+		errs = multierr.Append(errs, sink.Do(n.client, req))
+	}
+	return errs
+}
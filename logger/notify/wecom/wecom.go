@@ -0,0 +1,73 @@
+// Package wecom 通过企业微信群机器人发送 markdown 告警, 空导入后在 LoggerConfig.Sinks 中配置 type 为 wecom
+// 企业微信群机器人不支持签名, webhook 中的 key 需要妥善保管
+//
+//	sinks:
+//	  - type: wecom
+//	    options: {webhook: "https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=xxx", window_ms: 60000}
+package wecom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	log "basic-middle/logger"
+	"basic-middle/logger/notify"
+	"basic-middle/logger/sink"
+)
+
+func init() {
+	log.RegisterSink("wecom", New)
+}
+
+// Options 企业微信告警的配置
+type Options struct {
+	notify.Config
+	Webhook string `json:"webhook"` //机器人的 webhook 地址, 带 key
+}
+
+// New 按 sc.Options 创建企业微信告警
+func New(conf *log.LoggerConfig, sc log.SinkConfig) (log.Sink, error) {
+	var opts Options
+	if err := sc.Decode(&opts); err != nil {
+		return nil, err
+	}
+	if opts.Webhook == "" {
+		return nil, errors.New("webhook is required")
+	}
+	n := &notifier{client: sink.NewHTTPClient(opts.TimeoutMs), webhook: opts.Webhook}
+	return notify.NewSink("wecom", conf, opts.Config, n), nil
+}
+
+type notifier struct {
+	client  *http.Client
+	webhook string
+}
+
+type message struct {
+	MsgType  string   `json:"msgtype"`
+	Markdown markdown `json:"markdown"`
+}
+
+type markdown struct {
+	Content string `json:"content"`
+}
+
+type result struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+func (n *notifier) Notify(ctx context.Context, a *notify.Alert) error {
+	msg := message{MsgType: "markdown", Markdown: markdown{Content: "### " + notify.Title(a) + "\n" + notify.Markdown(a)}}
+
+	var res result
+	if err := notify.PostJSON(ctx, n.client, n.webhook, msg, &res); err != nil {
+		return err
+	}
+	if res.ErrCode != 0 {
+		return fmt.Errorf("wecom: %d %s", res.ErrCode, res.ErrMsg)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package log
+
+import (
+	"io"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Option 配置 NewLogger 创建的 logger
+type Option func(conf *LoggerConfig)
+
+// NewLogger 通过 Option 创建一个独立的 logger, 不影响全局 logger
+// 新增配置项只需增加对应的 Option, 调用方代码保持兼容
+// 创建的 logger 与 New 注册的 logger 一样随 log.Sync 刷盘、随 log.Close 关闭
+//
+//	l, err := log.NewLogger(log.WithProject("ns", "demo"), log.WithFile("logs/", "demo.log"), log.WithLevel("debug"))
+func NewLogger(opts ...Option) (*zap.SugaredLogger, error) {
+	conf := &LoggerConfig{}
+	for _, opt := range opts {
+		opt(conf)
+	}
+
+	ins, err := newLogger(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	registryMu.Lock()
+	unnamed = append(unnamed, ins)
+	registryMu.Unlock()
+	return ins.sugar, nil
+}
+
+// WithConfig 以已有的配置为基础, 应放在其他 Option 之前
+func WithConfig(c *LoggerConfig) Option {
+	return func(conf *LoggerConfig) {
+		*conf = *c
+	}
+}
+
+// WithProject 设置命名空间和项目名称
+func WithProject(namespace, project string) Option {
+	return func(conf *LoggerConfig) {
+		conf.Namespace = namespace
+		conf.Project = project
+	}
+}
+
+// WithLevel 设置日志等级
+func WithLevel(level string) Option {
+	return func(conf *LoggerConfig) {
+		conf.Level = level
+	}
+}
+
+// WithFormat 设置输出格式 console|json
+func WithFormat(format string) Option {
+	return func(conf *LoggerConfig) {
+		conf.Format = format
+	}
+}
+
+// WithOutput 设置输出目标 file|stdout|stderr
+func WithOutput(outputs ...string) Option {
+	return func(conf *LoggerConfig) {
+		conf.Outputs = outputs
+	}
+}
+
+// WithFile 设置日志目录和文件名
+func WithFile(dir, filename string) Option {
+	return func(conf *LoggerConfig) {
+		conf.OutPutDir = dir
+		conf.Filename = filename
+	}
+}
+
+// WithRotation 按时间轮转, 每 rotationHours 小时切分, 保留 maxAgeDays 天
+func WithRotation(rotationHours, maxAgeDays int) Option {
+	return func(conf *LoggerConfig) {
+		conf.RotateBy = RotateByTime
+		conf.RotationHours = rotationHours
+		conf.MaxAgeDays = maxAgeDays
+	}
+}
+
+// WithSizeRotation 按大小轮转, 单个文件 maxSizeMB, 保留 maxBackups 个旧文件
+func WithSizeRotation(maxSizeMB, maxBackups int) Option {
+	return func(conf *LoggerConfig) {
+		conf.RotateBy = RotateBySize
+		conf.MaxSizeMB = maxSizeMB
+		conf.MaxBackups = maxBackups
+	}
+}
+
+// WithFields 附加到每条日志的字段, 多次调用会合并
+func WithFields(fields map[string]string) Option {
+	return func(conf *LoggerConfig) {
+		merged := make(map[string]string, len(conf.Fields)+len(fields))
+		for k, v := range conf.Fields {
+			merged[k] = v
+		}
+		for k, v := range fields {
+			merged[k] = v
+		}
+		conf.Fields = merged
+	}
+}
+
+// WithCallerSkip 设置调用方跳过的栈帧数
+func WithCallerSkip(skip int) Option {
+	return func(conf *LoggerConfig) {
+		conf.CallerSkip = skip
+	}
+}
+
+// WithHostFields 每条日志附加 hostname、pid 和出口 ip
+func WithHostFields() Option {
+	return func(conf *LoggerConfig) {
+		conf.HostFields = true
+	}
+}
+
+// WithExtraWriters 在原有输出之外同时写入 ws, 无需自行创建 core
+// ws 实现了 zapcore.WriteSyncer 时 Sync 会同步调用
+func WithExtraWriters(ws ...io.Writer) Option {
+	return func(conf *LoggerConfig) {
+		conf.ExtraWriters = append(conf.ExtraWriters, ws...)
+	}
+}
+
+// WithOnFatal Fatal 日志写入并刷盘后调用 fn, 如停止接收请求、等待进行中的请求结束、发送告警
+func WithOnFatal(fn func(ent zapcore.Entry)) Option {
+	return func(conf *LoggerConfig) {
+		conf.OnFatal = fn
+	}
+}
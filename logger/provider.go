@@ -0,0 +1,80 @@
+package log
+
+import (
+	"os"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// FieldProvider 每条日志写入时调用, 返回的字段附加到该条日志
+type FieldProvider func() []zap.Field
+
+var (
+	providersMu sync.RWMutex
+	providers   []FieldProvider
+)
+
+// AddFieldProvider 注册动态字段, 对所有 logger 立即生效, 如当前 goroutine 数、特性开关的版本
+// provider 在每条输出的日志上都会调用一次, 应保证足够轻量
+//
+//	log.AddFieldProvider(func() []zap.Field { return []zap.Field{zap.Int("goroutines", runtime.NumGoroutine())} })
+func AddFieldProvider(p FieldProvider) {
+	providersMu.Lock()
+	providers = append(providers, p)
+	providersMu.Unlock()
+}
+
+func providerFields() []zap.Field {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+
+	var fields []zap.Field
+	for _, p := range providers {
+		fields = append(fields, p()...)
+	}
+	return fields
+}
+
+func hasProviders() bool {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	return len(providers) > 0
+}
+
+// providerCore 包在 Tee 外层, 写入时求值一次后再交给 Tee 按输出分发
+type providerCore struct {
+	zapcore.Core
+}
+
+func newProviderCore(core zapcore.Core) zapcore.Core {
+	return &providerCore{Core: core}
+}
+
+func (c *providerCore) With(fields []zapcore.Field) zapcore.Core {
+	return &providerCore{Core: c.Core.With(fields)}
+}
+
+func (c *providerCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !hasProviders() {
+		return c.Core.Check(ent, ce)
+	}
+	if c.Core.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+// Write 经由 Tee 的 Check 按各输出的等级分发, 写入错误与 zap 一样输出到 stderr
+func (c *providerCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	ce := c.Core.Check(ent, nil)
+	if ce == nil {
+		return nil
+	}
+	ce.ErrorOutput = zapcore.Lock(os.Stderr)
+	extra := providerFields()
+	all := make([]zapcore.Field, 0, len(fields)+len(extra))
+	ce.Write(append(append(all, fields...), extra...)...)
+	return nil
+}
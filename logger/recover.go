@@ -0,0 +1,40 @@
+package log
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// Recover 捕获 panic 并以 error 等级记录 panic 值和堆栈, 必须直接 defer 调用
+//
+//	defer log.Recover(ctx)
+func Recover(ctx context.Context) {
+	if r := recover(); r != nil {
+		logPanic(ctx, r)
+	}
+}
+
+// RecoverAndPanic 与 Recover 相同, 记录日志后重新抛出 panic, 用于不能吞掉 panic 的场景
+//
+//	defer log.RecoverAndPanic(ctx)
+func RecoverAndPanic(ctx context.Context) {
+	if r := recover(); r != nil {
+		logPanic(ctx, r)
+		panic(r)
+	}
+}
+
+// Go 启动一个 goroutine 执行 fn, fn 中的 panic 会被记录而不会导致进程退出
+func Go(fn func()) {
+	go func() {
+		defer Recover(context.Background())
+		fn()
+	}()
+}
+
+// logPanic 调用栈为 logPanic <- Recover <- runtime.gopanic <- 发生 panic 的函数, 跳过前三层
+func logPanic(ctx context.Context, r interface{}) {
+	l := FromContext(ctx).Desugar().WithOptions(zap.AddCallerSkip(3))
+	l.Error("panic recovered", zap.Any("panic", r), zap.StackSkip("stack", 3))
+}
@@ -0,0 +1,78 @@
+package log
+
+import (
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// RedactMask 敏感字段脱敏后的值
+const RedactMask = "****"
+
+// redactor 对 RedactKeys 中的字段做脱敏, 并调用 RedactFunc 处理自定义规则
+// 只处理顶层字段, zap.Object 等嵌套结构内部的字段需要调用方自行处理
+type redactor struct {
+	keys map[string]struct{}
+	fn   func(zapcore.Field) zapcore.Field
+}
+
+func newRedactor(conf *LoggerConfig) *redactor {
+	if len(conf.RedactKeys) == 0 && conf.RedactFunc == nil {
+		return nil
+	}
+
+	keys := make(map[string]struct{}, len(conf.RedactKeys))
+	for _, k := range conf.RedactKeys {
+		keys[strings.ToLower(k)] = struct{}{}
+	}
+	return &redactor{keys: keys, fn: conf.RedactFunc}
+}
+
+// redact 返回脱敏后的新切片, 不修改调用方传入的 fields
+func (r *redactor) redact(fields []zapcore.Field) []zapcore.Field {
+	if len(fields) == 0 {
+		return fields
+	}
+
+	out := make([]zapcore.Field, len(fields))
+	for i, f := range fields {
+		if _, ok := r.keys[strings.ToLower(f.Key)]; ok {
+			f = zap.String(f.Key, RedactMask)
+		}
+		if r.fn != nil {
+			f = r.fn(f)
+		}
+		out[i] = f
+	}
+	return out
+}
+
+// redactCore 包装在每个输出 core 外层, 在编码前替换敏感字段
+type redactCore struct {
+	zapcore.Core
+	r *redactor
+}
+
+func wrapRedact(core zapcore.Core, r *redactor) zapcore.Core {
+	if r == nil {
+		return core
+	}
+	return &redactCore{Core: core, r: r}
+}
+
+func (c *redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactCore{Core: c.Core.With(c.r.redact(fields)), r: c.r}
+}
+
+// Check 由内层 core 自行判断(如采样、按 logger 名称过滤), 通过判断后写入时先脱敏
+func (c *redactCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Core.Check(ent, nil) == nil {
+		return ce
+	}
+	return ce.AddCore(ent, c)
+}
+
+func (c *redactCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(ent, c.r.redact(fields))
+}
@@ -0,0 +1,208 @@
+// Package redislog go-redis 的 Hook, 通过 ctx 中的 logger 记录命令和 pipeline 的耗时与错误
+// 出错的命令为 error 等级, 超过慢命令阈值的为 warn, 其余为 debug; redis.Nil 不视为错误
+//
+//	rdb := redis.NewClient(&redis.Options{Addr: "redis:6379"})
+//	rdb.AddHook(redislog.New(redislog.Config{SlowThresholdMs: 50, SkipCommands: []string{"ping"}}))
+package redislog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	log "basic-middle/logger"
+)
+
+// Config Hook 的配置
+type Config struct {
+	SlowThresholdMs int      `json:"slow_threshold_ms"` //耗时超过该值的命令以 warn 等级记录, 默认 100, 小于 0 时不区分
+	Commands        []string `json:"commands"`          //只记录这些命令, 如 get、set, 为空时记录所有命令; 出错的命令总是记录
+	SkipCommands    []string `json:"skip_commands"`     //不记录的命令, 如 ping; 出错的命令总是记录
+	LogArgs         bool     `json:"log_args"`          //记录命令的参数, 参数中可能有敏感信息
+	MaxArgsLen      int      `json:"max_args_len"`      //LogArgs 时参数的最大长度, 超过时截断, 默认 256
+}
+
+// Hook 实现 redis.Hook
+type Hook struct {
+	slow       time.Duration
+	commands   map[string]bool
+	skip       map[string]bool
+	logArgs    bool
+	maxArgsLen int
+}
+
+// New 按配置创建 Hook, 通过 rdb.AddHook 添加
+func New(conf Config) *Hook {
+	h := &Hook{
+		commands:   toSet(conf.Commands),
+		skip:       toSet(conf.SkipCommands),
+		logArgs:    conf.LogArgs,
+		maxArgsLen: conf.MaxArgsLen,
+	}
+	switch {
+	case conf.SlowThresholdMs == 0:
+		h.slow = 100 * time.Millisecond
+	case conf.SlowThresholdMs > 0:
+		h.slow = time.Duration(conf.SlowThresholdMs) * time.Millisecond
+	}
+	if h.maxArgsLen <= 0 {
+		h.maxArgsLen = 256
+	}
+	return h
+}
+
+func toSet(names []string) map[string]bool {
+	m := make(map[string]bool, len(names))
+	for _, name := range names {
+		m[strings.ToLower(name)] = true
+	}
+	return m
+}
+
+// verbose 命令是否记录正常和慢的调用
+func (h *Hook) verbose(name string) bool {
+	if h.skip[name] {
+		return false
+	}
+	return len(h.commands) == 0 || h.commands[name]
+}
+
+// DialHook 记录建立连接失败
+func (h *Hook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		start := time.Now()
+		conn, err := next(ctx, network, addr)
+		if err != nil {
+			h.log(ctx, "redis dial", start, err, zap.String("addr", addr))
+		}
+		return conn, err
+	}
+}
+
+// ProcessHook 记录单个命令
+func (h *Hook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		if cmdErr(err) == nil && !h.verbose(cmd.Name()) {
+			return err
+		}
+		fields := []interface{}{zap.String("cmd", cmd.FullName())}
+		if h.logArgs {
+			fields = append(fields, zap.String("args", h.args(cmd)))
+		}
+		h.log(ctx, "redis command", start, cmdErr(err), fields...)
+		return err
+	}
+}
+
+// ProcessPipelineHook 记录 pipeline 和事务的命令数、出错的命令数与第一个错误
+func (h *Hook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+
+		var (
+			failed   int
+			firstErr = cmdErr(err)
+			verbose  bool
+		)
+		for _, cmd := range cmds {
+			if e := cmdErr(cmd.Err()); e != nil {
+				failed++
+				if firstErr == nil {
+					firstErr = e
+				}
+			}
+			if h.verbose(cmd.Name()) {
+				verbose = true
+			}
+		}
+		if firstErr == nil && !verbose {
+			return err
+		}
+		fields := []interface{}{zap.Int("cmds", len(cmds)), zap.String("cmd_names", names(cmds))}
+		if failed > 0 {
+			fields = append(fields, zap.Int("failed", failed))
+		}
+		h.log(ctx, "redis pipeline", start, firstErr, fields...)
+		return err
+	}
+}
+
+// cmdErr redis.Nil 表示 key 不存在, 不是错误
+func cmdErr(err error) error {
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	return err
+}
+
+// names pipeline 中的命令名, 连续相同的命令合并为 name*n, 如 multi,set*3,exec
+func names(cmds []redis.Cmder) string {
+	var b strings.Builder
+	for i := 0; i < len(cmds); {
+		j := i + 1
+		for j < len(cmds) && cmds[j].Name() == cmds[i].Name() {
+			j++
+		}
+		if b.Len() > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(cmds[i].Name())
+		if j-i > 1 {
+			b.WriteString("*" + strconv.Itoa(j-i))
+		}
+		i = j
+	}
+	return b.String()
+}
+
+// args 命令名之后的参数, 以空格分隔
+func (h *Hook) args(cmd redis.Cmder) string {
+	var b strings.Builder
+	for i, arg := range cmd.Args() {
+		if i == 0 {
+			continue
+		}
+		if i > 1 {
+			b.WriteByte(' ')
+		}
+		fmt.Fprint(&b, arg)
+		if b.Len() > h.maxArgsLen {
+			return strings.ToValidUTF8(b.String()[:h.maxArgsLen], "") + "..."
+		}
+	}
+	return b.String()
+}
+
+func (h *Hook) log(ctx context.Context, msg string, start time.Time, err error, fields ...interface{}) {
+	latency := time.Since(start)
+	slow := h.slow > 0 && latency >= h.slow
+	fields = append(fields, zap.Duration("latency", latency))
+	if err != nil {
+		fields = append(fields, zap.Error(err))
+	}
+	if slow {
+		fields = append(fields, zap.Bool("slow", true))
+	}
+
+	l := log.FromContext(ctx).Named("redis").WithOptions(zap.WithCaller(false)).With("source", log.CallerOutside("/redis/go-redis/"))
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		l.Warnw(msg, fields...)
+	case err != nil:
+		l.Errorw(msg, fields...)
+	case slow:
+		l.Warnw(msg, fields...)
+	default:
+		l.Debugw(msg, fields...)
+	}
+}
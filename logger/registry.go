@@ -0,0 +1,80 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]*instance)
+	unnamed    []*instance // NewLogger 创建的 logger
+)
+
+// New 按 name 创建一个独立的 logger 并注册
+// 每个 logger 拥有各自的输出文件、等级和编码格式
+// 同名 logger 会被替换, 旧 logger 在替换后刷盘并关闭, 之前取得的旧 logger 不应再使用
+// 名称 audit 保留给 LoggerConfig.Audit 创建的审计 logger, 使用该名称会替换审计日志的输出
+func New(name string, conf *LoggerConfig) (*zap.SugaredLogger, error) {
+	ins, err := newLogger(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	registryMu.Lock()
+	prev := registry[name]
+	registry[name] = ins
+	registryMu.Unlock()
+	if prev != nil {
+		// 新 logger 已经生效, 关闭旧 logger 失败不影响返回
+		if err := prev.close(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to close replaced logger %s: %v\n", name, err)
+		}
+	}
+	return ins.sugar, nil
+}
+
+// Get 获取通过 New 注册的 logger, 未注册则 panic
+func Get(name string) *zap.SugaredLogger {
+	registryMu.RLock()
+	ins, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		panic("unknown logger: " + name)
+	}
+
+	return ins.sugar
+}
+
+// Sync 将全局 logger 及所有通过 New、NewLogger 创建的 logger 缓冲的日志刷到输出
+func Sync() error {
+	return eachInstance(func(ins *instance) error { return ins.sync() })
+}
+
+// Close 刷盘并关闭全局 logger 及所有通过 New、NewLogger 创建的 logger 打开的文件, 一般在服务退出时调用
+//
+//	defer log.Close()
+func Close() error {
+	return eachInstance(func(ins *instance) error { return ins.close() })
+}
+
+func eachInstance(fn func(ins *instance) error) error {
+	var err error
+	if std != nil {
+		err = fn(std)
+	}
+
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	for _, ins := range registry {
+		err = multierr.Append(err, fn(ins))
+	}
+	for _, ins := range unnamed {
+		err = multierr.Append(err, fn(ins))
+	}
+	return err
+}
@@ -0,0 +1,67 @@
+package log
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+)
+
+// stdLoad 初始化全局 logger 时使用的配置来源, Reload 时重新调用
+var stdLoad func() (*LoggerConfig, error)
+
+// Reload 重新读取全局 logger 的配置并生效, 通过 InitFromFile 初始化时会重新读取配置文件
+// 可以生效的配置: 日志等级、模块等级、输出目录和文件名(重新打开文件)、BaggageKeys
+// 输出目标、编码格式等其他配置需要重启服务
+func Reload() error {
+	if std == nil || stdLoad == nil {
+		return errors.New("log: reload before init")
+	}
+
+	conf, err := stdLoad()
+	if err != nil {
+		return err
+	}
+	if conf == nil {
+		conf = &LoggerConfig{}
+	}
+	if conf, err = applyEnv(conf); err != nil {
+		return err
+	}
+	setBaggageKeys(conf.BaggageKeys)
+	return multierr.Append(std.reload(conf), reloadAudit(conf))
+}
+
+func (ins *instance) reload(conf *LoggerConfig) error {
+	if conf.Level == LevelOff {
+		// 高于 fatal 的等级, 关闭所有输出
+		ins.level.SetLevel(zapcore.FatalLevel + 1)
+	} else {
+		ins.level.SetLevel(ZapLevel(conf.Level))
+	}
+	ins.modules.set(conf.Levels)
+
+	var err error
+	for _, f := range ins.files {
+		err = multierr.Append(err, f.reopen(conf))
+	}
+	return err
+}
+
+// watchSIGHUP 收到 SIGHUP 时重新加载配置并重新打开日志文件, 配合 logrotate 使用
+func watchSIGHUP() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	go func() {
+		for range ch {
+			if err := Reload(); err != nil {
+				Logger().Errorw("reload log config failed", "error", err)
+				continue
+			}
+			Logger().Infow("log config reloaded")
+		}
+	}()
+}
@@ -0,0 +1,103 @@
+package requestid
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Generator 生成请求 ID
+type Generator func() string
+
+var generator atomic.Value // Generator
+
+// SetGenerator 设置 New 使用的生成方式, 默认 ULID
+func SetGenerator(g Generator) {
+	if g == nil {
+		g = ULID
+	}
+	generator.Store(g)
+}
+
+// New 生成一个请求 ID
+func New() string {
+	if g, ok := generator.Load().(Generator); ok {
+		return g()
+	}
+	return ULID()
+}
+
+// crockford ULID 使用的 Crockford base32 字母表
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULID 生成 26 个字符的 ULID: 48 位毫秒时间戳 + 80 位随机数, 按字符串排序即按时间排序
+func ULID() string {
+	var b [16]byte
+	ms := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+	b[0], b[1], b[2] = byte(ms>>40), byte(ms>>32), byte(ms>>24)
+	b[3], b[4], b[5] = byte(ms>>16), byte(ms>>8), byte(ms)
+	rand.Read(b[6:])
+
+	// 128 位按 5 位一组编码, 最高的 2 位补 0
+	hi, lo := binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+// snowflake 起始时间 2020-01-01 UTC
+const snowflakeEpoch = 1577836800000
+
+// Snowflake 按 snowflake 算法生成十进制 ID: 41 位毫秒时间戳 + 10 位节点号 + 12 位序列号
+// 同一毫秒内序列号用完时等待下一毫秒, 时钟回拨时沿用上次的时间戳
+type Snowflake struct {
+	mu   sync.Mutex
+	node int64
+	last int64
+	seq  int64
+}
+
+// NewSnowflake 创建节点号为 node 的生成器, node 取值 0-1023, 多实例部署时需保证各不相同
+func NewSnowflake(node int64) (*Snowflake, error) {
+	if node < 0 || node > 1023 {
+		return nil, errors.New("snowflake node must be in [0, 1023]")
+	}
+	return &Snowflake{node: node}, nil
+}
+
+// Next 生成下一个 ID
+func (s *Snowflake) Next() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UnixNano()/int64(time.Millisecond) - snowflakeEpoch
+	if now < s.last {
+		now = s.last
+	}
+	if now == s.last {
+		s.seq = (s.seq + 1) & 0xfff
+		if s.seq == 0 {
+			for now <= s.last {
+				time.Sleep(100 * time.Microsecond)
+				now = time.Now().UnixNano()/int64(time.Millisecond) - snowflakeEpoch
+			}
+		}
+	} else {
+		s.seq = 0
+	}
+	s.last = now
+	return now<<22 | s.node<<12 | s.seq
+}
+
+// Generator 用于 SetGenerator
+func (s *Snowflake) Generator() Generator {
+	return func() string { return strconv.FormatInt(s.Next(), 10) }
+}
@@ -0,0 +1,85 @@
+// Package requestid 生成请求 ID 并在 context、HTTP header 和 gRPC metadata 之间传递
+// context 中的请求 ID 由 log.FromContext 自动输出为 request_id 字段
+//
+//	func handler(w http.ResponseWriter, r *http.Request) {
+//		ctx := requestid.FromHTTP(r)          // 取 X-Request-Id, 没有时生成
+//		requestid.SetHeader(ctx, w.Header())  // 返回给调用方
+//		log.FromContext(ctx).Info("handle")   // 带 request_id 字段
+//	}
+package requestid
+
+import (
+	"context"
+	"net/http"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// HeaderName HTTP header 中的请求 ID
+const HeaderName = "X-Request-Id"
+
+// MetadataKey gRPC metadata 中的请求 ID, metadata 的 key 为小写
+const MetadataKey = "x-request-id"
+
+type ctxKey struct{}
+
+// NewContext 返回携带请求 ID 的 context
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext ctx 中的请求 ID, 没有时返回空字符串
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// WithRequestID ctx 中已有请求 ID 时原样返回, 否则生成新的请求 ID
+func WithRequestID(ctx context.Context) context.Context {
+	if FromContext(ctx) != "" {
+		return ctx
+	}
+	return NewContext(ctx, New())
+}
+
+// FromHeader 取 header 中的请求 ID
+func FromHeader(h http.Header) string {
+	return h.Get(HeaderName)
+}
+
+// SetHeader 将 ctx 中的请求 ID 写入 header, 用于发出的请求或返回的响应
+func SetHeader(ctx context.Context, h http.Header) {
+	if id := FromContext(ctx); id != "" {
+		h.Set(HeaderName, id)
+	}
+}
+
+// FromHTTP 返回携带请求 ID 的 r.Context(), 请求中没有 X-Request-Id 时生成
+func FromHTTP(r *http.Request) context.Context {
+	if id := FromHeader(r.Header); id != "" {
+		return NewContext(r.Context(), id)
+	}
+	return WithRequestID(r.Context())
+}
+
+// FromIncoming 返回携带请求 ID 的 context, gRPC 请求的 metadata 中没有请求 ID 时生成
+func FromIncoming(ctx context.Context) context.Context {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(MetadataKey); len(ids) > 0 && ids[0] != "" {
+			return NewContext(ctx, ids[0])
+		}
+	}
+	return WithRequestID(ctx)
+}
+
+// AppendOutgoing 将 ctx 中的请求 ID 加入发出的 gRPC 请求的 metadata
+func AppendOutgoing(ctx context.Context) context.Context {
+	id := FromContext(ctx)
+	if id == "" {
+		return ctx
+	}
+	if md, ok := metadata.FromOutgoingContext(ctx); ok && len(md.Get(MetadataKey)) > 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, MetadataKey, id)
+}
@@ -0,0 +1,44 @@
+package log
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Route 将 [MinLevel, MaxLevel] 区间内的日志写入 Outputs
+// Outputs 取值同 LoggerConfig.Outputs, 多条路由中的同名文件只打开一次, 例如:
+//
+//	routes:
+//	  - {max_level: info, outputs: [app.log]}
+//	  - {min_level: warn, max_level: warn, outputs: [warn.log]}
+//	  - {min_level: error, outputs: [error.log, stderr]}
+type Route struct {
+	MinLevel string   `json:"min_level"` //为空表示不限制
+	MaxLevel string   `json:"max_level"` //为空表示不限制
+	Outputs  []string `json:"outputs"`
+}
+
+func (r Route) enabler() zapcore.LevelEnabler {
+	min, max := zapcore.DebugLevel, zapcore.FatalLevel
+	if r.MinLevel != "" {
+		min = ZapLevel(r.MinLevel)
+	}
+	if r.MaxLevel != "" {
+		max = ZapLevel(r.MaxLevel)
+	}
+	return zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
+		return lvl >= min && lvl <= max
+	})
+}
+
+func newRouteCores(routes []Route, encoder zapcore.Encoder, outs *outputSet) ([]zapcore.Core, error) {
+	cores := make([]zapcore.Core, 0, len(routes))
+	for _, r := range routes {
+		rc, err := outputCores(r.Outputs, encoder, r.enabler(), outs)
+		if err != nil {
+			return nil, err
+		}
+		cores = append(cores, rc...)
+	}
+	return cores, nil
+}
@@ -0,0 +1,47 @@
+package log
+
+import (
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// SamplingConfig 日志采样配置
+// 每秒内同等级同内容的日志先输出 Initial 条, 之后每 Thereafter 条输出一条
+type SamplingConfig struct {
+	Initial    int `json:"initial"`
+	Thereafter int `json:"thereafter"`
+}
+
+// samplerCore 按等级分发到各自的 sampler, 未配置采样的等级不做限制
+type samplerCore struct {
+	zapcore.Core
+	sampled map[zapcore.Level]zapcore.Core
+}
+
+func newSamplerCore(core zapcore.Core, sampling map[string]SamplingConfig) zapcore.Core {
+	if len(sampling) == 0 {
+		return core
+	}
+
+	sampled := make(map[zapcore.Level]zapcore.Core, len(sampling))
+	for level, conf := range sampling {
+		sampled[ZapLevel(level)] = zapcore.NewSamplerWithOptions(core, time.Second, conf.Initial, conf.Thereafter)
+	}
+	return &samplerCore{Core: core, sampled: sampled}
+}
+
+func (c *samplerCore) With(fields []zapcore.Field) zapcore.Core {
+	sampled := make(map[zapcore.Level]zapcore.Core, len(c.sampled))
+	for lvl, core := range c.sampled {
+		sampled[lvl] = core.With(fields)
+	}
+	return &samplerCore{Core: c.Core.With(fields), sampled: sampled}
+}
+
+func (c *samplerCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if core, ok := c.sampled[ent.Level]; ok {
+		return core.Check(ent, ce)
+	}
+	return c.Core.Check(ent, ce)
+}
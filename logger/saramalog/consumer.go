@@ -0,0 +1,232 @@
+package saramalog
+
+import (
+	"context"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IBM/sarama"
+	"go.uber.org/zap"
+
+	log "basic-middle/logger"
+	"basic-middle/logger/requestid"
+)
+
+// Config 消费组 handler 的配置
+type Config struct {
+	LagIntervalMs    int   `json:"lag_interval_ms"`    //记录分区积压的间隔毫秒数, 默认 60000, 小于 0 时不记录
+	LagWarnThreshold int64 `json:"lag_warn_threshold"` //积压超过该值时以 warn 等级记录, 为 0 时不区分
+}
+
+func (c *Config) lagInterval() time.Duration {
+	if c.LagIntervalMs == 0 {
+		return time.Minute
+	}
+	return time.Duration(c.LagIntervalMs) * time.Millisecond
+}
+
+// WrapHandler 包装消费组 handler, 记录每次再均衡后分配到的分区、分区的开始和结束以及积压
+// 积压为分区的 HighWaterMarkOffset 与最后收到的消息之间的消息数
+func WrapHandler(h sarama.ConsumerGroupHandler, conf Config) sarama.ConsumerGroupHandler {
+	return &handler{ConsumerGroupHandler: h, conf: conf}
+}
+
+type handler struct {
+	sarama.ConsumerGroupHandler
+	conf  Config
+	start time.Time
+}
+
+func (h *handler) Setup(sess sarama.ConsumerGroupSession) error {
+	h.start = time.Now()
+	err := h.ConsumerGroupHandler.Setup(sess)
+	fields := []interface{}{
+		zap.String("member_id", sess.MemberID()),
+		zap.Int32("generation_id", sess.GenerationID()),
+		zap.String("claims", claims(sess.Claims())),
+	}
+	l := log.Named("kafka")
+	if err != nil {
+		l.Errorw("kafka consumer group setup failed", append(fields, zap.Error(err))...)
+	} else {
+		l.Infow("kafka consumer group rebalanced", fields...)
+	}
+	return err
+}
+
+func (h *handler) Cleanup(sess sarama.ConsumerGroupSession) error {
+	err := h.ConsumerGroupHandler.Cleanup(sess)
+	fields := []interface{}{
+		zap.String("member_id", sess.MemberID()),
+		zap.Int32("generation_id", sess.GenerationID()),
+		zap.Duration("session", time.Since(h.start)),
+	}
+	l := log.Named("kafka")
+	if err != nil {
+		l.Errorw("kafka consumer group cleanup failed", append(fields, zap.Error(err))...)
+	} else {
+		l.Infow("kafka consumer group session ended", fields...)
+	}
+	return err
+}
+
+// claims 分配到的分区, 如 orders:0,1,2 users:3
+func claims(m map[string][]int32) string {
+	topics := make([]string, 0, len(m))
+	for topic := range m {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	parts := make([]string, len(topics))
+	for i, topic := range topics {
+		ps := append([]int32(nil), m[topic]...)
+		sort.Slice(ps, func(i, j int) bool { return ps[i] < ps[j] })
+		ids := make([]string, len(ps))
+		for j, p := range ps {
+			ids[j] = strconv.Itoa(int(p))
+		}
+		parts[i] = topic + ":" + strings.Join(ids, ",")
+	}
+	return strings.Join(parts, " ")
+}
+
+func (h *handler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	l := log.Named("kafka").With("topic", claim.Topic(), "partition", claim.Partition())
+	l.Infow("kafka claim started", "initial_offset", claim.InitialOffset(), "high_watermark", claim.HighWaterMarkOffset())
+
+	wc := &watchedClaim{ConsumerGroupClaim: claim, msgs: make(chan *sarama.ConsumerMessage), quit: make(chan struct{}), offset: -1}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		wc.forward(sess.Context(), l, &h.conf)
+	}()
+	err := h.ConsumerGroupHandler.ConsumeClaim(sess, wc)
+	// handler 返回后不再读取消息, 停止转发
+	wc.stop()
+	<-done
+
+	fields := []interface{}{zap.Int64("messages", wc.count), zap.Int64("lag", wc.lag())}
+	if err != nil {
+		l.Errorw("kafka claim stopped", append(fields, zap.Error(err))...)
+	} else {
+		l.Infow("kafka claim stopped", fields...)
+	}
+	return err
+}
+
+// watchedClaim 转发分区的消息, 统计消息数并按 LagIntervalMs 记录积压
+type watchedClaim struct {
+	sarama.ConsumerGroupClaim
+	msgs   chan *sarama.ConsumerMessage
+	quit   chan struct{}
+	count  int64
+	offset int64 // 最后转发的消息的 offset, 只在 forward 中写入, forward 结束后读取
+}
+
+func (c *watchedClaim) Messages() <-chan *sarama.ConsumerMessage {
+	return c.msgs
+}
+
+func (c *watchedClaim) lag() int64 {
+	next := c.offset + 1
+	if c.offset < 0 {
+		next = c.InitialOffset()
+	}
+	if lag := c.HighWaterMarkOffset() - next; lag > 0 {
+		return lag
+	}
+	return 0
+}
+
+func (c *watchedClaim) stop() {
+	close(c.quit)
+}
+
+func (c *watchedClaim) forward(ctx context.Context, l *zap.SugaredLogger, conf *Config) {
+	defer close(c.msgs)
+	var tick <-chan time.Time
+	if conf.LagIntervalMs >= 0 {
+		ticker := time.NewTicker(conf.lagInterval())
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case msg, ok := <-c.ConsumerGroupClaim.Messages():
+			if !ok {
+				return
+			}
+			select {
+			case c.msgs <- msg:
+				c.count++
+				c.offset = msg.Offset
+			case <-c.quit:
+				return
+			case <-ctx.Done():
+				return
+			}
+		case <-tick:
+			lag := c.lag()
+			if conf.LagWarnThreshold > 0 && lag > conf.LagWarnThreshold {
+				l.Warnw("kafka claim lag", "lag", lag, "messages", c.count)
+			} else {
+				l.Infow("kafka claim lag", "lag", lag, "messages", c.count)
+			}
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// MessageFunc 处理一条消息, ctx 中的 logger 带有 topic、partition、offset 字段, 消息的 X-Request-Id header 作为请求 ID
+type MessageFunc func(ctx context.Context, msg *sarama.ConsumerMessage) error
+
+// Handler 以 fn 逐条处理分配到的消息的消费组 handler, 并按 WrapHandler 记录
+// fn 返回错误或 panic 时以 error 等级记录, 之后仍然提交该消息的 offset, 需要重试的消息由 fn 自行处理
+func Handler(fn MessageFunc, conf Config) sarama.ConsumerGroupHandler {
+	return WrapHandler(messageHandler(fn), conf)
+}
+
+type messageHandler MessageFunc
+
+func (messageHandler) Setup(sarama.ConsumerGroupSession) error   { return nil }
+func (messageHandler) Cleanup(sarama.ConsumerGroupSession) error { return nil }
+
+func (h messageHandler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for {
+		select {
+		case msg, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+			h.handle(sess.Context(), msg)
+			sess.MarkMessage(msg, "")
+		case <-sess.Context().Done():
+			return nil
+		}
+	}
+}
+
+func (h messageHandler) handle(ctx context.Context, msg *sarama.ConsumerMessage) {
+	for _, hd := range msg.Headers {
+		if hd != nil && textproto.CanonicalMIMEHeaderKey(string(hd.Key)) == requestid.HeaderName {
+			ctx = requestid.NewContext(ctx, string(hd.Value))
+			break
+		}
+	}
+	ctx = log.WithFieldsCtx(ctx, "topic", msg.Topic, "partition", msg.Partition, "offset", msg.Offset)
+	start := time.Now()
+	defer func() {
+		if p := recover(); p != nil {
+			// 调用栈为 defer 的函数 <- runtime.gopanic <- 发生 panic 的函数, 跳过前两层
+			log.FromContext(ctx).Desugar().WithOptions(zap.AddCallerSkip(2)).Error("kafka message panicked",
+				zap.Any("panic", p), zap.Duration("latency", time.Since(start)), zap.StackSkip("stack", 2))
+		}
+	}()
+	if err := h(ctx, msg); err != nil {
+		log.FromContext(ctx).Errorw("kafka message failed", zap.Duration("latency", time.Since(start)), zap.Error(err))
+	}
+}
@@ -0,0 +1,63 @@
+// Package saramalog 将 sarama 的日志写入 logger, 并包装消费组的 handler, 记录分区分配、再均衡、积压和每条消息的处理错误
+// 使用 logger/sink/kafka 时 sarama 自身的日志可能再次写入 kafka, 连接异常时可能循环产生日志, 建议 SetLogger 使用 warn 等级
+//
+//	saramalog.SetLogger("warn", false)
+//	handler := saramalog.Handler(func(ctx context.Context, msg *sarama.ConsumerMessage) error {
+//		log.FromContext(ctx).Infow("got message") // 带有 topic、partition、offset 字段
+//		return nil
+//	}, saramalog.Config{LagWarnThreshold: 10000})
+//	for ctx.Err() == nil {
+//		if err := group.Consume(ctx, topics, handler); err != nil {
+//			log.Logger().Errorw("consume", "err", err)
+//		}
+//	}
+package saramalog
+
+import (
+	"io"
+	stdlog "log"
+
+	"github.com/IBM/sarama"
+	"go.uber.org/zap"
+
+	log "basic-middle/logger"
+)
+
+// SetLogger 将 sarama.Logger 设为全局 logger 的 sarama 子 logger, level 为写入时使用的等级
+// debug 为 true 时 sarama.DebugLogger 的详细日志以 debug 等级写入, 否则丢弃
+func SetLogger(level string, debug bool) error {
+	l := log.Desugared().Named("sarama")
+	std, err := zap.NewStdLogAt(l, log.ZapLevel(level))
+	if err != nil {
+		return err
+	}
+	// sarama.DebugLogger 默认转发到 sarama.Logger, 不开启 debug 时丢弃
+	dbg := stdlog.New(io.Discard, "", 0)
+	if debug {
+		if dbg, err = zap.NewStdLogAt(l, zap.DebugLevel); err != nil {
+			return err
+		}
+	}
+	sarama.Logger, sarama.DebugLogger = std, dbg
+	return nil
+}
+
+// LogProducerErrors 以 error 等级记录 AsyncProducer 发送失败的消息, 需要开启 Producer.Return.Errors, 阻塞到 errs 关闭
+//
+//	go saramalog.LogProducerErrors(producer.Errors())
+func LogProducerErrors(errs <-chan *sarama.ProducerError) {
+	l := log.Named("kafka")
+	for e := range errs {
+		l.Errorw("kafka produce failed", "topic", e.Msg.Topic, "partition", e.Msg.Partition, zap.Error(e.Err))
+	}
+}
+
+// LogConsumerErrors 以 error 等级记录消费组的错误, 需要开启 Consumer.Return.Errors, 阻塞到 errs 关闭
+//
+//	go saramalog.LogConsumerErrors(group.Errors())
+func LogConsumerErrors(errs <-chan error) {
+	l := log.Named("kafka")
+	for err := range errs {
+		l.Errorw("kafka consumer error", zap.Error(err))
+	}
+}
@@ -0,0 +1,164 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// SchemeParser 将 URL 形式的输出转换为 SinkConfig, 如 kafka://broker1:9092,broker2:9092/topic
+type SchemeParser func(u *url.URL) (SinkConfig, error)
+
+var (
+	schemeParsersMu sync.RWMutex
+	schemeParsers   = make(map[string]SchemeParser)
+)
+
+// RegisterScheme 注册输出 URL 的 scheme, 之后 Outputs 和 Route.Outputs 中可以直接使用该 URL, 同名注册会覆盖
+// 未注册的 scheme 与同名的 sink 类型对应, 查询参数作为 sink 的 Options, 见 URLOptions
+func RegisterScheme(scheme string, parse SchemeParser) {
+	schemeParsersMu.Lock()
+	schemeParsers[scheme] = parse
+	schemeParsersMu.Unlock()
+}
+
+// URLOptions 将 URL 的查询参数转换为 sink 的 Options, 数字和 true/false 按 JSON 类型解析, 同名参数出现多次时为数组
+// 查询参数 level 为 SinkConfig.Level, 不包含在 Options 中; extra 覆盖同名的查询参数, 用于各 sink 自行解析的 host 和 path
+func URLOptions(u *url.URL, extra map[string]interface{}) (json.RawMessage, error) {
+	opts := make(map[string]interface{})
+	for k, vs := range u.Query() {
+		if k == "level" {
+			continue
+		}
+		if len(vs) == 1 {
+			opts[k] = queryValue(vs[0])
+			continue
+		}
+		arr := make([]interface{}, 0, len(vs))
+		for _, v := range vs {
+			arr = append(arr, queryValue(v))
+		}
+		opts[k] = arr
+	}
+	for k, v := range extra {
+		opts[k] = v
+	}
+	return json.Marshal(opts)
+}
+
+func queryValue(v string) interface{} {
+	if b, err := strconv.ParseBool(v); err == nil && (v == "true" || v == "false") {
+		return b
+	}
+	if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(v, 64); err == nil {
+		return f
+	}
+	return v
+}
+
+// isURLOutput 输出中带有 scheme 时按 URL 处理
+func isURLOutput(output string) bool {
+	return strings.Contains(output, "://")
+}
+
+// parseOutputURL 解析 URL 形式的 sink 输出
+func parseOutputURL(output string) (SinkConfig, error) {
+	u, err := url.Parse(output)
+	if err != nil {
+		return SinkConfig{}, fmt.Errorf("invalid log output %q: %w", output, err)
+	}
+
+	schemeParsersMu.RLock()
+	parse, ok := schemeParsers[u.Scheme]
+	schemeParsersMu.RUnlock()
+
+	var sc SinkConfig
+	if ok {
+		sc, err = parse(u)
+	} else {
+		sc.Type = u.Scheme
+		sc.Options, err = URLOptions(u, nil)
+	}
+	if err != nil {
+		return SinkConfig{}, fmt.Errorf("invalid log output %q: %w", output, err)
+	}
+	if sc.Level == "" {
+		sc.Level = u.Query().Get("level")
+	}
+	return sc, nil
+}
+
+// fileURLTarget file:///var/log/app/ 为目录, 使用 Filename 作为文件名; file:///var/log/app/demo.log 为完整路径
+func fileURLTarget(output string) (func(conf *LoggerConfig) string, error) {
+	u, err := url.Parse(output)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log output %q: %w", output, err)
+	}
+	path := u.Path
+	if u.Host != "" && u.Host != "localhost" {
+		// file://logs/app.log 视为相对路径
+		path = u.Host + path
+	}
+	if path == "" {
+		return nil, fmt.Errorf("invalid log output %q: empty path", output)
+	}
+	if strings.HasSuffix(path, "/") {
+		return func(c *LoggerConfig) string { return filepath.Join(path, c.Filename) }, nil
+	}
+	return func(*LoggerConfig) string { return path }, nil
+}
+
+// outputCores 为一组输出创建 core: 文件和标准输出合并为一个 core, URL 形式的 sink 各自一个 core
+func outputCores(outputs []string, encoder zapcore.Encoder, enab zapcore.LevelEnabler, outs *outputSet) ([]zapcore.Core, error) {
+	var (
+		plain []string
+		sinks []SinkConfig
+	)
+	for _, output := range outputs {
+		if !isURLOutput(output) || strings.HasPrefix(output, "file://") {
+			plain = append(plain, output)
+			continue
+		}
+		sc, err := parseOutputURL(output)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sc)
+	}
+
+	var cores []zapcore.Core
+	// 只配置了 sink 时不再默认输出到文件
+	if len(plain) > 0 || len(sinks) == 0 {
+		ws, err := outs.syncer(plain)
+		if err != nil {
+			return nil, err
+		}
+		cores = append(cores, zapcore.NewCore(encoder, ws, enab))
+	}
+	for _, sc := range sinks {
+		core, err := openSink(outs.conf, sc)
+		if err != nil {
+			return nil, err
+		}
+		outs.closers = append(outs.closers, core)
+		core.LevelEnabler = andEnabler(core.LevelEnabler, enab)
+		cores = append(cores, core)
+	}
+	return cores, nil
+}
+
+func andEnabler(a, b zapcore.LevelEnabler) zapcore.LevelEnabler {
+	return zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
+		return a.Enabled(lvl) && b.Enabled(lvl)
+	})
+}
@@ -0,0 +1,179 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// SinkConfig 远程日志输出的配置, Type 对应 RegisterSink 注册的名称
+// 具体的 sink 在 logger/sink 下的子包中实现, 通过空导入注册:
+//
+//	import _ "basic-middle/logger/sink/kafka"
+type SinkConfig struct {
+	Type    string          `json:"type"`    //sink 类型, 如 kafka、loki
+	Level   string          `json:"level"`   //该 sink 接收的最低等级, 为空时与全局等级一致或使用 sink 的默认等级
+	Options json.RawMessage `json:"options"` //sink 自身的配置, 由各 sink 解析
+}
+
+// Decode 将 Options 解析到 v, 未配置 Options 时保持 v 的默认值
+func (sc SinkConfig) Decode(v interface{}) error {
+	if len(sc.Options) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(sc.Options, v); err != nil {
+		return fmt.Errorf("invalid %s sink options: %w", sc.Type, err)
+	}
+	return nil
+}
+
+// Sink 接收编码后的日志, line 为 JSON 编码的一行(不含换行符), 调用返回后不再有效
+// Write 会被并发调用, 实现需要自行保证并发安全
+type Sink interface {
+	Write(ent zapcore.Entry, line []byte) error
+	Sync() error
+	Close() error
+}
+
+// sinkLevel Sink 可以实现 DefaultLevel 指定未配置 SinkConfig.Level 时接收的最低等级
+type sinkLevel interface {
+	DefaultLevel() zapcore.Level
+}
+
+// SinkFactory 根据 logger 的配置和 sink 的配置创建 Sink
+type SinkFactory func(conf *LoggerConfig, sc SinkConfig) (Sink, error)
+
+var (
+	sinkFactoriesMu sync.RWMutex
+	sinkFactories   = make(map[string]SinkFactory)
+)
+
+// RegisterSink 注册 sink 类型, 一般在 sink 包的 init 中调用, 同名注册会覆盖
+func RegisterSink(typ string, factory SinkFactory) {
+	sinkFactoriesMu.Lock()
+	sinkFactories[typ] = factory
+	sinkFactoriesMu.Unlock()
+}
+
+func sinkFactory(typ string) (SinkFactory, bool) {
+	sinkFactoriesMu.RLock()
+	defer sinkFactoriesMu.RUnlock()
+	f, ok := sinkFactories[typ]
+	return f, ok
+}
+
+// newSinkCores 按 conf.Sinks 创建各 sink 的 core, 任一创建失败时关闭已创建的 sink
+func newSinkCores(conf *LoggerConfig) ([]zapcore.Core, []io.Closer, error) {
+	var (
+		cores   []zapcore.Core
+		closers []io.Closer
+	)
+	for _, sc := range conf.Sinks {
+		core, err := openSink(conf, sc)
+		if err != nil {
+			closeAll(closers)
+			return nil, nil, err
+		}
+		cores = append(cores, core)
+		closers = append(closers, core)
+	}
+	return cores, closers, nil
+}
+
+// openSink 创建 sink 及其 core
+func openSink(conf *LoggerConfig, sc SinkConfig) (*sinkCore, error) {
+	s, err := NewSink(conf, sc)
+	if err != nil {
+		return nil, err
+	}
+	return newSinkCore(conf, sc, s), nil
+}
+
+// NewSink 按 sc.Type 查找注册的 SinkFactory 创建 Sink, 用于组合其他 sink 的 sink, 如 failover
+// 返回的 Sink 不检查 sc.Level, 由调用方决定写入哪些日志
+func NewSink(conf *LoggerConfig, sc SinkConfig) (Sink, error) {
+	factory, ok := sinkFactory(sc.Type)
+	if !ok {
+		return nil, fmt.Errorf("unknown log sink type: %q, forgot to import basic-middle/logger/sink/%s?", sc.Type, sc.Type)
+	}
+	s, err := factory(conf, sc)
+	if err != nil {
+		return nil, fmt.Errorf("create %s sink: %w", sc.Type, err)
+	}
+	return s, nil
+}
+
+// sinkCore 以 JSON 编码日志后交给 Sink
+type sinkCore struct {
+	zapcore.LevelEnabler
+	name string
+	enc  zapcore.Encoder
+	sink Sink
+}
+
+func newSinkCore(conf *LoggerConfig, sc SinkConfig, s Sink) *sinkCore {
+	var enabler zapcore.LevelEnabler = zapcore.DebugLevel
+	if sc.Level != "" {
+		enabler = ZapLevel(sc.Level)
+	} else if l, ok := s.(sinkLevel); ok {
+		enabler = l.DefaultLevel()
+	}
+	return &sinkCore{
+		LevelEnabler: enabler,
+		name:         sc.Type,
+		enc:          zapcore.NewJSONEncoder(newEncoderConfig(conf)),
+		sink:         s,
+	}
+}
+
+func (c *sinkCore) With(fields []zapcore.Field) zapcore.Core {
+	enc := c.enc.Clone()
+	for i := range fields {
+		fields[i].AddTo(enc)
+	}
+	return &sinkCore{LevelEnabler: c.LevelEnabler, name: c.name, enc: enc, sink: c.sink}
+}
+
+func (c *sinkCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *sinkCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	defer buf.Free()
+
+	line := trimNewline(buf)
+	if err := c.sink.Write(ent, line); err != nil {
+		GetSinkMetrics().Failed(c.name, 1)
+		return err
+	}
+	GetSinkMetrics().Written(c.name, 1, len(line))
+	return nil
+}
+
+func (c *sinkCore) Sync() error {
+	return c.sink.Sync()
+}
+
+func (c *sinkCore) Close() error {
+	return multierr.Append(c.sink.Sync(), c.sink.Close())
+}
+
+func trimNewline(buf *buffer.Buffer) []byte {
+	b := buf.Bytes()
+	if n := len(b); n > 0 && b[n-1] == '\n' {
+		return b[:n-1]
+	}
+	return b
+}
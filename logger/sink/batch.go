@@ -0,0 +1,236 @@
+// Package sink 远程日志 sink 的公共部分: 批量发送、重试和队列满时的处理
+// 具体的 sink 在子包中实现并通过 log.RegisterSink 注册
+package sink
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+
+	log "basic-middle/logger"
+)
+
+// BatchConfig 批量发送配置, 各 sink 的 Options 中内嵌使用
+type BatchConfig struct {
+	BatchSize       int  `json:"batch_size"`        //每批最多条数, 默认 100
+	FlushIntervalMs int  `json:"flush_interval_ms"` //未攒满一批时的发送间隔毫秒数, 默认 1000
+	QueueSize       int  `json:"queue_size"`        //待发送队列长度, 默认 10000
+	BlockWhenFull   bool `json:"block_when_full"`   //队列满时阻塞写入方, 默认丢弃并计入 Dropped, 避免远程服务不可用时拖住所有写日志的 goroutine
+	MaxRetries      int  `json:"max_retries"`       //发送失败的重试次数, 默认 3, 为负数时不重试
+	RetryBackoffMs  int  `json:"retry_backoff_ms"`  //首次重试等待毫秒数, 之后每次翻倍, 默认 500
+}
+
+func (c BatchConfig) withDefaults() BatchConfig {
+	if c.BatchSize <= 0 {
+		c.BatchSize = 100
+	}
+	if c.FlushIntervalMs <= 0 {
+		c.FlushIntervalMs = 1000
+	}
+	if c.QueueSize <= 0 {
+		c.QueueSize = 10000
+	}
+	if c.MaxRetries < 0 {
+		c.MaxRetries = 0
+	} else if c.MaxRetries == 0 {
+		c.MaxRetries = 3
+	}
+	if c.RetryBackoffMs <= 0 {
+		c.RetryBackoffMs = 500
+	}
+	return c
+}
+
+// Record 队列中的一条日志, Line 为 JSON 编码的一行
+type Record struct {
+	Entry zapcore.Entry
+	Line  []byte
+}
+
+// FlushFunc 发送一批日志, 返回错误时按 BatchConfig 重试
+type FlushFunc func(records []Record) error
+
+// Batcher 在后台 goroutine 中攒批发送, 实现了 log.Sink
+type Batcher struct {
+	name    string
+	conf    BatchConfig
+	flush   FlushFunc
+	queue   chan Record
+	syncs   chan chan struct{}
+	done    chan struct{}
+	mu      sync.RWMutex
+	closed  bool
+	dropped int64
+	failed  int64
+	lastErr atomic.Value // sendErr
+}
+
+// sendErr atomic.Value 不能存储 nil, 也要求每次存储的类型相同
+type sendErr struct {
+	err error
+}
+
+// NewBatcher 创建 Batcher 并启动发送 goroutine, name 用于错误输出和 log.SinkMetrics
+func NewBatcher(name string, conf BatchConfig, flush FlushFunc) *Batcher {
+	conf = conf.withDefaults()
+	b := &Batcher{
+		name:  name,
+		conf:  conf,
+		flush: flush,
+		queue: make(chan Record, conf.QueueSize),
+		syncs: make(chan chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+// Write 复制 line 后放入队列, Close 之后的日志丢弃并计入 Dropped
+func (b *Batcher) Write(ent zapcore.Entry, line []byte) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		b.drop()
+		return nil
+	}
+
+	r := Record{Entry: ent, Line: append([]byte(nil), line...)}
+	if b.conf.BlockWhenFull {
+		b.queue <- r
+		return nil
+	}
+	select {
+	case b.queue <- r:
+	default:
+		b.drop()
+	}
+	return nil
+}
+
+func (b *Batcher) drop() {
+	atomic.AddInt64(&b.dropped, 1)
+	log.GetSinkMetrics().Dropped(b.name, 1)
+}
+
+// Sync 发送队列中已有的日志, 发送完成后返回
+func (b *Batcher) Sync() error {
+	ch := make(chan struct{})
+	select {
+	case b.syncs <- ch:
+		<-ch
+	case <-b.done:
+	}
+	return nil
+}
+
+// Close 发送剩余的日志后停止 goroutine, 之后的 Write 丢弃日志
+func (b *Batcher) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		<-b.done
+		return nil
+	}
+	b.closed = true
+	close(b.queue)
+	b.mu.Unlock()
+
+	<-b.done
+	return nil
+}
+
+// Dropped 队列满或 Close 之后被丢弃的日志条数
+func (b *Batcher) Dropped() int64 {
+	return atomic.LoadInt64(&b.dropped)
+}
+
+// Failed 重试后仍发送失败的日志条数
+func (b *Batcher) Failed() int64 {
+	return atomic.LoadInt64(&b.failed)
+}
+
+// Err 最近一批日志重试后仍发送失败的错误, 之后有一批发送成功时恢复为 nil
+func (b *Batcher) Err() error {
+	if v, ok := b.lastErr.Load().(sendErr); ok {
+		return v.err
+	}
+	return nil
+}
+
+func (b *Batcher) run() {
+	defer close(b.done)
+
+	ticker := time.NewTicker(time.Duration(b.conf.FlushIntervalMs) * time.Millisecond)
+	defer ticker.Stop()
+
+	batch := make([]Record, 0, b.conf.BatchSize)
+	send := func() {
+		if len(batch) > 0 {
+			b.send(batch)
+			batch = make([]Record, 0, b.conf.BatchSize)
+		}
+	}
+	for {
+		select {
+		case r, ok := <-b.queue:
+			if !ok {
+				send()
+				return
+			}
+			batch = append(batch, r)
+			if len(batch) >= b.conf.BatchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case ch := <-b.syncs:
+			// 取出 Sync 之前已入队的日志
+			for n := len(b.queue); n > 0; n-- {
+				batch = append(batch, <-b.queue)
+				if len(batch) >= b.conf.BatchSize {
+					send()
+				}
+			}
+			send()
+			close(ch)
+		}
+	}
+}
+
+// retryable 实现了 Retryable 且返回 false 的错误不再重试, 如 *HTTPError 的 400、401
+type retryable interface {
+	Retryable() bool
+}
+
+// send 按指数退避重试, 仍然失败时输出到 stderr 并丢弃该批日志
+func (b *Batcher) send(batch []Record) {
+	metrics := log.GetSinkMetrics()
+	metrics.QueueDepth(b.name, len(b.queue))
+
+	backoff := time.Duration(b.conf.RetryBackoffMs) * time.Millisecond
+	var err error
+	for i := 0; ; i++ {
+		start := time.Now()
+		err = b.flush(batch)
+		metrics.FlushLatency(b.name, time.Since(start))
+		if err == nil {
+			b.lastErr.Store(sendErr{})
+			return
+		}
+		var r retryable
+		if i >= b.conf.MaxRetries || (errors.As(err, &r) && !r.Retryable()) {
+			break
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+	atomic.AddInt64(&b.failed, int64(len(batch)))
+	metrics.Failed(b.name, len(batch))
+	b.lastErr.Store(sendErr{err: err})
+	fmt.Fprintf(os.Stderr, "%s log sink: drop %d entries: %v\n", b.name, len(batch), err)
+}
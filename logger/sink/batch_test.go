@@ -0,0 +1,106 @@
+package sink
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// stalledFlush 每批进入 flush 时通知 entered, 等待 release 后返回
+type stalledFlush struct {
+	entered chan struct{}
+	release chan struct{}
+	mu      sync.Mutex
+	flushed int
+}
+
+func newStalledFlush() *stalledFlush {
+	return &stalledFlush{entered: make(chan struct{}, 16), release: make(chan struct{})}
+}
+
+func (f *stalledFlush) flush(records []Record) error {
+	f.entered <- struct{}{}
+	<-f.release
+	f.mu.Lock()
+	f.flushed += len(records)
+	f.mu.Unlock()
+	return nil
+}
+
+func (f *stalledFlush) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.flushed
+}
+
+// TestBatcherQueueFull 发送阻塞且队列已满时, 默认丢弃并计入 Dropped, BlockWhenFull 时阻塞写入方直到队列有空位
+func TestBatcherQueueFull(t *testing.T) {
+	tests := []struct {
+		name        string
+		block       bool
+		wantDropped int64
+		wantFlushed int
+	}{
+		{"drop when full", false, 1, 2},
+		{"block when full", true, 0, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newStalledFlush()
+			b := NewBatcher("test", BatchConfig{BatchSize: 1, QueueSize: 1, BlockWhenFull: tt.block}, f.flush)
+			ent := zapcore.Entry{Message: "m"}
+
+			// 第一条被取出发送并阻塞在 flush 中, 第二条占满队列
+			b.Write(ent, []byte("1"))
+			<-f.entered
+			b.Write(ent, []byte("2"))
+
+			written := make(chan struct{})
+			go func() {
+				b.Write(ent, []byte("3"))
+				close(written)
+			}()
+			select {
+			case <-written:
+				if tt.block {
+					t.Fatal("Write returned while the queue is full")
+				}
+			case <-time.After(50 * time.Millisecond):
+				if !tt.block {
+					t.Fatal("Write blocked while the queue is full")
+				}
+			}
+
+			close(f.release)
+			<-written
+			if err := b.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if got := b.Dropped(); got != tt.wantDropped {
+				t.Fatalf("Dropped = %d, want %d", got, tt.wantDropped)
+			}
+			if got := f.count(); got != tt.wantFlushed {
+				t.Fatalf("flushed = %d, want %d", got, tt.wantFlushed)
+			}
+		})
+	}
+}
+
+// TestBatcherWriteAfterClose Close 之后的 Write 不阻塞, 丢弃并计入 Dropped
+func TestBatcherWriteAfterClose(t *testing.T) {
+	for _, block := range []bool{false, true} {
+		var flushed int
+		b := NewBatcher("test", BatchConfig{BlockWhenFull: block}, func(records []Record) error {
+			flushed += len(records)
+			return nil
+		})
+		b.Write(zapcore.Entry{}, []byte("before"))
+		b.Close()
+		b.Write(zapcore.Entry{}, []byte("after"))
+		if b.Dropped() != 1 || flushed != 1 {
+			t.Fatalf("block_when_full=%v: Dropped = %d, flushed = %d, want 1 and 1", block, b.Dropped(), flushed)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+// Package cloudwatch 将日志写入 AWS CloudWatch Logs, 空导入后在 LoggerConfig.Sinks 中配置 type 为 cloudwatch
+// 凭证按 aws sdk 的默认顺序获取: 环境变量、共享配置文件、ECS/Lambda 的任务角色、EC2 实例角色
+//
+//	sinks:
+//	  - type: cloudwatch
+//	    options: {region: ap-southeast-1, create: true}
+package cloudwatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+
+	log "basic-middle/logger"
+	"basic-middle/logger/sink"
+)
+
+func init() {
+	log.RegisterSink("cloudwatch", New)
+}
+
+// Options cloudwatch sink 的配置
+type Options struct {
+	sink.BatchConfig
+	Region    string `json:"region"`     //区域, 默认使用 aws sdk 的默认配置
+	Endpoint  string `json:"endpoint"`   //自定义地址, 如 localstack
+	LogGroup  string `json:"log_group"`  //日志组, 默认 /<namespace>/<project>
+	LogStream string `json:"log_stream"` //日志流, 默认 <hostname>-<pid>
+	Create    bool   `json:"create"`     //日志组和日志流不存在时自动创建
+}
+
+// PutLogEvents 单次请求的上限: 10000 条, 1MB(每条日志额外按 26 字节计算)
+const (
+	maxBatchEvents = 10000
+	maxBatchBytes  = 1048576
+	eventOverhead  = 26
+)
+
+// New 按 sc.Options 创建 cloudwatch sink
+func New(conf *log.LoggerConfig, sc log.SinkConfig) (log.Sink, error) {
+	var opts Options
+	if err := sc.Decode(&opts); err != nil {
+		return nil, err
+	}
+	if opts.LogGroup == "" {
+		opts.LogGroup = defaultGroup(conf)
+	}
+	if opts.LogStream == "" {
+		host, _ := os.Hostname()
+		opts.LogStream = host + "-" + strconv.Itoa(os.Getpid())
+	}
+	if opts.BatchSize <= 0 || opts.BatchSize > maxBatchEvents {
+		opts.BatchSize = maxBatchEvents
+	}
+
+	ctx := context.Background()
+	var loadOpts []func(*config.LoadOptions) error
+	if opts.Region != "" {
+		loadOpts = append(loadOpts, config.WithRegion(opts.Region))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, loadOpts...)
+	if err != nil {
+		return nil, err
+	}
+	client := cloudwatchlogs.NewFromConfig(cfg, func(o *cloudwatchlogs.Options) {
+		if opts.Endpoint != "" {
+			o.BaseEndpoint = aws.String(opts.Endpoint)
+		}
+	})
+
+	w := &writer{client: client, group: opts.LogGroup, stream: opts.LogStream}
+	if opts.Create {
+		if err := w.create(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return sink.NewBatcher("cloudwatch", opts.BatchConfig, w.put), nil
+}
+
+// defaultGroup /<namespace>/<project>, 都为空时为 /app
+func defaultGroup(conf *log.LoggerConfig) string {
+	var parts []string
+	for _, p := range []string{conf.Namespace, conf.Project} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	if len(parts) == 0 {
+		return "/app"
+	}
+	return "/" + strings.Join(parts, "/")
+}
+
+// writer 只在 Batcher 的发送 goroutine 中调用, sequence token 无需加锁
+type writer struct {
+	client *cloudwatchlogs.Client
+	group  string
+	stream string
+	token  *string
+}
+
+func (w *writer) create(ctx context.Context) error {
+	var exists *types.ResourceAlreadyExistsException
+	_, err := w.client.CreateLogGroup(ctx, &cloudwatchlogs.CreateLogGroupInput{LogGroupName: aws.String(w.group)})
+	if err != nil && !errors.As(err, &exists) {
+		return fmt.Errorf("create log group %s: %w", w.group, err)
+	}
+	_, err = w.client.CreateLogStream(ctx, &cloudwatchlogs.CreateLogStreamInput{
+		LogGroupName:  aws.String(w.group),
+		LogStreamName: aws.String(w.stream),
+	})
+	if err != nil && !errors.As(err, &exists) {
+		return fmt.Errorf("create log stream %s: %w", w.stream, err)
+	}
+	return nil
+}
+
+// put 按时间排序后按条数和大小上限拆分为多次请求
+func (w *writer) put(records []sink.Record) error {
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Entry.Time.Before(records[j].Entry.Time) })
+
+	var (
+		events []types.InputLogEvent
+		size   int
+	)
+	for _, r := range records {
+		n := len(r.Line) + eventOverhead
+		if len(events) > 0 && (len(events) >= maxBatchEvents || size+n > maxBatchBytes) {
+			if err := w.putEvents(events); err != nil {
+				return err
+			}
+			events, size = nil, 0
+		}
+		events = append(events, types.InputLogEvent{
+			Message:   aws.String(string(r.Line)),
+			Timestamp: aws.Int64(r.Entry.Time.UnixNano() / 1e6),
+		})
+		size += n
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	return w.putEvents(events)
+}
+
+// putEvents 旧版接口要求 sequence token, token 不匹配时使用返回的 token 重试一次
+func (w *writer) putEvents(events []types.InputLogEvent) error {
+	input := &cloudwatchlogs.PutLogEventsInput{
+		LogGroupName:  aws.String(w.group),
+		LogStreamName: aws.String(w.stream),
+		LogEvents:     events,
+		SequenceToken: w.token,
+	}
+	out, err := w.client.PutLogEvents(context.Background(), input)
+	var invalid *types.InvalidSequenceTokenException
+	if errors.As(err, &invalid) {
+		input.SequenceToken = invalid.ExpectedSequenceToken
+		out, err = w.client.PutLogEvents(context.Background(), input)
+	}
+	if err != nil {
+		return err
+	}
+	w.token = out.NextSequenceToken
+	if info := out.RejectedLogEventsInfo; info != nil {
+		fmt.Fprintf(os.Stderr, "cloudwatch log sink: rejected events: too old before %d, too new from %d, expired before %d\n",
+			aws.ToInt32(info.TooOldLogEventEndIndex), aws.ToInt32(info.TooNewLogEventStartIndex), aws.ToInt32(info.ExpiredLogEventEndIndex))
+	}
+	return nil
+}
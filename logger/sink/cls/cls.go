@@ -0,0 +1,134 @@
+// Package cls 通过腾讯云日志服务的 producer 将日志写入 CLS 日志主题, 空导入后在 LoggerConfig.Sinks 中配置 type 为 cls
+// 批量、压缩和失败重试由 producer 完成
+//
+//	sinks:
+//	  - type: cls
+//	    options: {endpoint: ap-guangzhou.cls.tencentcs.com, topic_id: xxx, secret_id: xxx, secret_key: xxx}
+package cls
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+
+	cls "github.com/tencentcloud/tencentcloud-cls-sdk-go"
+	"go.uber.org/zap/zapcore"
+
+	log "basic-middle/logger"
+	"basic-middle/logger/sink"
+)
+
+func init() {
+	log.RegisterSink("cls", New)
+}
+
+// Options cls sink 的配置
+type Options struct {
+	Endpoint      string `json:"endpoint"`        //服务入口, 如 ap-guangzhou.cls.tencentcs.com, 同地域可使用内网入口
+	TopicID       string `json:"topic_id"`        //日志主题 id
+	SecretID      string `json:"secret_id"`       //SecretId
+	SecretKey     string `json:"secret_key"`      //SecretKey
+	Token         string `json:"token"`           //使用临时密钥时的 token
+	Source        string `json:"source"`          //日志来源, 默认本机 hostname
+	Compression   string `json:"compression"`     //压缩方式 lz4|zstd, 默认 lz4
+	LingerMs      int64  `json:"linger_ms"`       //一批日志的最长等待毫秒数, 默认 2000
+	MaxBatchCount int    `json:"max_batch_count"` //每批最多条数, 默认 4096
+	Retries       int    `json:"retries"`         //发送失败的重试次数, 默认 10
+	MaxBlockSec   int    `json:"max_block_sec"`   //缓冲区满时写入方最多阻塞的秒数, 超时后丢弃, 默认 60
+}
+
+// Sink 使用 cls 的异步 producer 发送
+type Sink struct {
+	topicID  string
+	producer *cls.AsyncProducerClient
+	failed   int64
+}
+
+// New 按 sc.Options 创建 cls sink
+func New(_ *log.LoggerConfig, sc log.SinkConfig) (log.Sink, error) {
+	var opts Options
+	if err := sc.Decode(&opts); err != nil {
+		return nil, err
+	}
+	if opts.Endpoint == "" || opts.TopicID == "" {
+		return nil, errors.New("endpoint and topic_id are required")
+	}
+	if opts.Source == "" {
+		opts.Source, _ = os.Hostname()
+	}
+
+	pc := cls.GetDefaultAsyncProducerClientConfig()
+	pc.Endpoint = opts.Endpoint
+	pc.AccessKeyID = opts.SecretID
+	pc.AccessKeySecret = opts.SecretKey
+	pc.AccessToken = opts.Token
+	pc.Source = opts.Source
+	pc.CompressType = opts.Compression
+	if opts.LingerMs > 0 {
+		pc.LingerMs = opts.LingerMs
+	}
+	if opts.MaxBatchCount > 0 {
+		pc.MaxBatchCount = opts.MaxBatchCount
+	}
+	if opts.Retries > 0 {
+		pc.Retries = opts.Retries
+	}
+	if opts.MaxBlockSec > 0 {
+		pc.MaxBlockSec = opts.MaxBlockSec
+	}
+
+	p, err := cls.NewAsyncProducerClient(pc)
+	if err != nil {
+		return nil, err
+	}
+	p.Start()
+	return &Sink{topicID: opts.TopicID, producer: p}, nil
+}
+
+// Write 日志的每个字段作为 cls 的一个 key, 非字符串的值编码为 JSON
+func (s *Sink) Write(ent zapcore.Entry, line []byte) error {
+	record, err := sink.DecodeLine(line)
+	if err != nil {
+		return err
+	}
+	contents := make(map[string]string, len(record))
+	for k, v := range record {
+		if str, ok := v.(string); ok {
+			contents[k] = str
+			continue
+		}
+		b, _ := json.Marshal(v)
+		contents[k] = string(b)
+	}
+
+	l := cls.NewCLSLog(ent.Time.UnixNano()/1e6, contents)
+	return s.producer.SendLog(s.topicID, l, (*callback)(s))
+}
+
+// Sync producer 按 LingerMs 定时发送, 没有主动刷新的接口
+func (s *Sink) Sync() error {
+	return nil
+}
+
+// Close 最多等待 10 秒发送剩余的日志
+func (s *Sink) Close() error {
+	return s.producer.Close(10000)
+}
+
+// Failed producer 重试后仍发送失败的日志条数
+func (s *Sink) Failed() int64 {
+	return atomic.LoadInt64(&s.failed)
+}
+
+// callback 重试后仍失败的日志输出到 stderr
+type callback Sink
+
+func (*callback) Success(*cls.Result) {}
+
+func (c *callback) Fail(r *cls.Result) {
+	atomic.AddInt64(&c.failed, 1)
+	log.GetSinkMetrics().Failed("cls", 1)
+	fmt.Fprintf(os.Stderr, "cls log sink: %s: %s, request id %s\n", r.GetErrorCode(), r.GetErrorMessage(), r.GetRequestId())
+}
@@ -0,0 +1,126 @@
+// Package datadog 通过 Datadog logs HTTP intake 批量上报日志, 空导入后在 LoggerConfig.Sinks 中配置 type 为 datadog
+//
+//	sinks:
+//	  - type: datadog
+//	    options: {api_key: xxx, site: datadoghq.com, service: demo, tags: "env:prod,team:pay"}
+package datadog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+
+	log "basic-middle/logger"
+	"basic-middle/logger/sink"
+)
+
+func init() {
+	log.RegisterSink("datadog", New)
+}
+
+// Options datadog sink 的配置, 429 和 5xx 按 BatchConfig 指数退避重试
+type Options struct {
+	sink.BatchConfig
+	APIKey    string `json:"api_key"`    //Datadog API key
+	Site      string `json:"site"`       //站点, 如 datadoghq.com、datadoghq.eu, 默认 datadoghq.com
+	URL       string `json:"url"`        //完整的 intake 地址, 设置后忽略 site
+	Service   string `json:"service"`    //service, 默认 project
+	Source    string `json:"source"`     //ddsource, 默认 go
+	Tags      string `json:"tags"`       //ddtags, 逗号分隔的 key:value
+	Hostname  string `json:"hostname"`   //hostname, 默认本机 hostname
+	NoGzip    bool   `json:"no_gzip"`    //不压缩请求体, 默认 gzip
+	TimeoutMs int    `json:"timeout_ms"` //请求超时毫秒数, 默认 10000
+}
+
+// intake 单次请求的上限为 1000 条
+const maxBatchSize = 1000
+
+// New 按 sc.Options 创建 datadog sink
+func New(conf *log.LoggerConfig, sc log.SinkConfig) (log.Sink, error) {
+	var opts Options
+	if err := sc.Decode(&opts); err != nil {
+		return nil, err
+	}
+	if opts.APIKey == "" {
+		return nil, errors.New("api_key is required")
+	}
+	if opts.URL == "" {
+		site := opts.Site
+		if site == "" {
+			site = "datadoghq.com"
+		}
+		opts.URL = "https://http-intake.logs." + site + "/api/v2/logs"
+	}
+	if opts.Service == "" {
+		opts.Service = conf.Project
+	}
+	if opts.Source == "" {
+		opts.Source = "go"
+	}
+	if opts.Hostname == "" {
+		opts.Hostname, _ = os.Hostname()
+	}
+	if opts.BatchSize <= 0 || opts.BatchSize > maxBatchSize {
+		opts.BatchSize = maxBatchSize
+	}
+
+	i := &intake{opts: opts, keys: conf.Keys.WithDefaults(), client: sink.NewHTTPClient(opts.TimeoutMs)}
+	return sink.NewBatcher("datadog", opts.BatchConfig, i.send), nil
+}
+
+type intake struct {
+	opts   Options
+	keys   log.EncoderKeys
+	client *http.Client
+}
+
+// send 每条日志保留原有字段, 并补充 datadog 的保留属性: message、status、service、ddsource、ddtags、hostname
+func (i *intake) send(records []sink.Record) error {
+	entries := make([]map[string]interface{}, 0, len(records))
+	for _, r := range records {
+		entry, err := sink.DecodeLine(r.Line)
+		if err != nil {
+			continue
+		}
+		delete(entry, i.keys.Message)
+		delete(entry, i.keys.Level)
+		entry["message"] = r.Entry.Message
+		entry["status"] = r.Entry.Level.String()
+		entry["service"] = i.opts.Service
+		entry["ddsource"] = i.opts.Source
+		entry["hostname"] = i.opts.Hostname
+		if i.opts.Tags != "" {
+			entry["ddtags"] = i.opts.Tags
+		}
+		entries = append(entries, entry)
+	}
+
+	var body bytes.Buffer
+	if i.opts.NoGzip {
+		if err := json.NewEncoder(&body).Encode(entries); err != nil {
+			return err
+		}
+	} else {
+		zw := gzip.NewWriter(&body)
+		if err := json.NewEncoder(zw).Encode(entries); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodPost, i.opts.URL, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("DD-API-KEY", i.opts.APIKey)
+	if !i.opts.NoGzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	return sink.Do(i.client, req)
+}
@@ -0,0 +1,135 @@
+// Package elasticsearch 通过 bulk API 将日志写入 Elasticsearch, 空导入后在 LoggerConfig.Sinks 中配置 type 为 elasticsearch
+//
+//	sinks:
+//	  - type: elasticsearch
+//	    options: {url: "http://es:9200", batch_size: 500, flush_interval_ms: 2000}
+package elasticsearch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	log "basic-middle/logger"
+	"basic-middle/logger/sink"
+)
+
+func init() {
+	log.RegisterSink("elasticsearch", New)
+}
+
+// Options elasticsearch sink 的配置
+// 写入队列满时默认丢弃, 配置 block_when_full 后阻塞写入方; es 返回 429 时按 BatchConfig 重试
+type Options struct {
+	sink.BatchConfig
+	URL         string `json:"url"`          //es 地址, 如 http://es:9200
+	IndexPrefix string `json:"index_prefix"` //索引前缀, 默认 logs-<project>-, 实际索引为前缀加日期
+	IndexDate   string `json:"index_date"`   //索引日期的 Go 时间 layout, 默认 2006.01.02
+	Username    string `json:"username"`     //basic auth 用户名
+	Password    string `json:"password"`     //basic auth 密码
+	APIKey      string `json:"api_key"`      //使用 ApiKey 认证时的 key
+	TimeoutMs   int    `json:"timeout_ms"`   //请求超时毫秒数, 默认 10000
+}
+
+// New 按 sc.Options 创建 elasticsearch sink
+func New(conf *log.LoggerConfig, sc log.SinkConfig) (log.Sink, error) {
+	var opts Options
+	if err := sc.Decode(&opts); err != nil {
+		return nil, err
+	}
+	if opts.URL == "" {
+		return nil, errors.New("url is required")
+	}
+	if opts.IndexPrefix == "" {
+		opts.IndexPrefix = "logs-"
+		if conf.Project != "" {
+			opts.IndexPrefix += conf.Project + "-"
+		}
+	}
+	if opts.IndexDate == "" {
+		opts.IndexDate = "2006.01.02"
+	}
+
+	b := &bulker{
+		url:    strings.TrimRight(opts.URL, "/") + "/_bulk",
+		opts:   opts,
+		client: sink.NewHTTPClient(opts.TimeoutMs),
+	}
+	return sink.NewBatcher("elasticsearch", opts.BatchConfig, b.bulk), nil
+}
+
+type bulker struct {
+	url    string
+	opts   Options
+	client *http.Client
+}
+
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int             `json:"status"`
+		Error  json.RawMessage `json:"error"`
+	} `json:"items"`
+}
+
+// bulk 一次请求写入一批日志, 每条日志附加 @timestamp 便于 es 识别时间
+// 部分文档写入失败时只输出到 stderr 不重试, 避免重复写入已成功的文档
+func (b *bulker) bulk(records []sink.Record) error {
+	var body bytes.Buffer
+	for _, r := range records {
+		index := b.opts.IndexPrefix + r.Entry.Time.Format(b.opts.IndexDate)
+		fmt.Fprintf(&body, `{"index":{"_index":%q}}`+"\n", index)
+		body.WriteString(`{"@timestamp":"`)
+		body.WriteString(r.Entry.Time.Format(time.RFC3339Nano))
+		body.WriteString(`"`)
+		if len(r.Line) > 2 {
+			body.WriteByte(',')
+		}
+		body.Write(r.Line[1:])
+		body.WriteByte('\n')
+	}
+
+	req, err := http.NewRequest(http.MethodPost, b.url, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if b.opts.APIKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+b.opts.APIKey)
+	} else if b.opts.Username != "" {
+		req.SetBasicAuth(b.opts.Username, b.opts.Password)
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("bulk: %s", resp.Status)
+	}
+
+	var result bulkResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil || !result.Errors {
+		return nil
+	}
+	failed := 0
+	var first json.RawMessage
+	for _, item := range result.Items {
+		for _, r := range item {
+			if r.Status/100 != 2 {
+				failed++
+				if first == nil {
+					first = r.Error
+				}
+			}
+		}
+	}
+	fmt.Fprintf(os.Stderr, "elasticsearch log sink: %d of %d documents failed: %s\n", failed, len(records), first)
+	return nil
+}
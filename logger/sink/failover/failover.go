@@ -0,0 +1,195 @@
+// Package failover 组合主备两个 sink, 主 sink 连续出错时切换到备用 sink, 并定期探测主 sink 恢复后切换回来
+// 空导入后在 LoggerConfig.Sinks 中配置 type 为 failover, primary 和 secondary 的 type 需要同样空导入
+//
+// 主 sink 异步发送时(基于 sink.Batcher 的 sink), 以 Err 判断最近一批是否在重试后仍然失败
+// 切换期间每个探测间隔将最近的一条日志额外写入主 sink 并 Sync, 成功后切换回来, 因此主 sink 会多收到少量重复的日志
+//
+//	sinks:
+//	  - type: failover
+//	    options:
+//	      primary: {type: kafka, options: {brokers: ["kafka:9092"], topic: app-logs}}
+//	      secondary: {type: file, options: {path: kafka-fallback.log}}
+package failover
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+
+	log "basic-middle/logger"
+)
+
+func init() {
+	log.RegisterSink("failover", New)
+}
+
+// Options failover sink 的配置
+type Options struct {
+	Primary         log.SinkConfig `json:"primary"`           //主 sink
+	Secondary       log.SinkConfig `json:"secondary"`         //备用 sink
+	MaxFailures     int            `json:"max_failures"`      //主 sink 连续写入出错多少次后切换, 默认 3
+	ProbeIntervalMs int            `json:"probe_interval_ms"` //切换后探测主 sink 的间隔毫秒数, 默认 10000
+}
+
+// errReporter sink 异步发送时通过 Err 报告发送失败, 如 sink.Batcher
+type errReporter interface {
+	Err() error
+}
+
+// New 按 sc.Options 创建主备 sink
+func New(conf *log.LoggerConfig, sc log.SinkConfig) (log.Sink, error) {
+	var opts Options
+	if err := sc.Decode(&opts); err != nil {
+		return nil, err
+	}
+	if opts.Primary.Type == "" || opts.Secondary.Type == "" {
+		return nil, errors.New("primary and secondary are required")
+	}
+	if opts.MaxFailures <= 0 {
+		opts.MaxFailures = 3
+	}
+	if opts.ProbeIntervalMs <= 0 {
+		opts.ProbeIntervalMs = 10000
+	}
+
+	primary, err := log.NewSink(conf, opts.Primary)
+	if err != nil {
+		return nil, err
+	}
+	secondary, err := log.NewSink(conf, opts.Secondary)
+	if err != nil {
+		primary.Close()
+		return nil, err
+	}
+
+	s := &Sink{
+		primary:     primary,
+		secondary:   secondary,
+		name:        opts.Primary.Type,
+		maxFailures: int32(opts.MaxFailures),
+		interval:    time.Duration(opts.ProbeIntervalMs) * time.Millisecond,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go s.probe()
+	return s, nil
+}
+
+// Sink 主备切换的 sink
+type Sink struct {
+	primary     log.Sink
+	secondary   log.Sink
+	name        string
+	maxFailures int32
+	interval    time.Duration
+
+	failures int32 // 主 sink 连续写入出错的次数
+	failed   int32 // 1 表示已切换到备用 sink
+
+	mu   sync.Mutex
+	last *record // 切换期间最近写入备用 sink 的一条日志, 用于探测
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+type record struct {
+	ent  zapcore.Entry
+	line []byte
+}
+
+// Failover 当前是否已切换到备用 sink
+func (s *Sink) Failover() bool {
+	return atomic.LoadInt32(&s.failed) == 1
+}
+
+func (s *Sink) Write(ent zapcore.Entry, line []byte) error {
+	if !s.Failover() {
+		err := s.primary.Write(ent, line)
+		if err == nil {
+			err = asyncErr(s.primary)
+		}
+		if err == nil {
+			atomic.StoreInt32(&s.failures, 0)
+			return nil
+		}
+		// 未达到切换条件时本条日志也写入备用 sink, 避免丢失
+		if atomic.AddInt32(&s.failures, 1) < s.maxFailures && asyncErr(s.primary) == nil {
+			return s.secondary.Write(ent, line)
+		}
+		if atomic.CompareAndSwapInt32(&s.failed, 0, 1) {
+			fmt.Fprintf(os.Stderr, "failover log sink: %s failed, switch to secondary: %v\n", s.name, err)
+		}
+	}
+
+	s.mu.Lock()
+	s.last = &record{ent: ent, line: append([]byte(nil), line...)}
+	s.mu.Unlock()
+	return s.secondary.Write(ent, line)
+}
+
+// asyncErr 异步发送的 sink 最近一批是否发送失败
+func asyncErr(s log.Sink) error {
+	if r, ok := s.(errReporter); ok {
+		return r.Err()
+	}
+	return nil
+}
+
+// probe 切换后定期将最近一条日志写入主 sink, 写入和 Sync 都成功且没有发送失败时切换回主 sink
+func (s *Sink) probe() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.stop:
+			return
+		}
+		if !s.Failover() {
+			continue
+		}
+		s.mu.Lock()
+		r := s.last
+		s.mu.Unlock()
+		if r == nil {
+			continue
+		}
+
+		err := s.primary.Write(r.ent, r.line)
+		if err == nil {
+			err = s.primary.Sync()
+		}
+		if err == nil {
+			err = asyncErr(s.primary)
+		}
+		if err != nil {
+			continue
+		}
+		atomic.StoreInt32(&s.failures, 0)
+		atomic.StoreInt32(&s.failed, 0)
+		fmt.Fprintf(os.Stderr, "failover log sink: %s recovered, switch back to primary\n", s.name)
+	}
+}
+
+func (s *Sink) Sync() error {
+	return multierr.Append(s.primary.Sync(), s.secondary.Sync())
+}
+
+func (s *Sink) Close() error {
+	s.once.Do(func() { close(s.stop) })
+	<-s.done
+	return multierr.Combine(
+		s.primary.Sync(), s.primary.Close(),
+		s.secondary.Sync(), s.secondary.Close(),
+	)
+}
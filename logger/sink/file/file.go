@@ -0,0 +1,82 @@
+// Package file 将 JSON 行日志写入本地文件并按大小轮转, 空导入后在 LoggerConfig.Sinks 中配置 type 为 file
+// 主要作为 failover 的备用输出, 远程 sink 不可用时日志先落盘
+//
+//	sinks:
+//	  - type: file
+//	    options: {path: fallback.log, max_size_mb: 100, max_backups: 10}
+package file
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+
+	log "basic-middle/logger"
+)
+
+func init() {
+	log.RegisterSink("file", New)
+}
+
+// Options file sink 的配置
+type Options struct {
+	Path       string `json:"path"`         //文件路径, 相对路径时位于 OutPutDir 下
+	MaxSizeMB  int    `json:"max_size_mb"`  //单个文件的最大 MB 数, 默认 100
+	MaxBackups int    `json:"max_backups"`  //保留的旧文件个数, 默认 10
+	MaxAgeDays int    `json:"max_age_days"` //旧文件保留天数, 默认不按时间清理
+	Compress   bool   `json:"compress"`     //旧文件 gzip 压缩
+}
+
+// New 按 sc.Options 创建 file sink
+func New(conf *log.LoggerConfig, sc log.SinkConfig) (log.Sink, error) {
+	var opts Options
+	if err := sc.Decode(&opts); err != nil {
+		return nil, err
+	}
+	if opts.Path == "" {
+		return nil, errors.New("path is required")
+	}
+	if !filepath.IsAbs(opts.Path) {
+		opts.Path = filepath.Join(conf.OutPutDir, opts.Path)
+	}
+	if opts.MaxSizeMB <= 0 {
+		opts.MaxSizeMB = 100
+	}
+	if opts.MaxBackups <= 0 {
+		opts.MaxBackups = 10
+	}
+	return &Sink{w: &lumberjack.Logger{
+		Filename:   opts.Path,
+		MaxSize:    opts.MaxSizeMB,
+		MaxBackups: opts.MaxBackups,
+		MaxAge:     opts.MaxAgeDays,
+		LocalTime:  true,
+		Compress:   opts.Compress,
+	}}, nil
+}
+
+// Sink 同步写入文件, 写入失败时返回错误
+type Sink struct {
+	mu  sync.Mutex
+	w   *lumberjack.Logger
+	buf []byte
+}
+
+func (s *Sink) Write(_ zapcore.Entry, line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buf = append(append(s.buf[:0], line...), '\n')
+	_, err := s.w.Write(s.buf)
+	return err
+}
+
+func (s *Sink) Sync() error {
+	return nil
+}
+
+func (s *Sink) Close() error {
+	return s.w.Close()
+}
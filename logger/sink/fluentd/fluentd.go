@@ -0,0 +1,123 @@
+// Package fluentd 通过 forward 协议(msgpack over TCP)将日志发送到 fluentd 或 fluent-bit
+// 空导入后在 LoggerConfig.Sinks 中配置 type 为 fluentd
+//
+//	sinks:
+//	  - type: fluentd
+//	    options: {host: 127.0.0.1, port: 24224}
+package fluentd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fluent/fluent-logger-golang/fluent"
+	"go.uber.org/zap/zapcore"
+
+	log "basic-middle/logger"
+	"basic-middle/logger/sink"
+)
+
+func init() {
+	log.RegisterSink("fluentd", New)
+}
+
+// Options fluentd sink 的配置
+type Options struct {
+	Host         string `json:"host"`           //fluentd 地址, 默认 127.0.0.1
+	Port         int    `json:"port"`           //fluentd 端口, 默认 24224
+	SocketPath   string `json:"socket_path"`    //使用 unix socket 时的路径, 设置后忽略 host 和 port
+	Tag          string `json:"tag"`            //tag, 默认 namespace.project
+	TagWithLevel bool   `json:"tag_with_level"` //tag 后追加 .level, 便于在 fluentd 中按等级路由
+	BufferLimit  int    `json:"buffer_limit"`   //异步发送的缓冲区条数, 默认 8192
+	TimeoutMs    int    `json:"timeout_ms"`     //连接和写入超时毫秒数, 默认 3000
+	RequestAck   bool   `json:"request_ack"`    //要求 fluentd 回复确认, 提高可靠性
+}
+
+// Sink 使用 fluent-logger-golang 的异步模式发送, 断线重连和重试由其完成
+type Sink struct {
+	tag          string
+	tagWithLevel bool
+	fluent       *fluent.Fluent
+}
+
+// New 按 sc.Options 创建 fluentd sink
+func New(conf *log.LoggerConfig, sc log.SinkConfig) (log.Sink, error) {
+	var opts Options
+	if err := sc.Decode(&opts); err != nil {
+		return nil, err
+	}
+
+	tag := opts.Tag
+	if tag == "" {
+		tag = defaultTag(conf)
+	}
+	timeout := time.Duration(opts.TimeoutMs) * time.Millisecond
+	if timeout <= 0 {
+		timeout = 3 * time.Second
+	}
+	cfg := fluent.Config{
+		FluentHost:         opts.Host,
+		FluentPort:         opts.Port,
+		Timeout:            timeout,
+		WriteTimeout:       timeout,
+		BufferLimit:        opts.BufferLimit,
+		Async:              true,
+		RequestAck:         opts.RequestAck,
+		SubSecondPrecision: true,
+		AsyncResultCallback: func(_ []byte, err error) {
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "fluentd log sink: %v\n", err)
+			}
+		},
+	}
+	if opts.SocketPath != "" {
+		cfg.FluentNetwork = "unix"
+		cfg.FluentSocketPath = opts.SocketPath
+	}
+
+	f, err := fluent.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Sink{tag: tag, tagWithLevel: opts.TagWithLevel, fluent: f}, nil
+}
+
+// defaultTag namespace.project, 都为空时为 app
+func defaultTag(conf *log.LoggerConfig) string {
+	var parts []string
+	for _, p := range []string{conf.Namespace, conf.Project} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	if len(parts) == 0 {
+		return "app"
+	}
+	return strings.Join(parts, ".")
+}
+
+// Write 将 JSON 解析为 map 后以 msgpack 发送, 数字保持原样不转为 float
+func (s *Sink) Write(ent zapcore.Entry, line []byte) error {
+	record, err := sink.DecodeLine(line)
+	if err != nil {
+		return err
+	}
+
+	tag := s.tag
+	if s.tagWithLevel {
+		tag += "." + ent.Level.String()
+	}
+	return s.fluent.PostWithTime(tag, ent.Time, record)
+}
+
+// Sync 异步发送由 fluent-logger-golang 的后台 goroutine 完成
+func (s *Sink) Sync() error {
+	return nil
+}
+
+// Close 发送缓冲区中剩余的日志后关闭连接
+func (s *Sink) Close() error {
+	return s.fluent.Close()
+}
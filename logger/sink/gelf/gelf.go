@@ -0,0 +1,241 @@
+// Package gelf 以 GELF 1.1 格式将日志发送到 Graylog, 支持 UDP(压缩、分块)和 TCP
+// 空导入后在 LoggerConfig.Sinks 中配置 type 为 gelf
+//
+//	sinks:
+//	  - type: gelf
+//	    options: {network: udp, address: "graylog:12201"}
+package gelf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+
+	log "basic-middle/logger"
+	"basic-middle/logger/sink"
+)
+
+func init() {
+	log.RegisterSink("gelf", New)
+}
+
+// Options gelf sink 的配置
+type Options struct {
+	Network     string `json:"network"`     //udp|tcp, 默认 udp
+	Address     string `json:"address"`     //graylog input 地址, 如 graylog:12201
+	Compression string `json:"compression"` //udp 的压缩方式 gzip|zlib|none, 默认 gzip
+	ChunkSize   int    `json:"chunk_size"`  //udp 单个分块的最大字节数, 默认 1420
+	Host        string `json:"host"`        //host 字段, 默认本机 hostname
+	TimeoutMs   int    `json:"timeout_ms"`  //连接和写入超时毫秒数, 默认 3000
+}
+
+// gelf 规定的分块上限
+const (
+	maxChunks   = 128
+	chunkHeader = 12
+)
+
+// severity zap 等级对应的 syslog severity
+var severity = map[zapcore.Level]int{
+	zapcore.DebugLevel:  7,
+	zapcore.InfoLevel:   6,
+	zapcore.WarnLevel:   4,
+	zapcore.ErrorLevel:  3,
+	zapcore.DPanicLevel: 2,
+	zapcore.PanicLevel:  1,
+	zapcore.FatalLevel:  0,
+}
+
+// Sink 同步发送, tcp 写入失败时重连一次
+type Sink struct {
+	mu      sync.Mutex
+	opts    Options
+	keys    log.EncoderKeys
+	timeout time.Duration
+	conn    net.Conn
+}
+
+// New 按 sc.Options 创建 gelf sink
+func New(conf *log.LoggerConfig, sc log.SinkConfig) (log.Sink, error) {
+	opts := Options{Network: "udp", Compression: "gzip", ChunkSize: 1420}
+	if err := sc.Decode(&opts); err != nil {
+		return nil, err
+	}
+	if opts.Address == "" {
+		return nil, errors.New("address is required")
+	}
+	if opts.Network != "udp" && opts.Network != "tcp" {
+		return nil, fmt.Errorf("unsupported network: %q", opts.Network)
+	}
+	if opts.ChunkSize <= chunkHeader {
+		opts.ChunkSize = 1420
+	}
+	if opts.Host == "" {
+		opts.Host, _ = os.Hostname()
+	}
+
+	s := &Sink{opts: opts, keys: conf.Keys.WithDefaults(), timeout: time.Duration(opts.TimeoutMs) * time.Millisecond}
+	if s.timeout <= 0 {
+		s.timeout = 3 * time.Second
+	}
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Sink) connect() error {
+	conn, err := net.DialTimeout(s.opts.Network, s.opts.Address, s.timeout)
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	return nil
+}
+
+// message 将日志转为 GELF 消息, 日志中的其他字段作为 _ 开头的附加字段
+func (s *Sink) message(ent zapcore.Entry, line []byte) ([]byte, error) {
+	record, err := sink.DecodeLine(line)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := map[string]interface{}{
+		"version":       "1.1",
+		"host":          s.opts.Host,
+		"short_message": ent.Message,
+		"timestamp":     float64(ent.Time.UnixNano()) / float64(time.Second),
+		"level":         severity[ent.Level],
+	}
+	if ent.Stack != "" {
+		msg["full_message"] = ent.Stack
+	}
+	for k, v := range record {
+		switch k {
+		case s.keys.Time, s.keys.Level, s.keys.Message, s.keys.Stacktrace:
+			continue
+		case "id":
+			// _id 是 GELF 保留字段
+			k = "id_"
+		}
+		switch v.(type) {
+		case string, int64, float64:
+		default:
+			b, _ := json.Marshal(v)
+			v = string(b)
+		}
+		msg["_"+k] = v
+	}
+	return json.Marshal(msg)
+}
+
+func (s *Sink) Write(ent zapcore.Entry, line []byte) error {
+	msg, err := s.message(ent, line)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.opts.Network == "udp" {
+		return s.writeUDP(msg)
+	}
+
+	// tcp 以 \0 分隔消息, 不支持压缩
+	frame := append(msg, 0)
+	if err := s.write(frame); err == nil {
+		return nil
+	}
+	s.conn.Close()
+	if err := s.connect(); err != nil {
+		return err
+	}
+	return s.write(frame)
+}
+
+func (s *Sink) writeUDP(msg []byte) error {
+	msg, err := compress(s.opts.Compression, msg)
+	if err != nil {
+		return err
+	}
+	if len(msg) <= s.opts.ChunkSize {
+		return s.write(msg)
+	}
+
+	// 超过单个数据报大小时分块: 0x1e 0x0f、8 字节消息 id、序号、总块数、数据
+	size := s.opts.ChunkSize - chunkHeader
+	count := (len(msg) + size - 1) / size
+	if count > maxChunks {
+		return fmt.Errorf("message too large: %d bytes", len(msg))
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+	chunk := make([]byte, 0, s.opts.ChunkSize)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * size
+		if end > len(msg) {
+			end = len(msg)
+		}
+		chunk = append(chunk[:0], 0x1e, 0x0f)
+		chunk = append(chunk, id...)
+		chunk = append(chunk, byte(i), byte(count))
+		chunk = append(chunk, msg[i*size:end]...)
+		if err := s.write(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func compress(method string, msg []byte) ([]byte, error) {
+	var (
+		buf bytes.Buffer
+		w   io.WriteCloser
+	)
+	switch method {
+	case "", "none":
+		return msg, nil
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "zlib":
+		w = zlib.NewWriter(&buf)
+	default:
+		return nil, fmt.Errorf("unsupported compression: %q", method)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *Sink) write(b []byte) error {
+	s.conn.SetWriteDeadline(time.Now().Add(s.timeout))
+	_, err := s.conn.Write(b)
+	return err
+}
+
+func (s *Sink) Sync() error {
+	return nil
+}
+
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn.Close()
+}
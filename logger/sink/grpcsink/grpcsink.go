@@ -0,0 +1,304 @@
+// Package grpcsink 通过 gRPC 双向流将批量日志发送到实现了 logpb.LogService 的收集服务
+// 空导入后在 LoggerConfig.Sinks 中配置 type 为 grpc, 适用于不允许直接使用 TCP syslog 的服务网格
+//
+// 未确认的批次数达到 max_inflight 时暂停发送, 日志在 Batcher 的队列中等待, 队列满时按 block_when_full 处理
+// 连接断开后自动重连, 并在新的流上按顺序重新发送未确认的批次
+//
+//	sinks:
+//	  - type: grpc
+//	    options: {endpoint: "log-collector:9000", insecure: true, max_inflight: 8}
+package grpcsink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+
+	log "basic-middle/logger"
+	"basic-middle/logger/sink"
+	"basic-middle/logger/sink/grpcsink/logpb"
+)
+
+func init() {
+	log.RegisterSink("grpc", New)
+}
+
+// Options grpc sink 的配置, BatchConfig 的重试不生效, 发送失败的批次由重连后重新发送
+type Options struct {
+	sink.BatchConfig
+	Endpoint       string            `json:"endpoint"`         //收集服务地址
+	Insecure       bool              `json:"insecure"`         //不使用 TLS, 如网格内由 sidecar 加密
+	Metadata       map[string]string `json:"metadata"`         //附加的 gRPC metadata, 如认证信息
+	MaxInflight    int               `json:"max_inflight"`     //最多未确认的批次数, 默认 8
+	AckTimeoutMs   int               `json:"ack_timeout_ms"`   //Sync 和 Close 时等待确认的毫秒数, 默认 5000
+	ReconnectMs    int               `json:"reconnect_ms"`     //首次重连等待毫秒数, 之后每次翻倍, 默认 500
+	MaxReconnectMs int               `json:"max_reconnect_ms"` //重连等待的上限毫秒数, 默认 30000
+}
+
+// New 按 sc.Options 创建 grpc sink, 连接在后台建立
+func New(conf *log.LoggerConfig, sc log.SinkConfig) (log.Sink, error) {
+	var opts Options
+	if err := sc.Decode(&opts); err != nil {
+		return nil, err
+	}
+	if opts.Endpoint == "" {
+		return nil, errors.New("endpoint is required")
+	}
+	if opts.MaxInflight <= 0 {
+		opts.MaxInflight = 8
+	}
+	if opts.AckTimeoutMs <= 0 {
+		opts.AckTimeoutMs = 5000
+	}
+	if opts.ReconnectMs <= 0 {
+		opts.ReconnectMs = 500
+	}
+	if opts.MaxReconnectMs <= 0 {
+		opts.MaxReconnectMs = 30000
+	}
+	opts.MaxRetries = -1
+
+	creds := credentials.NewTLS(nil)
+	if opts.Insecure {
+		creds = insecure.NewCredentials()
+	}
+	conn, err := grpc.Dial(opts.Endpoint, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, err
+	}
+
+	host, _ := os.Hostname()
+	c := &client{
+		opts:      opts,
+		conn:      conn,
+		client:    logpb.NewLogServiceClient(conn),
+		md:        metadata.New(opts.Metadata),
+		id:        host + "-" + strconv.Itoa(os.Getpid()) + "-" + strconv.FormatInt(time.Now().UnixNano(), 36),
+		namespace: conf.Namespace,
+		project:   conf.Project,
+		hostname:  host,
+		done:      make(chan struct{}),
+	}
+	c.cond = sync.NewCond(&c.mu)
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	go c.run()
+
+	return &Sink{Batcher: sink.NewBatcher("grpc", opts.BatchConfig, c.push), client: c}, nil
+}
+
+// Sink Sync 和 Close 在发送完队列后还会等待服务端确认
+type Sink struct {
+	*sink.Batcher
+	client *client
+}
+
+func (s *Sink) Sync() error {
+	s.Batcher.Sync()
+	return s.client.wait()
+}
+
+func (s *Sink) Close() error {
+	s.Batcher.Close()
+	err := s.client.wait()
+	return multierr.Append(err, s.client.close())
+}
+
+type client struct {
+	opts   Options
+	conn   *grpc.ClientConn
+	client logpb.LogServiceClient
+	md     metadata.MD
+
+	id        string
+	namespace string
+	project   string
+	hostname  string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// sendMu 保证同一个流上按 seq 顺序发送, 需要同时持有时先获取 sendMu
+	sendMu  sync.Mutex
+	mu      sync.Mutex
+	cond    *sync.Cond
+	seq     uint64
+	unacked []*logpb.PushRequest
+	stream  logpb.LogService_PushClient
+	closed  bool
+}
+
+// push Batcher 的 FlushFunc, 未确认的批次过多时阻塞, 发送失败时由重连后重新发送
+func (c *client) push(records []sink.Record) error {
+	req := c.request(records)
+
+	c.mu.Lock()
+	for len(c.unacked) >= c.opts.MaxInflight && !c.closed {
+		c.cond.Wait()
+	}
+	closed := c.closed
+	c.mu.Unlock()
+	if closed {
+		return errors.New("grpc log sink closed")
+	}
+
+	// 持有 sendMu 时加入 unacked, 重连时要么包含在重新发送的批次中, 要么在之后发送到新的流上
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	c.mu.Lock()
+	c.seq++
+	req.Seq = c.seq
+	c.unacked = append(c.unacked, req)
+	stream := c.stream
+	c.mu.Unlock()
+	if stream != nil {
+		// 出错时 Recv 也会返回错误, 由 run 重连
+		stream.Send(req)
+	}
+	return nil
+}
+
+func (c *client) request(records []sink.Record) *logpb.PushRequest {
+	req := &logpb.PushRequest{
+		ClientId:  c.id,
+		Namespace: c.namespace,
+		Project:   c.project,
+		Hostname:  c.hostname,
+		Entries:   make([]*logpb.Entry, 0, len(records)),
+	}
+	for _, r := range records {
+		e := &logpb.Entry{
+			TimeUnixNano: r.Entry.Time.UnixNano(),
+			Level:        r.Entry.Level.String(),
+			Logger:       r.Entry.LoggerName,
+			Message:      r.Entry.Message,
+			Json:         r.Line,
+		}
+		if r.Entry.Caller.Defined {
+			e.Caller = r.Entry.Caller.TrimmedPath()
+		}
+		req.Entries = append(req.Entries, e)
+	}
+	return req
+}
+
+// run 维护推送流: 建立流后重新发送未确认的批次, 接收确认直到流断开, 然后退避重连
+func (c *client) run() {
+	defer close(c.done)
+
+	backoff := time.Duration(c.opts.ReconnectMs) * time.Millisecond
+	var lastErr error
+	for {
+		acked, err := c.serve()
+		if c.ctx.Err() != nil {
+			return
+		}
+		if acked {
+			backoff = time.Duration(c.opts.ReconnectMs) * time.Millisecond
+			lastErr = nil
+		}
+		if lastErr == nil {
+			fmt.Fprintf(os.Stderr, "grpc log sink: stream to %s broken, reconnecting: %v\n", c.opts.Endpoint, err)
+		}
+		lastErr = err
+
+		select {
+		case <-time.After(backoff):
+		case <-c.ctx.Done():
+			return
+		}
+		if backoff *= 2; backoff > time.Duration(c.opts.MaxReconnectMs)*time.Millisecond {
+			backoff = time.Duration(c.opts.MaxReconnectMs) * time.Millisecond
+		}
+	}
+}
+
+// serve 在一个流上发送和接收确认, 返回期间是否收到过确认
+func (c *client) serve() (bool, error) {
+	ctx, cancel := context.WithCancel(metadata.NewOutgoingContext(c.ctx, c.md))
+	defer cancel()
+
+	stream, err := c.client.Push(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	c.sendMu.Lock()
+	c.mu.Lock()
+	c.stream = stream
+	resend := append([]*logpb.PushRequest(nil), c.unacked...)
+	c.mu.Unlock()
+	for _, req := range resend {
+		if err = stream.Send(req); err != nil {
+			break
+		}
+	}
+	c.sendMu.Unlock()
+
+	acked := false
+	if err == nil {
+		for {
+			var resp *logpb.PushResponse
+			if resp, err = stream.Recv(); err != nil {
+				break
+			}
+			acked = true
+			c.ack(resp.GetSeq())
+		}
+	}
+
+	c.mu.Lock()
+	c.stream = nil
+	c.mu.Unlock()
+	return acked, err
+}
+
+func (c *client) ack(seq uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for n < len(c.unacked) && c.unacked[n].Seq <= seq {
+		n++
+	}
+	c.unacked = c.unacked[n:]
+	c.cond.Broadcast()
+}
+
+// wait 等待所有批次被确认, 最多等待 AckTimeoutMs
+func (c *client) wait() error {
+	timeout := time.Duration(c.opts.AckTimeoutMs) * time.Millisecond
+	deadline := time.Now().Add(timeout)
+	for {
+		c.mu.Lock()
+		n := len(c.unacked)
+		c.mu.Unlock()
+		if n == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%d log batches not acknowledged by %s within %s", n, c.opts.Endpoint, timeout)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (c *client) close() error {
+	c.mu.Lock()
+	c.closed = true
+	c.cond.Broadcast()
+	c.mu.Unlock()
+
+	c.cancel()
+	<-c.done
+	return c.conn.Close()
+}
@@ -0,0 +1,6 @@
+// Package logpb LogService 的 protobuf 定义和生成代码, 修改 log.proto 后重新生成:
+//
+//	go generate ./logger/sink/grpcsink/logpb
+package logpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative log.proto
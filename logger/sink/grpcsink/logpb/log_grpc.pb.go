@@ -0,0 +1,145 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v4.25.1
+// source: log.proto
+
+package logpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	LogService_Push_FullMethodName = "/basicmiddle.log.v1.LogService/Push"
+)
+
+// LogServiceClient is the client API for LogService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type LogServiceClient interface {
+	// Push 客户端以流的方式持续发送批量日志, 服务端处理完成后按批次确认
+	// 连接断开后客户端会在新的流上按顺序重新发送未确认的批次, 服务端可以按 client_id 和 seq 去重
+	Push(ctx context.Context, opts ...grpc.CallOption) (LogService_PushClient, error)
+}
+
+type logServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewLogServiceClient(cc grpc.ClientConnInterface) LogServiceClient {
+	return &logServiceClient{cc}
+}
+
+func (c *logServiceClient) Push(ctx context.Context, opts ...grpc.CallOption) (LogService_PushClient, error) {
+	stream, err := c.cc.NewStream(ctx, &LogService_ServiceDesc.Streams[0], LogService_Push_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &logServicePushClient{stream}
+	return x, nil
+}
+
+type LogService_PushClient interface {
+	Send(*PushRequest) error
+	Recv() (*PushResponse, error)
+	grpc.ClientStream
+}
+
+type logServicePushClient struct {
+	grpc.ClientStream
+}
+
+func (x *logServicePushClient) Send(m *PushRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *logServicePushClient) Recv() (*PushResponse, error) {
+	m := new(PushResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// LogServiceServer is the server API for LogService service.
+// All implementations must embed UnimplementedLogServiceServer
+// for forward compatibility
+type LogServiceServer interface {
+	// Push 客户端以流的方式持续发送批量日志, 服务端处理完成后按批次确认
+	// 连接断开后客户端会在新的流上按顺序重新发送未确认的批次, 服务端可以按 client_id 和 seq 去重
+	Push(LogService_PushServer) error
+	mustEmbedUnimplementedLogServiceServer()
+}
+
+// UnimplementedLogServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedLogServiceServer struct {
+}
+
+func (UnimplementedLogServiceServer) Push(LogService_PushServer) error {
+	return status.Errorf(codes.Unimplemented, "method Push not implemented")
+}
+func (UnimplementedLogServiceServer) mustEmbedUnimplementedLogServiceServer() {}
+
+// UnsafeLogServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to LogServiceServer will
+// result in compilation errors.
+type UnsafeLogServiceServer interface {
+	mustEmbedUnimplementedLogServiceServer()
+}
+
+func RegisterLogServiceServer(s grpc.ServiceRegistrar, srv LogServiceServer) {
+	s.RegisterService(&LogService_ServiceDesc, srv)
+}
+
+func _LogService_Push_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(LogServiceServer).Push(&logServicePushServer{stream})
+}
+
+type LogService_PushServer interface {
+	Send(*PushResponse) error
+	Recv() (*PushRequest, error)
+	grpc.ServerStream
+}
+
+type logServicePushServer struct {
+	grpc.ServerStream
+}
+
+func (x *logServicePushServer) Send(m *PushResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *logServicePushServer) Recv() (*PushRequest, error) {
+	m := new(PushRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// LogService_ServiceDesc is the grpc.ServiceDesc for LogService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var LogService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "basicmiddle.log.v1.LogService",
+	HandlerType: (*LogServiceServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Push",
+			Handler:       _LogService_Push_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "log.proto",
+}
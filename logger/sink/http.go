@@ -0,0 +1,52 @@
+package sink
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// NewHTTPClient 发送日志使用的 http.Client, timeoutMs 为 0 时默认 10 秒
+func NewHTTPClient(timeoutMs int) *http.Client {
+	timeout := time.Duration(timeoutMs) * time.Millisecond
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &http.Client{Timeout: timeout}
+}
+
+// HTTPError 非 2xx 的响应
+type HTTPError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Status     string
+	Body       []byte // 最多 512 字节
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("%s %s: %s: %s", e.Method, e.URL, e.Status, e.Body)
+}
+
+// Retryable 408、429 和 5xx 可以重试, 其余 4xx 重试也不会成功
+func (e *HTTPError) Retryable() bool {
+	return e.StatusCode == http.StatusRequestTimeout || e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// Do 发送请求, 非 2xx 的响应返回 *HTTPError
+func Do(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return &HTTPError{Method: req.Method, URL: req.URL.Redacted(), StatusCode: resp.StatusCode, Status: resp.Status, Body: body}
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	return nil
+}
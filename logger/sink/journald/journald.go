@@ -0,0 +1,133 @@
+// Package journald 通过 native 协议将日志写入 systemd-journald, 空导入后在 LoggerConfig.Sinks 中配置 type 为 journald
+// 日志字段转换为大写的 journal 字段, 可以用 journalctl 按字段过滤:
+//
+//	journalctl -t demo PRIORITY=3 TRACE_ID=xxx
+//
+//	sinks:
+//	  - type: journald
+//	    options: {identifier: demo}
+package journald
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/coreos/go-systemd/v22/journal"
+	"go.uber.org/zap/zapcore"
+
+	log "basic-middle/logger"
+	"basic-middle/logger/sink"
+)
+
+func init() {
+	log.RegisterSink("journald", New)
+}
+
+// Options journald sink 的配置
+type Options struct {
+	Identifier  string `json:"identifier"`   //SYSLOG_IDENTIFIER, 默认 project
+	FieldPrefix string `json:"field_prefix"` //日志字段的前缀, 如 APP_, 避免与 journal 的标准字段冲突
+}
+
+// priority zap 等级对应的 journal priority, 与 syslog sink 相同
+var priority = map[zapcore.Level]journal.Priority{
+	zapcore.DebugLevel:  journal.PriDebug,
+	zapcore.InfoLevel:   journal.PriInfo,
+	zapcore.WarnLevel:   journal.PriWarning,
+	zapcore.ErrorLevel:  journal.PriErr,
+	zapcore.DPanicLevel: journal.PriCrit,
+	zapcore.PanicLevel:  journal.PriAlert,
+	zapcore.FatalLevel:  journal.PriEmerg,
+}
+
+// New 按 sc.Options 创建 journald sink, journald 的 socket 不存在时返回错误
+func New(conf *log.LoggerConfig, sc log.SinkConfig) (log.Sink, error) {
+	var opts Options
+	if err := sc.Decode(&opts); err != nil {
+		return nil, err
+	}
+	if !journal.Enabled() {
+		return nil, errors.New("journald socket not available")
+	}
+	if opts.Identifier == "" {
+		opts.Identifier = conf.Project
+	}
+	return &Sink{opts: opts, keys: conf.Keys.WithDefaults()}, nil
+}
+
+// Sink 每条日志发送一个 datagram, 超过 socket 缓冲区大小时由 go-systemd 改用临时文件传递
+type Sink struct {
+	opts Options
+	keys log.EncoderKeys
+}
+
+func (s *Sink) Write(ent zapcore.Entry, line []byte) error {
+	record, err := sink.DecodeLine(line)
+	if err != nil {
+		return err
+	}
+
+	vars := make(map[string]string, len(record)+5)
+	for k, v := range record {
+		switch k {
+		case s.keys.Time, s.keys.Level, s.keys.Caller, s.keys.Message:
+			continue
+		}
+		name := fieldName(s.opts.FieldPrefix + k)
+		if name == "" {
+			continue
+		}
+		vars[name] = value(v)
+	}
+	if s.opts.Identifier != "" {
+		vars["SYSLOG_IDENTIFIER"] = s.opts.Identifier
+	}
+	if ent.Caller.Defined {
+		vars["CODE_FILE"] = ent.Caller.File
+		vars["CODE_LINE"] = strconv.Itoa(ent.Caller.Line)
+		if ent.Caller.Function != "" {
+			vars["CODE_FUNC"] = ent.Caller.Function
+		}
+	}
+	return journal.Send(ent.Message, priority[ent.Level], vars)
+}
+
+// fieldName journal 字段名只能包含大写字母、数字和下划线, 且不能以下划线或数字开头
+func fieldName(k string) string {
+	b := make([]byte, 0, len(k))
+	for i := 0; i < len(k); i++ {
+		c := k[i]
+		switch {
+		case c >= 'a' && c <= 'z':
+			b = append(b, c-'a'+'A')
+		case c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_':
+			b = append(b, c)
+		default:
+			b = append(b, '_')
+		}
+	}
+	return strings.TrimLeft(string(b), "_0123456789")
+}
+
+// value 字符串原样写入, 其余类型按 JSON 编码
+func value(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+func (s *Sink) Sync() error {
+	return nil
+}
+
+func (s *Sink) Close() error {
+	return nil
+}
@@ -0,0 +1,204 @@
+// Package kafka 将日志以 JSON 发送到 kafka topic, 空导入后在 LoggerConfig.Sinks 中配置 type 为 kafka
+//
+//	sinks:
+//	  - type: kafka
+//	    options: {brokers: ["127.0.0.1:9092"], topic: app-logs, compression: lz4}
+//
+// 也可以在 Outputs 中配置为 URL, 多个 broker 以逗号分隔, 其余配置作为查询参数:
+//
+//	outputs: [file, "kafka://127.0.0.1:9092,127.0.0.2:9092/app-logs?compression=lz4"]
+package kafka
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/IBM/sarama"
+	"go.uber.org/zap/zapcore"
+
+	log "basic-middle/logger"
+)
+
+func init() {
+	log.RegisterSink("kafka", New)
+	log.RegisterScheme("kafka", ParseURL)
+}
+
+// ParseURL 解析 kafka://broker1,broker2/topic?key=value 形式的输出
+func ParseURL(u *url.URL) (log.SinkConfig, error) {
+	topic := strings.Trim(u.Path, "/")
+	if u.Host == "" || topic == "" {
+		return log.SinkConfig{}, errors.New("kafka url must be kafka://broker[,broker]/topic")
+	}
+	opts, err := log.URLOptions(u, map[string]interface{}{
+		"brokers": strings.Split(u.Host, ","),
+		"topic":   topic,
+	})
+	return log.SinkConfig{Type: "kafka", Options: opts}, err
+}
+
+// Options kafka sink 的配置
+type Options struct {
+	Brokers         []string `json:"brokers"`           //broker 地址列表
+	Topic           string   `json:"topic"`             //写入的 topic
+	BatchSize       int      `json:"batch_size"`        //每批最多条数, 默认 100
+	FlushIntervalMs int      `json:"flush_interval_ms"` //未攒满一批时的发送间隔毫秒数, 默认 500
+	Compression     string   `json:"compression"`       //压缩方式 none|gzip|snappy|lz4|zstd, 默认 none
+	Version         string   `json:"version"`           //kafka 版本, 如 2.8.0, 默认使用 sarama 的默认版本
+	BlockWhenFull   bool     `json:"block_when_full"`   //发送队列满时阻塞写入方, 默认丢弃并计入 Dropped
+	SyncTimeoutMs   int      `json:"sync_timeout_ms"`   //Sync 等待已写入的消息返回结果的最长毫秒数, 默认 5000
+}
+
+// Sink 通过 sarama 的 AsyncProducer 发送, 批量和压缩由 sarama 完成
+type Sink struct {
+	topic    string
+	block    bool
+	timeout  time.Duration
+	producer sarama.AsyncProducer
+	done     chan struct{}
+	dropped  int64
+	failed   int64
+
+	mu      sync.Mutex
+	pending int // 已写入 producer 还未返回结果的消息数
+}
+
+// New 按 sc.Options 创建 kafka sink
+func New(_ *log.LoggerConfig, sc log.SinkConfig) (log.Sink, error) {
+	var opts Options
+	if err := sc.Decode(&opts); err != nil {
+		return nil, err
+	}
+	if len(opts.Brokers) == 0 || opts.Topic == "" {
+		return nil, errors.New("brokers and topic are required")
+	}
+
+	cfg := sarama.NewConfig()
+	cfg.Producer.RequiredAcks = sarama.WaitForLocal
+	cfg.Producer.Return.Errors = true
+	cfg.Producer.Return.Successes = true
+	cfg.Producer.Flush.Messages = opts.BatchSize
+	if cfg.Producer.Flush.Messages <= 0 {
+		cfg.Producer.Flush.Messages = 100
+	}
+	cfg.Producer.Flush.Frequency = time.Duration(opts.FlushIntervalMs) * time.Millisecond
+	if cfg.Producer.Flush.Frequency <= 0 {
+		cfg.Producer.Flush.Frequency = 500 * time.Millisecond
+	}
+	if opts.Compression != "" {
+		if err := cfg.Producer.Compression.UnmarshalText([]byte(opts.Compression)); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Version != "" {
+		v, err := sarama.ParseKafkaVersion(opts.Version)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Version = v
+	}
+
+	producer, err := sarama.NewAsyncProducer(opts.Brokers, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if opts.SyncTimeoutMs <= 0 {
+		opts.SyncTimeoutMs = 5000
+	}
+	s := &Sink{
+		topic:    opts.Topic,
+		block:    opts.BlockWhenFull,
+		timeout:  time.Duration(opts.SyncTimeoutMs) * time.Millisecond,
+		producer: producer,
+		done:     make(chan struct{}),
+	}
+	go s.drain()
+	return s, nil
+}
+
+func (s *Sink) Write(_ zapcore.Entry, line []byte) error {
+	msg := &sarama.ProducerMessage{Topic: s.topic, Value: sarama.ByteEncoder(append([]byte(nil), line...))}
+	s.mu.Lock()
+	s.pending++
+	s.mu.Unlock()
+	if s.block {
+		s.producer.Input() <- msg
+		return nil
+	}
+	select {
+	case s.producer.Input() <- msg:
+	default:
+		atomic.AddInt64(&s.dropped, 1)
+		log.GetSinkMetrics().Dropped("kafka", 1)
+		s.done1()
+	}
+	return nil
+}
+
+// Sync sarama 没有主动刷新的接口, 等待已写入的消息按 Flush.Frequency 发送并返回结果
+// broker 不可用时 sarama 会持续重试, 最多等待 SyncTimeoutMs
+func (s *Sink) Sync() error {
+	deadline := time.Now().Add(s.timeout)
+	for {
+		s.mu.Lock()
+		n := s.pending
+		s.mu.Unlock()
+		if n == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%d log entries not sent to kafka within %s", n, s.timeout)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Close 发送剩余的消息后关闭 producer
+func (s *Sink) Close() error {
+	s.producer.AsyncClose()
+	<-s.done
+	return nil
+}
+
+// Dropped 发送队列满被丢弃的日志条数
+func (s *Sink) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+// Failed sarama 重试后仍发送失败的日志条数
+func (s *Sink) Failed() int64 {
+	return atomic.LoadInt64(&s.failed)
+}
+
+// done1 一条消息有了结果
+func (s *Sink) done1() {
+	s.mu.Lock()
+	s.pending--
+	s.mu.Unlock()
+}
+
+// drain 读取发送结果, 发送失败的日志无法再写入日志, 只能输出到 stderr
+func (s *Sink) drain() {
+	defer close(s.done)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range s.producer.Successes() {
+			s.done1()
+		}
+	}()
+	for err := range s.producer.Errors() {
+		atomic.AddInt64(&s.failed, 1)
+		log.GetSinkMetrics().Failed("kafka", 1)
+		fmt.Fprintf(os.Stderr, "kafka log sink: %v\n", err)
+		s.done1()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,120 @@
+// Package loki 通过 push API 将日志发送到 Grafana Loki, 空导入后在 LoggerConfig.Sinks 中配置 type 为 loki
+//
+//	sinks:
+//	  - type: loki
+//	    options: {url: "http://loki:3100", labels: {env: prod}}
+package loki
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	log "basic-middle/logger"
+	"basic-middle/logger/sink"
+)
+
+func init() {
+	log.RegisterSink("loki", New)
+}
+
+// Options loki sink 的配置
+type Options struct {
+	sink.BatchConfig
+	URL       string            `json:"url"`        //loki 地址, 如 http://loki:3100
+	Labels    map[string]string `json:"labels"`     //附加的静态 label
+	TenantID  string            `json:"tenant_id"`  //多租户时的 X-Scope-OrgID
+	Username  string            `json:"username"`   //basic auth 用户名
+	Password  string            `json:"password"`   //basic auth 密码
+	TimeoutMs int               `json:"timeout_ms"` //请求超时毫秒数, 默认 10000
+}
+
+// New 按 sc.Options 创建 loki sink, namespace、project 和 level 作为 label
+func New(conf *log.LoggerConfig, sc log.SinkConfig) (log.Sink, error) {
+	var opts Options
+	if err := sc.Decode(&opts); err != nil {
+		return nil, err
+	}
+	if opts.URL == "" {
+		return nil, errors.New("url is required")
+	}
+
+	labels := map[string]string{}
+	for k, v := range opts.Labels {
+		labels[k] = v
+	}
+	if conf.Namespace != "" {
+		labels["namespace"] = conf.Namespace
+	}
+	if conf.Project != "" {
+		labels["project"] = conf.Project
+	}
+
+	p := &pusher{
+		url:    strings.TrimRight(opts.URL, "/") + "/loki/api/v1/push",
+		opts:   opts,
+		labels: labels,
+		client: sink.NewHTTPClient(opts.TimeoutMs),
+	}
+	return sink.NewBatcher("loki", opts.BatchConfig, p.push), nil
+}
+
+type pusher struct {
+	url    string
+	opts   Options
+	labels map[string]string
+	client *http.Client
+}
+
+type stream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// push 按等级分成不同的 stream 发送
+func (p *pusher) push(records []sink.Record) error {
+	streams := make(map[string]*stream)
+	var order []string
+	for _, r := range records {
+		level := r.Entry.Level.String()
+		s, ok := streams[level]
+		if !ok {
+			labels := make(map[string]string, len(p.labels)+1)
+			for k, v := range p.labels {
+				labels[k] = v
+			}
+			labels["level"] = level
+			s = &stream{Stream: labels}
+			streams[level] = s
+			order = append(order, level)
+		}
+		s.Values = append(s.Values, [2]string{strconv.FormatInt(r.Entry.Time.UnixNano(), 10), string(r.Line)})
+	}
+
+	body := struct {
+		Streams []*stream `json:"streams"`
+	}{}
+	for _, level := range order {
+		body.Streams = append(body.Streams, streams[level])
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, p.url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.opts.TenantID != "" {
+		req.Header.Set("X-Scope-OrgID", p.opts.TenantID)
+	}
+	if p.opts.Username != "" {
+		req.SetBasicAuth(p.opts.Username, p.opts.Password)
+	}
+	return sink.Do(p.client, req)
+}
@@ -0,0 +1,177 @@
+// Package nats 将日志发布到 NATS, 可选使用 JetStream 持久化, 空导入后在 LoggerConfig.Sinks 中配置 type 为 nats
+// subject 默认 logs.{namespace}.{project}.{level}, 订阅 logs.ns.demo.* 即可只查看某个项目的日志
+//
+//	sinks:
+//	  - type: nats
+//	    options: {url: "nats://nats:4222", jetstream: true, stream: LOGS}
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"go.uber.org/multierr"
+
+	log "basic-middle/logger"
+	"basic-middle/logger/sink"
+)
+
+func init() {
+	log.RegisterSink("nats", New)
+}
+
+// Options nats sink 的配置
+type Options struct {
+	sink.BatchConfig
+	URL       string `json:"url"`        //服务地址, 多个地址以逗号分隔, 默认 nats://127.0.0.1:4222
+	Subject   string `json:"subject"`    //subject 模板, 支持 {namespace}、{project}、{level} 占位符
+	Username  string `json:"username"`   //用户名
+	Password  string `json:"password"`   //密码
+	Token     string `json:"token"`      //token 认证
+	CredsFile string `json:"creds_file"` //NATS 2.0 的 credentials 文件
+	JetStream bool   `json:"jetstream"`  //通过 JetStream 发布并等待确认
+	Stream    string `json:"stream"`     //JetStream 的 stream 名称, 设置后不存在时自动创建并订阅 subject 的所有等级
+	TimeoutMs int    `json:"timeout_ms"` //每批发布的超时毫秒数, 默认 5000
+}
+
+// New 按 sc.Options 创建 nats sink
+func New(conf *log.LoggerConfig, sc log.SinkConfig) (log.Sink, error) {
+	var opts Options
+	if err := sc.Decode(&opts); err != nil {
+		return nil, err
+	}
+	if opts.URL == "" {
+		opts.URL = nats.DefaultURL
+	}
+	if opts.Subject == "" {
+		opts.Subject = "logs.{namespace}.{project}.{level}"
+	}
+	if opts.TimeoutMs <= 0 {
+		opts.TimeoutMs = 5000
+	}
+
+	natsOpts := []nats.Option{nats.Name("basic-middle/logger"), nats.MaxReconnects(-1)}
+	if opts.Username != "" {
+		natsOpts = append(natsOpts, nats.UserInfo(opts.Username, opts.Password))
+	}
+	if opts.Token != "" {
+		natsOpts = append(natsOpts, nats.Token(opts.Token))
+	}
+	if opts.CredsFile != "" {
+		natsOpts = append(natsOpts, nats.UserCredentials(opts.CredsFile))
+	}
+	nc, err := nats.Connect(opts.URL, natsOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &publisher{
+		nc:      nc,
+		subject: strings.NewReplacer("{namespace}", token(conf.Namespace), "{project}", token(conf.Project)).Replace(opts.Subject),
+		timeout: time.Duration(opts.TimeoutMs) * time.Millisecond,
+	}
+	if opts.JetStream {
+		if p.js, err = nc.JetStream(); err != nil {
+			nc.Close()
+			return nil, err
+		}
+		if opts.Stream != "" {
+			if err := p.ensureStream(opts.Stream); err != nil {
+				nc.Close()
+				return nil, err
+			}
+		}
+	}
+	return &Sink{Batcher: sink.NewBatcher("nats", opts.BatchConfig, p.publish), nc: nc}, nil
+}
+
+// token subject 中的一段不能为空, 也不能包含 . 和空白
+func token(s string) string {
+	if s == "" {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', ' ', '\t', '\r', '\n', '*', '>':
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// Sink 关闭时先发布完剩余的日志再关闭连接
+type Sink struct {
+	*sink.Batcher
+	nc *nats.Conn
+}
+
+func (s *Sink) Close() error {
+	err := s.Batcher.Close()
+	return multierr.Append(err, s.nc.Drain())
+}
+
+type publisher struct {
+	nc      *nats.Conn
+	js      nats.JetStreamContext
+	subject string // 已替换 namespace 和 project
+	timeout time.Duration
+}
+
+// ensureStream stream 不存在时创建, 订阅 subject 中 {level} 替换为 * 后的所有 subject
+func (p *publisher) ensureStream(name string) error {
+	_, err := p.js.StreamInfo(name)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, nats.ErrStreamNotFound) {
+		return err
+	}
+	_, err = p.js.AddStream(&nats.StreamConfig{
+		Name:     name,
+		Subjects: []string{strings.Replace(p.subject, "{level}", "*", -1)},
+	})
+	return err
+}
+
+func (p *publisher) publish(records []sink.Record) error {
+	if p.js == nil {
+		for _, r := range records {
+			if err := p.nc.Publish(p.subjectOf(r), r.Line); err != nil {
+				return err
+			}
+		}
+		return p.nc.FlushTimeout(p.timeout)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+
+	futures := make([]nats.PubAckFuture, 0, len(records))
+	for _, r := range records {
+		f, err := p.js.PublishAsync(p.subjectOf(r), r.Line)
+		if err != nil {
+			return err
+		}
+		futures = append(futures, f)
+	}
+	// 部分消息失败时整批重试, 对端可能收到重复的日志
+	var errs error
+	for _, f := range futures {
+		select {
+		case <-f.Ok():
+		case err := <-f.Err():
+			errs = multierr.Append(errs, err)
+		case <-ctx.Done():
+			return fmt.Errorf("jetstream publish: %w", ctx.Err())
+		}
+	}
+	return errs
+}
+
+func (p *publisher) subjectOf(r sink.Record) string {
+	return strings.Replace(p.subject, "{level}", r.Entry.Level.String(), -1)
+}
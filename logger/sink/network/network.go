@@ -0,0 +1,416 @@
+// Package network 通过 TCP(可选 TLS) 或 UDP 发送 JSON 行日志, 空导入后在 LoggerConfig.Sinks 中配置 type 为 network
+// 连接断开时自动重连, 期间的日志暂存在内存中, 超出 buffer_size 时丢弃最早的日志, 不阻塞写日志的 goroutine
+// 连接断开时正在发送的一批日志会重新发送, 对端可能收到重复的日志
+//
+//	sinks:
+//	  - type: network
+//	    options: {network: tcp, address: "collector:5170", framing: newline, tls: {ca_file: /etc/ssl/ca.pem}}
+//
+// 也可以在 Outputs 中配置为 tcp://、udp:// 或 tls:// 的 URL, 其余配置作为查询参数:
+//
+//	outputs: [file, "tls://collector:5170?ca_file=/etc/ssl/ca.pem&framing=octet"]
+package network
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+
+	log "basic-middle/logger"
+)
+
+func init() {
+	log.RegisterSink("network", New)
+	for _, scheme := range []string{"tcp", "udp", "tls"} {
+		log.RegisterScheme(scheme, ParseURL)
+	}
+}
+
+// tlsParams tls:// URL 中属于 TLSConfig 的查询参数
+var tlsParams = []string{"ca_file", "cert_file", "key_file", "server_name", "insecure_skip_verify"}
+
+// ParseURL 解析 tcp://host:port、udp://host:port 和 tls://host:port 形式的输出
+func ParseURL(u *url.URL) (log.SinkConfig, error) {
+	if u.Host == "" {
+		return log.SinkConfig{}, fmt.Errorf("%s url must be %s://host:port", u.Scheme, u.Scheme)
+	}
+	extra := map[string]interface{}{"network": u.Scheme, "address": u.Host}
+	if u.Scheme == "tls" {
+		q := u.Query()
+		conf := map[string]interface{}{}
+		for _, k := range tlsParams {
+			if v := q.Get(k); v != "" {
+				conf[k] = v
+			}
+			q.Del(k)
+		}
+		if v, ok := conf["insecure_skip_verify"]; ok {
+			conf["insecure_skip_verify"] = v == "true"
+		}
+		u2 := *u
+		u2.RawQuery = q.Encode()
+		u = &u2
+		extra["network"] = "tcp"
+		extra["tls"] = conf
+	}
+	opts, err := log.URLOptions(u, extra)
+	return log.SinkConfig{Type: "network", Options: opts}, err
+}
+
+// 每条日志的分帧方式
+const (
+	FramingNewline = "newline" //行尾追加换行符
+	FramingLength  = "length"  //4 字节大端序长度前缀
+	FramingOctet   = "octet"   //RFC 6587 octet counting, 如 "12 {...}"
+)
+
+// Options network sink 的配置
+type Options struct {
+	Network        string     `json:"network"`          //tcp|udp, 默认 tcp
+	Address        string     `json:"address"`          //地址, 如 collector:5170
+	TLS            *TLSConfig `json:"tls"`              //为空时不使用 TLS, 只支持 tcp
+	Framing        string     `json:"framing"`          //newline|length|octet, 默认 newline
+	BufferSize     int        `json:"buffer_size"`      //内存中暂存的最大日志条数, 默认 10000
+	BatchSize      int        `json:"batch_size"`       //tcp 单次写入的最大条数, 默认 100
+	DialTimeoutMs  int        `json:"dial_timeout_ms"`  //连接超时毫秒数, 默认 5000
+	WriteTimeoutMs int        `json:"write_timeout_ms"` //写入超时毫秒数, 也是 Sync 和 Close 的最长等待时间, 默认 5000
+	ReconnectMs    int        `json:"reconnect_ms"`     //首次重连等待毫秒数, 之后每次翻倍, 默认 500
+	MaxReconnectMs int        `json:"max_reconnect_ms"` //重连等待的上限毫秒数, 默认 30000
+}
+
+// TLSConfig tcp 连接的 TLS 配置
+type TLSConfig struct {
+	CAFile             string `json:"ca_file"`              //校验服务端证书的 CA, 默认使用系统 CA
+	CertFile           string `json:"cert_file"`            //客户端证书, 服务端要求双向认证时配置
+	KeyFile            string `json:"key_file"`             //客户端私钥
+	ServerName         string `json:"server_name"`          //校验的服务端名称, 默认取 address 中的主机名
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"` //不校验服务端证书, 仅用于测试
+}
+
+// New 按 sc.Options 创建 network sink, 首次连接在后台进行, 连接失败不影响创建
+func New(conf *log.LoggerConfig, sc log.SinkConfig) (log.Sink, error) {
+	var opts Options
+	if err := sc.Decode(&opts); err != nil {
+		return nil, err
+	}
+	if opts.Address == "" {
+		return nil, errors.New("address is required")
+	}
+	switch opts.Network {
+	case "":
+		opts.Network = "tcp"
+	case "tcp", "tcp4", "tcp6":
+	case "udp", "udp4", "udp6":
+		if opts.TLS != nil {
+			return nil, errors.New("tls is not supported over udp")
+		}
+	default:
+		return nil, fmt.Errorf("unknown network: %q", opts.Network)
+	}
+	frame, err := framer(opts.Framing)
+	if err != nil {
+		return nil, err
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = 10000
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.DialTimeoutMs <= 0 {
+		opts.DialTimeoutMs = 5000
+	}
+	if opts.WriteTimeoutMs <= 0 {
+		opts.WriteTimeoutMs = 5000
+	}
+	if opts.ReconnectMs <= 0 {
+		opts.ReconnectMs = 500
+	}
+	if opts.MaxReconnectMs <= 0 {
+		opts.MaxReconnectMs = 30000
+	}
+
+	var tlsConf *tls.Config
+	if opts.TLS != nil {
+		if tlsConf, err = opts.TLS.config(opts.Address); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &Sink{
+		opts:    opts,
+		tls:     tlsConf,
+		frame:   frame,
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	s.cond = sync.NewCond(&s.mu)
+	go s.run()
+	return s, nil
+}
+
+func (c *TLSConfig) config(address string) (*tls.Config, error) {
+	conf := &tls.Config{ServerName: c.ServerName, InsecureSkipVerify: c.InsecureSkipVerify}
+	if conf.ServerName == "" {
+		if host, _, err := net.SplitHostPort(address); err == nil {
+			conf.ServerName = host
+		}
+	}
+	if c.CAFile != "" {
+		pem, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		conf.RootCAs = x509.NewCertPool()
+		if !conf.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", c.CAFile)
+		}
+	}
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	return conf, nil
+}
+
+func framer(framing string) (func(line []byte) []byte, error) {
+	switch framing {
+	case "", FramingNewline:
+		return func(line []byte) []byte {
+			b := make([]byte, 0, len(line)+1)
+			return append(append(b, line...), '\n')
+		}, nil
+	case FramingLength:
+		return func(line []byte) []byte {
+			b := make([]byte, 4, len(line)+4)
+			binary.BigEndian.PutUint32(b, uint32(len(line)))
+			return append(b, line...)
+		}, nil
+	case FramingOctet:
+		return func(line []byte) []byte {
+			b := make([]byte, 0, len(line)+8)
+			b = strconv.AppendInt(b, int64(len(line)), 10)
+			return append(append(b, ' '), line...)
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown framing: %q", framing)
+}
+
+// Sink 写日志只追加到内存队列, 由后台 goroutine 负责连接和发送
+type Sink struct {
+	opts  Options
+	tls   *tls.Config
+	frame func(line []byte) []byte
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	queue    [][]byte
+	inflight int
+	closed   bool
+	dropped  int64
+
+	closing chan struct{}
+	done    chan struct{}
+	conn    net.Conn      // 只在后台 goroutine 中使用
+	eof     chan struct{} // 对端关闭 conn 后关闭
+}
+
+func (s *Sink) Write(ent zapcore.Entry, line []byte) error {
+	frame := s.frame(line)
+
+	s.mu.Lock()
+	// 队列满时丢弃最早的日志, 保留最近的日志
+	if n := len(s.queue) + s.inflight - s.opts.BufferSize + 1; n > 0 && len(s.queue) > 0 {
+		if n > len(s.queue) {
+			n = len(s.queue)
+		}
+		s.queue = s.queue[n:]
+		atomic.AddInt64(&s.dropped, int64(n))
+		log.GetSinkMetrics().Dropped("network", n)
+	}
+	s.queue = append(s.queue, frame)
+	s.cond.Signal()
+	s.mu.Unlock()
+	return nil
+}
+
+// Dropped 暂存队列满被丢弃的日志条数
+func (s *Sink) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+// Sync 等待暂存的日志发送完成, 连接不可用时最多等待 WriteTimeoutMs
+func (s *Sink) Sync() error {
+	timeout := time.Duration(s.opts.WriteTimeoutMs) * time.Millisecond
+	deadline := time.Now().Add(timeout)
+	for {
+		s.mu.Lock()
+		n := len(s.queue) + s.inflight
+		s.mu.Unlock()
+		if n == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%d log entries not sent to %s within %s", n, s.opts.Address, timeout)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Close 等待暂存的日志发送完成后关闭连接, 未发送的日志丢弃
+func (s *Sink) Close() error {
+	err := s.Sync()
+
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.closing)
+		s.cond.Broadcast()
+	}
+	s.mu.Unlock()
+	<-s.done
+	return err
+}
+
+func (s *Sink) run() {
+	defer close(s.done)
+	defer func() {
+		if s.conn != nil {
+			s.conn.Close()
+		}
+	}()
+
+	backoff := time.Duration(s.opts.ReconnectMs) * time.Millisecond
+	var lastErr error
+	for {
+		batch, ok := s.next()
+		if !ok {
+			return
+		}
+		start := time.Now()
+		err := s.send(batch)
+		log.GetSinkMetrics().FlushLatency("network", time.Since(start))
+		s.mu.Lock()
+		if err != nil {
+			// 放回队列头部等待重连后重新发送
+			s.queue = append(batch, s.queue...)
+		}
+		s.inflight = 0
+		s.mu.Unlock()
+		if err == nil {
+			if lastErr != nil {
+				fmt.Fprintf(os.Stderr, "network log sink: reconnected to %s\n", s.opts.Address)
+				lastErr = nil
+			}
+			backoff = time.Duration(s.opts.ReconnectMs) * time.Millisecond
+			continue
+		}
+
+		if lastErr == nil {
+			fmt.Fprintf(os.Stderr, "network log sink: send to %s failed, retrying: %v\n", s.opts.Address, err)
+		}
+		lastErr = err
+		select {
+		case <-time.After(backoff):
+		case <-s.closing:
+			return
+		}
+		if backoff *= 2; backoff > time.Duration(s.opts.MaxReconnectMs)*time.Millisecond {
+			backoff = time.Duration(s.opts.MaxReconnectMs) * time.Millisecond
+		}
+	}
+}
+
+// next 等待并取出一批日志, 关闭后返回 false
+func (s *Sink) next() ([][]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.queue) == 0 && !s.closed {
+		s.cond.Wait()
+	}
+	if s.closed {
+		return nil, false
+	}
+	n := len(s.queue)
+	if n > s.opts.BatchSize {
+		n = s.opts.BatchSize
+	}
+	batch := s.queue[:n:n]
+	s.queue = s.queue[n:]
+	s.inflight = n
+	log.GetSinkMetrics().QueueDepth("network", len(s.queue))
+	return batch, true
+}
+
+// send tcp 将一批日志合并为一次写入, udp 每条日志一个 datagram; 失败时关闭连接, 下次重新连接
+func (s *Sink) send(batch [][]byte) error {
+	if s.conn != nil {
+		select {
+		case <-s.eof:
+			s.conn.Close()
+			s.conn = nil
+		default:
+		}
+	}
+	if s.conn == nil {
+		conn, err := s.dial()
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+		s.eof = make(chan struct{})
+		if _, ok := conn.(*net.UDPConn); !ok {
+			go watch(conn, s.eof)
+		}
+	}
+
+	s.conn.SetWriteDeadline(time.Now().Add(time.Duration(s.opts.WriteTimeoutMs) * time.Millisecond))
+	var err error
+	if _, ok := s.conn.(*net.UDPConn); ok {
+		for _, frame := range batch {
+			if _, err = s.conn.Write(frame); err != nil {
+				break
+			}
+		}
+	} else {
+		// WriteTo 会修改 Buffers 中的切片, 复制一份以便失败后重新发送
+		bufs := append(net.Buffers(nil), batch...)
+		_, err = bufs.WriteTo(s.conn)
+	}
+	if err != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+	return err
+}
+
+// watch 对端不会发送数据, 读到 EOF 或错误说明连接已经关闭
+// 对端关闭后的第一次写入通常仍然成功, 提前发现可以避免这部分日志丢失
+func watch(conn net.Conn, eof chan struct{}) {
+	io.Copy(ioutil.Discard, conn)
+	close(eof)
+}
+
+func (s *Sink) dial() (net.Conn, error) {
+	d := &net.Dialer{Timeout: time.Duration(s.opts.DialTimeoutMs) * time.Millisecond, KeepAlive: 30 * time.Second}
+	if s.tls != nil {
+		return tls.DialWithDialer(d, s.opts.Network, s.opts.Address, s.tls)
+	}
+	return d.Dial(s.opts.Network, s.opts.Address)
+}
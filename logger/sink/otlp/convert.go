@@ -0,0 +1,204 @@
+package otlp
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"go.uber.org/zap/zapcore"
+
+	log "basic-middle/logger"
+	"basic-middle/logger/sink"
+)
+
+// scopeName 未命名的 logger 使用的 instrumentation scope
+const scopeName = "basic-middle/logger"
+
+// converter 将日志转换为 OTLP 请求, 每个 logger 名称对应一个 instrumentation scope
+type converter struct {
+	resource   *resourcepb.Resource
+	keys       log.EncoderKeys
+	traceIDKey string
+	spanIDKey  string
+}
+
+func newConverter(conf *log.LoggerConfig, opts Options) *converter {
+	attrs := map[string]interface{}{}
+	if conf.Project != "" {
+		attrs["service.name"] = conf.Project
+	}
+	if conf.Namespace != "" {
+		attrs["service.namespace"] = conf.Namespace
+	}
+	if host, err := os.Hostname(); err == nil {
+		attrs["host.name"] = host
+	}
+	for k, v := range opts.Resource {
+		attrs[k] = v
+	}
+
+	res := &resourcepb.Resource{}
+	for _, k := range sortedKeys(attrs) {
+		res.Attributes = append(res.Attributes, &commonpb.KeyValue{Key: k, Value: anyValue(attrs[k])})
+	}
+	return &converter{
+		resource:   res,
+		keys:       conf.Keys.WithDefaults(),
+		traceIDKey: opts.TraceIDKey,
+		spanIDKey:  opts.SpanIDKey,
+	}
+}
+
+func (c *converter) request(records []sink.Record) *collogspb.ExportLogsServiceRequest {
+	scopes := make(map[string]*logspb.ScopeLogs)
+	var order []*logspb.ScopeLogs
+	for _, r := range records {
+		name := r.Entry.LoggerName
+		if name == "" {
+			name = scopeName
+		}
+		sl, ok := scopes[name]
+		if !ok {
+			sl = &logspb.ScopeLogs{Scope: &commonpb.InstrumentationScope{Name: name}}
+			scopes[name] = sl
+			order = append(order, sl)
+		}
+		sl.LogRecords = append(sl.LogRecords, c.record(r))
+	}
+	return &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*logspb.ResourceLogs{{Resource: c.resource, ScopeLogs: order}},
+	}
+}
+
+func (c *converter) record(r sink.Record) *logspb.LogRecord {
+	ent := r.Entry
+	lr := &logspb.LogRecord{
+		TimeUnixNano:         uint64(ent.Time.UnixNano()),
+		ObservedTimeUnixNano: uint64(time.Now().UnixNano()),
+		SeverityNumber:       severity(ent.Level),
+		SeverityText:         ent.Level.CapitalString(),
+		Body:                 &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: ent.Message}},
+	}
+	if ent.Caller.Defined {
+		lr.Attributes = append(lr.Attributes,
+			stringAttr("code.filepath", ent.Caller.File),
+			&commonpb.KeyValue{Key: "code.lineno", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(ent.Caller.Line)}}},
+		)
+		if ent.Caller.Function != "" {
+			lr.Attributes = append(lr.Attributes, stringAttr("code.function", ent.Caller.Function))
+		}
+	}
+	if ent.Stack != "" {
+		lr.Attributes = append(lr.Attributes, stringAttr("exception.stacktrace", ent.Stack))
+	}
+
+	record, err := sink.DecodeLine(r.Line)
+	if err != nil {
+		return lr
+	}
+	for _, k := range sortedKeys(record) {
+		v := record[k]
+		switch k {
+		case c.keys.Time, c.keys.Level, c.keys.Name, c.keys.Caller, c.keys.Message, c.keys.Stacktrace:
+			continue
+		case c.traceIDKey:
+			if id, ok := decodeID(v, 16); ok {
+				lr.TraceId = id
+				continue
+			}
+		case c.spanIDKey:
+			if id, ok := decodeID(v, 8); ok {
+				lr.SpanId = id
+				continue
+			}
+		}
+		lr.Attributes = append(lr.Attributes, &commonpb.KeyValue{Key: k, Value: anyValue(v)})
+	}
+	return lr
+}
+
+// severity 按 OTel 日志数据模型的 SeverityNumber 映射
+func severity(l zapcore.Level) logspb.SeverityNumber {
+	switch l {
+	case zapcore.DebugLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG
+	case zapcore.InfoLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_INFO
+	case zapcore.WarnLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_WARN
+	case zapcore.ErrorLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_ERROR
+	case zapcore.DPanicLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_ERROR2
+	case zapcore.PanicLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_FATAL
+	case zapcore.FatalLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_FATAL4
+	}
+	return logspb.SeverityNumber_SEVERITY_NUMBER_UNSPECIFIED
+}
+
+// decodeID 十六进制的 trace id 或 span id, 长度不符或全 0 时视为无效, 按普通字段处理
+func decodeID(v interface{}, n int) ([]byte, bool) {
+	s, ok := v.(string)
+	if !ok || len(s) != n*2 {
+		return nil, false
+	}
+	id, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, false
+	}
+	for _, b := range id {
+		if b != 0 {
+			return id, true
+		}
+	}
+	return nil, false
+}
+
+func anyValue(v interface{}) *commonpb.AnyValue {
+	switch v := v.(type) {
+	case nil:
+		return &commonpb.AnyValue{}
+	case string:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v}}
+	case bool:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: v}}
+	case int64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: v}}
+	case float64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: v}}
+	case []interface{}:
+		arr := &commonpb.ArrayValue{Values: make([]*commonpb.AnyValue, 0, len(v))}
+		for _, e := range v {
+			arr.Values = append(arr.Values, anyValue(e))
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{ArrayValue: arr}}
+	case map[string]interface{}:
+		kv := &commonpb.KeyValueList{Values: make([]*commonpb.KeyValue, 0, len(v))}
+		for _, k := range sortedKeys(v) {
+			kv.Values = append(kv.Values, &commonpb.KeyValue{Key: k, Value: anyValue(v[k])})
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_KvlistValue{KvlistValue: kv}}
+	}
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: fmt.Sprint(v)}}
+}
+
+func stringAttr(k, v string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: k, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v}}}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
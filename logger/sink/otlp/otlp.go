@@ -0,0 +1,210 @@
+// Package otlp 通过 OTLP/gRPC 或 OTLP/HTTP 将日志发送到 OpenTelemetry Collector, 空导入后在 LoggerConfig.Sinks 中配置 type 为 otlp
+// 日志字段转换为 OTel 属性, trace_id 和 span_id 字段写入 LogRecord 的 trace context
+//
+//	sinks:
+//	  - type: otlp
+//	    options: {protocol: grpc, endpoint: "otel-collector:4317", insecure: true}
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	"go.uber.org/multierr"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	log "basic-middle/logger"
+	"basic-middle/logger/sink"
+)
+
+func init() {
+	log.RegisterSink("otlp", New)
+}
+
+// 传输协议
+const (
+	ProtocolGRPC = "grpc"
+	ProtocolHTTP = "http"
+)
+
+// Options otlp sink 的配置
+type Options struct {
+	sink.BatchConfig
+	Protocol   string            `json:"protocol"`     //grpc|http, 默认 grpc
+	Endpoint   string            `json:"endpoint"`     //grpc 默认 localhost:4317, http 默认 http://localhost:4318/v1/logs
+	Insecure   bool              `json:"insecure"`     //grpc 不使用 TLS
+	Headers    map[string]string `json:"headers"`      //附加的请求头或 gRPC metadata, 如认证信息
+	TimeoutMs  int               `json:"timeout_ms"`   //请求超时毫秒数, 默认 10000
+	Resource   map[string]string `json:"resource"`     //附加的 resource 属性, 默认已包含 service.name、service.namespace、host.name
+	TraceIDKey string            `json:"trace_id_key"` //trace id 字段名, 默认 trace_id
+	SpanIDKey  string            `json:"span_id_key"`  //span id 字段名, 默认 span_id
+}
+
+// New 按 sc.Options 创建 otlp sink
+func New(conf *log.LoggerConfig, sc log.SinkConfig) (log.Sink, error) {
+	var opts Options
+	if err := sc.Decode(&opts); err != nil {
+		return nil, err
+	}
+	if opts.TraceIDKey == "" {
+		opts.TraceIDKey = "trace_id"
+	}
+	if opts.SpanIDKey == "" {
+		opts.SpanIDKey = "span_id"
+	}
+	timeout := time.Duration(opts.TimeoutMs) * time.Millisecond
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	var (
+		e   exporter
+		err error
+	)
+	switch opts.Protocol {
+	case "", ProtocolGRPC:
+		e, err = newGRPCExporter(opts, timeout)
+	case ProtocolHTTP:
+		e = newHTTPExporter(opts)
+	default:
+		return nil, fmt.Errorf("unknown otlp protocol: %q", opts.Protocol)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := newConverter(conf, opts)
+	flush := func(records []sink.Record) error {
+		return e.export(c.request(records))
+	}
+	return &Sink{Batcher: sink.NewBatcher("otlp", opts.BatchConfig, flush), exporter: e}, nil
+}
+
+// Sink 关闭时先发送剩余的日志再关闭连接
+type Sink struct {
+	*sink.Batcher
+	exporter exporter
+}
+
+func (s *Sink) Close() error {
+	return multierr.Append(s.Batcher.Close(), s.exporter.Close())
+}
+
+type exporter interface {
+	export(req *collogspb.ExportLogsServiceRequest) error
+	io.Closer
+}
+
+type grpcExporter struct {
+	conn    *grpc.ClientConn
+	client  collogspb.LogsServiceClient
+	md      metadata.MD
+	timeout time.Duration
+}
+
+func newGRPCExporter(opts Options, timeout time.Duration) (*grpcExporter, error) {
+	endpoint := opts.Endpoint
+	if endpoint == "" {
+		endpoint = "localhost:4317"
+	}
+	creds := credentials.NewTLS(nil)
+	if opts.Insecure {
+		creds = insecure.NewCredentials()
+	}
+	// 不阻塞等待连接建立, collector 暂时不可用时由 Batcher 重试
+	conn, err := grpc.Dial(endpoint, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, err
+	}
+	return &grpcExporter{
+		conn:    conn,
+		client:  collogspb.NewLogsServiceClient(conn),
+		md:      metadata.New(opts.Headers),
+		timeout: timeout,
+	}, nil
+}
+
+func (e *grpcExporter) export(req *collogspb.ExportLogsServiceRequest) error {
+	ctx, cancel := context.WithTimeout(metadata.NewOutgoingContext(context.Background(), e.md), e.timeout)
+	defer cancel()
+
+	resp, err := e.client.Export(ctx, req)
+	if err != nil {
+		return &grpcError{err: err}
+	}
+	partialSuccess(resp)
+	return nil
+}
+
+func (e *grpcExporter) Close() error {
+	return e.conn.Close()
+}
+
+// grpcError OTLP 规范中可以重试的状态码
+type grpcError struct {
+	err error
+}
+
+func (e *grpcError) Error() string { return e.err.Error() }
+
+func (e *grpcError) Retryable() bool {
+	switch status.Code(e.err) {
+	case codes.Canceled, codes.DeadlineExceeded, codes.Aborted, codes.OutOfRange,
+		codes.Unavailable, codes.DataLoss, codes.ResourceExhausted:
+		return true
+	}
+	return false
+}
+
+type httpExporter struct {
+	client  *http.Client
+	url     string
+	headers map[string]string
+}
+
+func newHTTPExporter(opts Options) *httpExporter {
+	url := opts.Endpoint
+	if url == "" {
+		url = "http://localhost:4318/v1/logs"
+	}
+	return &httpExporter{client: sink.NewHTTPClient(opts.TimeoutMs), url: url, headers: opts.Headers}
+}
+
+func (e *httpExporter) export(req *collogspb.ExportLogsServiceRequest) error {
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+	r, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/x-protobuf")
+	for k, v := range e.headers {
+		r.Header.Set(k, v)
+	}
+	return sink.Do(e.client, r)
+}
+
+func (e *httpExporter) Close() error {
+	return nil
+}
+
+// partialSuccess collector 拒绝了部分日志时输出到 stderr, 重试也不会成功
+func partialSuccess(resp *collogspb.ExportLogsServiceResponse) {
+	if ps := resp.GetPartialSuccess(); ps.GetRejectedLogRecords() > 0 {
+		fmt.Fprintf(os.Stderr, "otlp log sink: %d records rejected: %s\n", ps.GetRejectedLogRecords(), ps.GetErrorMessage())
+	}
+}
@@ -0,0 +1,32 @@
+package sink
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// DecodeLine 将 JSON 编码的日志解析为 map, 整数保持为 int64 不转为 float64
+func DecodeLine(line []byte) (map[string]interface{}, error) {
+	record := make(map[string]interface{})
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	if err := dec.Decode(&record); err != nil {
+		return nil, err
+	}
+	for k, v := range record {
+		if n, ok := v.(json.Number); ok {
+			record[k] = numberValue(n)
+		}
+	}
+	return record, nil
+}
+
+func numberValue(n json.Number) interface{} {
+	if i, err := n.Int64(); err == nil {
+		return i
+	}
+	if f, err := n.Float64(); err == nil {
+		return f
+	}
+	return n.String()
+}
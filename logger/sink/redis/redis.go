@@ -0,0 +1,114 @@
+// Package redis 将日志写入 Redis Stream, 空导入后在 LoggerConfig.Sinks 中配置 type 为 redis
+// 每条日志一个 stream 消息, 包含 level、logger、msg 和完整 JSON 的 data 字段, 可用 XREAD 实时查看或用消费组处理
+//
+//	sinks:
+//	  - type: redis
+//	    options: {addrs: ["redis:6379"], stream: "logs:{namespace}:{project}", max_len: 100000}
+package redis
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/multierr"
+
+	log "basic-middle/logger"
+	"basic-middle/logger/sink"
+)
+
+func init() {
+	log.RegisterSink("redis", New)
+}
+
+// Options redis sink 的配置
+type Options struct {
+	sink.BatchConfig
+	Addrs       []string `json:"addrs"`         //地址, 默认 localhost:6379, 多个地址时使用集群模式
+	MasterName  string   `json:"master_name"`   //哨兵模式的 master 名称, 设置后 addrs 为哨兵地址
+	Username    string   `json:"username"`      //用户名, Redis 6 ACL
+	Password    string   `json:"password"`      //密码
+	DB          int      `json:"db"`            //数据库, 集群模式不支持
+	Stream      string   `json:"stream"`        //stream 名称, 支持 {namespace}、{project}、{level} 占位符, 默认 logs:{namespace}:{project}
+	MaxLen      int64    `json:"max_len"`       //每个 stream 保留的最大消息数, 默认 100000, 为负数时不限制
+	ExactMaxLen bool     `json:"exact_max_len"` //精确裁剪到 max_len, 默认近似裁剪(MAXLEN ~)性能更好
+	TimeoutMs   int      `json:"timeout_ms"`    //每批写入的超时毫秒数, 默认 5000
+}
+
+// New 按 sc.Options 创建 redis sink
+func New(conf *log.LoggerConfig, sc log.SinkConfig) (log.Sink, error) {
+	var opts Options
+	if err := sc.Decode(&opts); err != nil {
+		return nil, err
+	}
+	if len(opts.Addrs) == 0 {
+		opts.Addrs = []string{"localhost:6379"}
+	}
+	if opts.Stream == "" {
+		opts.Stream = "logs:{namespace}:{project}"
+	}
+	if opts.MaxLen == 0 {
+		opts.MaxLen = 100000
+	} else if opts.MaxLen < 0 {
+		opts.MaxLen = 0
+	}
+	if opts.TimeoutMs <= 0 {
+		opts.TimeoutMs = 5000
+	}
+
+	client := redis.NewUniversalClient(&redis.UniversalOptions{
+		Addrs:      opts.Addrs,
+		MasterName: opts.MasterName,
+		Username:   opts.Username,
+		Password:   opts.Password,
+		DB:         opts.DB,
+	})
+	w := &writer{
+		client:  client,
+		opts:    opts,
+		stream:  strings.NewReplacer("{namespace}", conf.Namespace, "{project}", conf.Project).Replace(opts.Stream),
+		timeout: time.Duration(opts.TimeoutMs) * time.Millisecond,
+	}
+	return &Sink{Batcher: sink.NewBatcher("redis", opts.BatchConfig, w.flush), client: client}, nil
+}
+
+// Sink 关闭时先写完剩余的日志再关闭连接
+type Sink struct {
+	*sink.Batcher
+	client redis.UniversalClient
+}
+
+func (s *Sink) Close() error {
+	return multierr.Append(s.Batcher.Close(), s.client.Close())
+}
+
+type writer struct {
+	client  redis.UniversalClient
+	opts    Options
+	stream  string // 已替换 namespace 和 project
+	timeout time.Duration
+}
+
+// flush 一批日志通过 pipeline 一次发送
+func (w *writer) flush(records []sink.Record) error {
+	ctx, cancel := context.WithTimeout(context.Background(), w.timeout)
+	defer cancel()
+
+	pipe := w.client.Pipeline()
+	for _, r := range records {
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: strings.Replace(w.stream, "{level}", r.Entry.Level.String(), -1),
+			MaxLen: w.opts.MaxLen,
+			Approx: !w.opts.ExactMaxLen,
+			Values: []interface{}{
+				"level", r.Entry.Level.String(),
+				"logger", r.Entry.LoggerName,
+				"msg", r.Entry.Message,
+				"data", r.Line,
+			},
+		})
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
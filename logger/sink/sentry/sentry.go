@@ -0,0 +1,181 @@
+// Package sentry 将 error 及以上等级的日志作为事件上报到 Sentry, 按消息聚合并告警
+// 空导入后在 LoggerConfig.Sinks 中配置 type 为 sentry
+//
+//	sinks:
+//	  - type: sentry
+//	    options: {dsn: "https://key@sentry.example.com/1", environment: prod, rate_limit: 10}
+package sentry
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"go.uber.org/zap/zapcore"
+
+	log "basic-middle/logger"
+	"basic-middle/logger/sink"
+)
+
+func init() {
+	log.RegisterSink("sentry", New)
+}
+
+// Options sentry sink 的配置, SinkConfig.Level 为空时只上报 error 及以上等级
+type Options struct {
+	DSN            string  `json:"dsn"`              //sentry DSN
+	Environment    string  `json:"environment"`      //环境, 默认 LoggerConfig.Environment
+	Release        string  `json:"release"`          //版本, 默认 LoggerConfig.Fields 中的 version
+	SampleRate     float64 `json:"sample_rate"`      //采样率 0~1, 默认 1
+	RateLimit      int     `json:"rate_limit"`       //每秒最多上报的事件数, 默认 10, 超出的丢弃
+	FlushTimeoutMs int     `json:"flush_timeout_ms"` //Sync 和 Close 时等待发送完成的毫秒数, 默认 2000
+}
+
+// Sink 在写日志的 goroutine 中生成堆栈, 由 sentry 的 transport 异步发送
+type Sink struct {
+	client  *sentry.Client
+	keys    log.EncoderKeys
+	limit   int
+	flush   time.Duration
+	mu      sync.Mutex
+	window  time.Time
+	count   int
+	dropped int64
+}
+
+// New 按 sc.Options 创建 sentry sink
+func New(conf *log.LoggerConfig, sc log.SinkConfig) (log.Sink, error) {
+	var opts Options
+	if err := sc.Decode(&opts); err != nil {
+		return nil, err
+	}
+	if opts.DSN == "" {
+		return nil, errors.New("dsn is required")
+	}
+	if opts.Environment == "" {
+		opts.Environment = conf.Environment
+	}
+	if opts.Release == "" {
+		opts.Release = conf.Fields["version"]
+	}
+	if opts.RateLimit <= 0 {
+		opts.RateLimit = 10
+	}
+	if opts.FlushTimeoutMs <= 0 {
+		opts.FlushTimeoutMs = 2000
+	}
+
+	client, err := sentry.NewClient(sentry.ClientOptions{
+		Dsn:         opts.DSN,
+		Environment: opts.Environment,
+		Release:     opts.Release,
+		SampleRate:  opts.SampleRate,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Sink{
+		client: client,
+		keys:   conf.Keys.WithDefaults(),
+		limit:  opts.RateLimit,
+		flush:  time.Duration(opts.FlushTimeoutMs) * time.Millisecond,
+	}, nil
+}
+
+// DefaultLevel 未配置 SinkConfig.Level 时只上报 error 及以上等级
+func (s *Sink) DefaultLevel() zapcore.Level {
+	return zapcore.ErrorLevel
+}
+
+var levels = map[zapcore.Level]sentry.Level{
+	zapcore.DebugLevel:  sentry.LevelDebug,
+	zapcore.InfoLevel:   sentry.LevelInfo,
+	zapcore.WarnLevel:   sentry.LevelWarning,
+	zapcore.ErrorLevel:  sentry.LevelError,
+	zapcore.DPanicLevel: sentry.LevelFatal,
+	zapcore.PanicLevel:  sentry.LevelFatal,
+	zapcore.FatalLevel:  sentry.LevelFatal,
+}
+
+// allow 每秒的固定窗口限流, 避免错误风暴时打满 sentry 的配额
+func (s *Sink) allow(now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.window) >= time.Second {
+		s.window, s.count = now, 0
+	}
+	if s.count >= s.limit {
+		return false
+	}
+	s.count++
+	return true
+}
+
+func (s *Sink) Write(ent zapcore.Entry, line []byte) error {
+	if !s.allow(time.Now()) {
+		atomic.AddInt64(&s.dropped, 1)
+		log.GetSinkMetrics().Dropped("sentry", 1)
+		return nil
+	}
+	record, err := sink.DecodeLine(line)
+	if err != nil {
+		return err
+	}
+
+	event := sentry.NewEvent()
+	event.Level = levels[ent.Level]
+	event.Message = ent.Message
+	event.Timestamp = ent.Time
+	event.Logger = ent.LoggerName
+	for k, v := range record {
+		switch k {
+		case s.keys.Time, s.keys.Level, s.keys.Message, s.keys.Stacktrace:
+			continue
+		}
+		event.Extra[k] = v
+	}
+
+	// 以 error 字段作为异常的描述, 堆栈去掉 zap 和日志包自身的栈帧
+	value, _ := record["error"].(string)
+	event.Exception = []sentry.Exception{{
+		Type:       ent.Message,
+		Value:      value,
+		Stacktrace: callerStacktrace(),
+	}}
+	s.client.CaptureEvent(event, nil, nil)
+	return nil
+}
+
+func callerStacktrace() *sentry.Stacktrace {
+	st := sentry.NewStacktrace()
+	if st == nil {
+		return nil
+	}
+	frames := st.Frames[:0]
+	for _, f := range st.Frames {
+		if strings.HasPrefix(f.Module, "go.uber.org/zap") || strings.HasPrefix(f.Module, "basic-middle/logger") {
+			continue
+		}
+		frames = append(frames, f)
+	}
+	st.Frames = frames
+	return st
+}
+
+// Dropped 超过限流被丢弃的事件数
+func (s *Sink) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+func (s *Sink) Sync() error {
+	s.client.Flush(s.flush)
+	return nil
+}
+
+func (s *Sink) Close() error {
+	s.client.Flush(s.flush)
+	return nil
+}
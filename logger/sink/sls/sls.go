@@ -0,0 +1,181 @@
+// Package sls 通过阿里云日志服务的 producer 将日志写入 SLS logstore, 空导入后在 LoggerConfig.Sinks 中配置 type 为 sls
+// 批量、LZ4 压缩和失败重试由 producer 完成
+//
+//	sinks:
+//	  - type: sls
+//	    options: {endpoint: cn-hangzhou.log.aliyuncs.com, project: my-project, logstore: app, access_key_id: xxx, access_key_secret: xxx}
+package sls
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	sls "github.com/aliyun/aliyun-log-go-sdk"
+	"github.com/aliyun/aliyun-log-go-sdk/producer"
+	"go.uber.org/zap/zapcore"
+
+	log "basic-middle/logger"
+	"basic-middle/logger/sink"
+)
+
+func init() {
+	log.RegisterSink("sls", New)
+}
+
+// Options sls sink 的配置
+type Options struct {
+	Endpoint        string `json:"endpoint"`          //服务入口, 如 cn-hangzhou.log.aliyuncs.com, 同地域 ecs 可使用内网入口
+	Project         string `json:"project"`           //sls project
+	Logstore        string `json:"logstore"`          //logstore
+	AccessKeyID     string `json:"access_key_id"`     //AccessKey ID
+	AccessKeySecret string `json:"access_key_secret"` //AccessKey Secret
+	Topic           string `json:"topic"`             //日志主题, 默认 LoggerConfig.Project
+	Source          string `json:"source"`            //日志来源, 默认本机 hostname
+	LingerMs        int64  `json:"linger_ms"`         //一批日志的最长等待毫秒数, 默认 2000
+	MaxBatchCount   int    `json:"max_batch_count"`   //每批最多条数, 默认 4096
+	Retries         int    `json:"retries"`           //发送失败的重试次数, 默认 10
+	MaxBlockSec     int    `json:"max_block_sec"`     //缓冲区满时写入方最多阻塞的秒数, 超时后丢弃, 默认 0 即立即丢弃并计入 Dropped
+	SyncTimeoutMs   int    `json:"sync_timeout_ms"`   //Sync 等待已写入的日志回调的最长毫秒数, 默认 5000
+}
+
+// Sink 使用 sls producer 异步发送
+type Sink struct {
+	opts     Options
+	producer *producer.Producer
+	timeout  time.Duration
+	dropped  int64
+	failed   int64
+
+	mu      sync.Mutex
+	pending int // 已交给 producer 还未回调的日志数
+}
+
+// New 按 sc.Options 创建 sls sink
+func New(conf *log.LoggerConfig, sc log.SinkConfig) (log.Sink, error) {
+	var opts Options
+	if err := sc.Decode(&opts); err != nil {
+		return nil, err
+	}
+	if opts.Endpoint == "" || opts.Project == "" || opts.Logstore == "" {
+		return nil, errors.New("endpoint, project and logstore are required")
+	}
+	if opts.Topic == "" {
+		opts.Topic = conf.Project
+	}
+	if opts.Source == "" {
+		opts.Source, _ = os.Hostname()
+	}
+
+	pc := producer.GetDefaultProducerConfig()
+	pc.Endpoint = opts.Endpoint
+	pc.CredentialsProvider = sls.NewStaticCredentialsProvider(opts.AccessKeyID, opts.AccessKeySecret, "")
+	pc.AllowLogLevel = "warn"
+	if opts.LingerMs > 0 {
+		pc.LingerMs = opts.LingerMs
+	}
+	if opts.MaxBatchCount > 0 {
+		pc.MaxBatchCount = opts.MaxBatchCount
+	}
+	if opts.Retries > 0 {
+		pc.Retries = opts.Retries
+	}
+	// sdk 默认阻塞 60 秒, 小于 0 时一直阻塞, 这里默认不阻塞
+	pc.MaxBlockSec = opts.MaxBlockSec
+	if pc.MaxBlockSec < 0 {
+		pc.MaxBlockSec = 0
+	}
+	if opts.SyncTimeoutMs <= 0 {
+		opts.SyncTimeoutMs = 5000
+	}
+
+	p := producer.InitProducer(pc)
+	p.Start()
+	return &Sink{opts: opts, producer: p, timeout: time.Duration(opts.SyncTimeoutMs) * time.Millisecond}, nil
+}
+
+// Write 日志的每个字段作为 sls 的一个 key, 非字符串的值编码为 JSON
+func (s *Sink) Write(ent zapcore.Entry, line []byte) error {
+	record, err := sink.DecodeLine(line)
+	if err != nil {
+		return err
+	}
+	contents := make(map[string]string, len(record))
+	for k, v := range record {
+		if str, ok := v.(string); ok {
+			contents[k] = str
+			continue
+		}
+		b, _ := json.Marshal(v)
+		contents[k] = string(b)
+	}
+
+	l := producer.GenerateLog(uint32(ent.Time.Unix()), contents)
+	s.mu.Lock()
+	s.pending++
+	s.mu.Unlock()
+	if err := s.producer.SendLogWithCallBack(s.opts.Project, s.opts.Logstore, s.opts.Topic, s.opts.Source, l, (*callback)(s)); err != nil {
+		// 缓冲区满或 producer 已关闭
+		atomic.AddInt64(&s.dropped, 1)
+		log.GetSinkMetrics().Dropped("sls", 1)
+		s.done1()
+	}
+	return nil
+}
+
+// Sync producer 按 LingerMs 定时发送, 没有主动刷新的接口, 等待已写入的日志回调
+// 发送失败时 producer 会按 Retries 重试, 最多等待 SyncTimeoutMs
+func (s *Sink) Sync() error {
+	deadline := time.Now().Add(s.timeout)
+	for {
+		s.mu.Lock()
+		n := s.pending
+		s.mu.Unlock()
+		if n == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%d log entries not sent to sls within %s", n, s.timeout)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Close 最多等待 10 秒发送剩余的日志
+func (s *Sink) Close() error {
+	return s.producer.Close(10000)
+}
+
+// Dropped 缓冲区满被丢弃的日志条数
+func (s *Sink) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+// Failed producer 重试后仍发送失败的日志条数
+func (s *Sink) Failed() int64 {
+	return atomic.LoadInt64(&s.failed)
+}
+
+func (s *Sink) done1() {
+	s.mu.Lock()
+	s.pending--
+	s.mu.Unlock()
+}
+
+// callback 重试后仍失败的日志输出到 stderr
+type callback Sink
+
+func (c *callback) Success(*producer.Result) {
+	(*Sink)(c).done1()
+}
+
+func (c *callback) Fail(r *producer.Result) {
+	atomic.AddInt64(&c.failed, 1)
+	log.GetSinkMetrics().Failed("sls", 1)
+	fmt.Fprintf(os.Stderr, "sls log sink: %s: %s\n", r.GetErrorCode(), r.GetErrorMessage())
+	(*Sink)(c).done1()
+}
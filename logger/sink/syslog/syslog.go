@@ -0,0 +1,188 @@
+// Package syslog 以 RFC5424 格式将日志写入本机 /dev/log 或远程 syslog
+// 空导入后在 LoggerConfig.Sinks 中配置 type 为 syslog
+//
+//	sinks:
+//	  - type: syslog
+//	    options: {network: udp, address: "syslog:514", facility: local3}
+package syslog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+
+	log "basic-middle/logger"
+)
+
+func init() {
+	log.RegisterSink("syslog", New)
+}
+
+// Options syslog sink 的配置
+type Options struct {
+	Network   string `json:"network"`    //unix|udp|tcp, 默认 unix
+	Address   string `json:"address"`    //地址, unix 默认依次尝试 /dev/log、/var/run/syslog、/var/run/log
+	Facility  string `json:"facility"`   //facility 名称, 如 user、daemon、local0~local7, 默认 local0
+	AppName   string `json:"app_name"`   //APP-NAME, 默认 project
+	TimeoutMs int    `json:"timeout_ms"` //连接和写入超时毫秒数, 默认 3000
+}
+
+var facilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// severity zap 等级对应的 syslog severity
+var severity = map[zapcore.Level]int{
+	zapcore.DebugLevel:  7,
+	zapcore.InfoLevel:   6,
+	zapcore.WarnLevel:   4,
+	zapcore.ErrorLevel:  3,
+	zapcore.DPanicLevel: 2,
+	zapcore.PanicLevel:  1,
+	zapcore.FatalLevel:  0,
+}
+
+// Sink 同步写入 syslog, 写入失败时重连一次
+type Sink struct {
+	mu       sync.Mutex
+	opts     Options
+	facility int
+	hostname string
+	appName  string
+	pid      string
+	timeout  time.Duration
+	conn     net.Conn
+}
+
+// New 按 sc.Options 创建 syslog sink
+func New(conf *log.LoggerConfig, sc log.SinkConfig) (log.Sink, error) {
+	var opts Options
+	if err := sc.Decode(&opts); err != nil {
+		return nil, err
+	}
+	if opts.Network == "" {
+		opts.Network = "unix"
+	}
+	if opts.Facility == "" {
+		opts.Facility = "local0"
+	}
+	facility, ok := facilities[opts.Facility]
+	if !ok {
+		return nil, fmt.Errorf("unknown facility: %q", opts.Facility)
+	}
+
+	s := &Sink{
+		opts:     opts,
+		facility: facility,
+		hostname: nilValue(hostname()),
+		appName:  nilValue(opts.AppName),
+		pid:      strconv.Itoa(os.Getpid()),
+		timeout:  time.Duration(opts.TimeoutMs) * time.Millisecond,
+	}
+	if opts.AppName == "" {
+		s.appName = nilValue(conf.Project)
+	}
+	if s.timeout <= 0 {
+		s.timeout = 3 * time.Second
+	}
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func hostname() string {
+	h, _ := os.Hostname()
+	return h
+}
+
+// nilValue RFC5424 中空的头部字段使用 -
+func nilValue(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func (s *Sink) connect() error {
+	if s.opts.Network != "unix" {
+		conn, err := net.DialTimeout(s.opts.Network, s.opts.Address, s.timeout)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+		return nil
+	}
+
+	addrs := []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+	if s.opts.Address != "" {
+		addrs = []string{s.opts.Address}
+	}
+	for _, addr := range addrs {
+		for _, network := range []string{"unixgram", "unix"} {
+			if conn, err := net.DialTimeout(network, addr, s.timeout); err == nil {
+				s.conn = conn
+				return nil
+			}
+		}
+	}
+	return errors.New("unix syslog delivery error")
+}
+
+// Write 写入一条 RFC5424 消息, MSG 为 JSON 编码的日志; tcp 使用 octet counting 分帧
+func (s *Sink) Write(ent zapcore.Entry, line []byte) error {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "<%d>1 %s %s %s %s - - ",
+		s.facility*8+severity[ent.Level], ent.Time.Format(time.RFC3339Nano), s.hostname, s.appName, s.pid)
+	msg.Write(line)
+
+	frame := msg.Bytes()
+	if s.opts.Network == "tcp" {
+		frame = append([]byte(strconv.Itoa(msg.Len())+" "), frame...)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn != nil {
+		if err := s.write(frame); err == nil {
+			return nil
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+	if err := s.connect(); err != nil {
+		return err
+	}
+	return s.write(frame)
+}
+
+func (s *Sink) write(frame []byte) error {
+	s.conn.SetWriteDeadline(time.Now().Add(s.timeout))
+	_, err := s.conn.Write(frame)
+	return err
+}
+
+func (s *Sink) Sync() error {
+	return nil
+}
+
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
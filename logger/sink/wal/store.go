@@ -0,0 +1,289 @@
+package wal
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.uber.org/multierr"
+)
+
+const (
+	segmentExt     = ".wal"
+	checkpointFile = "checkpoint"
+	headerSize     = 8 // 4 字节长度 + 4 字节 crc32
+)
+
+// position 读取位置, 即 Seg 号段文件中的偏移
+type position struct {
+	Seg    uint64 `json:"seg"`
+	Offset int64  `json:"offset"`
+}
+
+// store 目录下按序号命名的段文件, 每条记录以 长度+crc32+数据 的帧追加写入
+// 每次启动都写入新的段, 进程退出时未写完的帧只会出现在旧段的末尾, 读取时跳过
+type store struct {
+	dir         string
+	segmentSize int64
+	maxSize     int64
+
+	mu    sync.Mutex
+	segs  []uint64         // 未确认完的段, 最后一个是正在写入的段
+	sizes map[uint64]int64 // 各段的大小
+	total int64
+	w     *os.File
+	buf   []byte
+}
+
+// openStore 打开 dir 下已有的段并创建新的写入段, 返回上次确认的位置
+func openStore(dir string, segmentSize, maxSize int64) (*store, position, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, position{}, err
+	}
+	s := &store{dir: dir, segmentSize: segmentSize, maxSize: maxSize, sizes: make(map[uint64]int64)}
+
+	pos, err := s.loadCheckpoint()
+	if err != nil {
+		return nil, position{}, err
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, position{}, err
+	}
+	for _, fi := range files {
+		id, ok := segmentID(fi.Name())
+		if !ok {
+			continue
+		}
+		if id < pos.Seg {
+			os.Remove(filepath.Join(dir, fi.Name()))
+			continue
+		}
+		s.segs = append(s.segs, id)
+		s.sizes[id] = fi.Size()
+		s.total += fi.Size()
+	}
+	sort.Slice(s.segs, func(i, j int) bool { return s.segs[i] < s.segs[j] })
+
+	next := pos.Seg + 1
+	if n := len(s.segs); n > 0 {
+		next = s.segs[n-1] + 1
+	}
+	if err := s.create(next); err != nil {
+		return nil, position{}, err
+	}
+	return s, pos, nil
+}
+
+func segmentID(name string) (uint64, bool) {
+	if !strings.HasSuffix(name, segmentExt) {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+	return id, err == nil
+}
+
+func (s *store) path(id uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", id, segmentExt))
+}
+
+// create 创建并切换到新的段, 调用方持有 mu
+func (s *store) create(id uint64) error {
+	f, err := os.OpenFile(s.path(id), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if s.w != nil {
+		s.w.Close()
+	}
+	s.w = f
+	s.segs = append(s.segs, id)
+	s.sizes[id] = 0
+	return nil
+}
+
+func (s *store) active() uint64 {
+	return s.segs[len(s.segs)-1]
+}
+
+// append 追加一条记录, 总大小超过 maxSize 时删除最旧的段, 返回删除的段数
+func (s *store) append(data []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := int64(headerSize + len(data))
+	if size := s.sizes[s.active()]; size > 0 && size+n > s.segmentSize {
+		if err := s.create(s.active() + 1); err != nil {
+			return 0, err
+		}
+	}
+
+	s.buf = append(s.buf[:0], make([]byte, headerSize)...)
+	binary.BigEndian.PutUint32(s.buf[0:4], uint32(len(data)))
+	binary.BigEndian.PutUint32(s.buf[4:8], crc32.ChecksumIEEE(data))
+	s.buf = append(s.buf, data...)
+	if _, err := s.w.Write(s.buf); err != nil {
+		return 0, err
+	}
+	s.sizes[s.active()] += n
+	s.total += n
+
+	dropped := 0
+	for s.total > s.maxSize && len(s.segs) > 1 {
+		s.remove()
+		dropped++
+	}
+	return dropped, nil
+}
+
+// remove 删除最旧的段, 调用方持有 mu
+func (s *store) remove() {
+	id := s.segs[0]
+	os.Remove(s.path(id))
+	s.total -= s.sizes[id]
+	delete(s.sizes, id)
+	s.segs = s.segs[1:]
+}
+
+// sync 将正在写入的段刷到磁盘
+func (s *store) sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Sync()
+}
+
+func (s *store) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return multierr.Append(s.w.Sync(), s.w.Close())
+}
+
+// read 从 pos 开始最多读取 n 条记录, 返回读取后的位置
+// pos 所在的段已被删除时从下一个段开始, 旧段末尾不完整或校验失败的帧连同该段剩余部分一起跳过
+func (s *store) read(pos position, n int) ([][]byte, position, error) {
+	var records [][]byte
+	for len(records) < n {
+		seg, limit, last, ok := s.locate(pos.Seg)
+		if !ok {
+			return records, pos, nil
+		}
+		if seg != pos.Seg {
+			pos = position{Seg: seg}
+		}
+
+		recs, off, err := readSegment(s.path(seg), pos.Offset, limit, n-len(records))
+		records = append(records, recs...)
+		pos.Offset = off
+		switch {
+		case err != nil && !errors.Is(err, errCorrupt):
+			return records, pos, err
+		case last:
+			// 正在写入的段按已写入的大小读取, 不会读到不完整的帧
+			return records, pos, nil
+		case err == nil && off < limit:
+			return records, pos, nil
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "wal log sink: skip %s from offset %d: %v\n", s.path(seg), off, err)
+		}
+		pos = position{Seg: seg + 1}
+	}
+	return records, pos, nil
+}
+
+// locate 查找不小于 id 的第一个段, 返回段号、当前大小和是否为正在写入的段
+func (s *store) locate(id uint64) (seg uint64, size int64, last bool, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, seg := range s.segs {
+		if seg >= id {
+			return seg, s.sizes[seg], i == len(s.segs)-1, true
+		}
+	}
+	return 0, 0, false, false
+}
+
+var errCorrupt = errors.New("corrupt record")
+
+// readSegment 从 offset 开始读取 limit 之前的完整帧, 最多 n 条
+func readSegment(path string, offset, limit int64, n int) ([][]byte, int64, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		// 超过 maxSize 被删除
+		return nil, offset, errCorrupt
+	}
+	if err != nil {
+		return nil, offset, err
+	}
+	defer f.Close()
+
+	var (
+		records [][]byte
+		header  [headerSize]byte
+	)
+	for len(records) < n && offset < limit {
+		if offset+headerSize > limit {
+			return records, offset, errCorrupt
+		}
+		if _, err := f.ReadAt(header[:], offset); err != nil {
+			return records, offset, errCorrupt
+		}
+		size := int64(binary.BigEndian.Uint32(header[0:4]))
+		if offset+headerSize+size > limit {
+			return records, offset, errCorrupt
+		}
+		data := make([]byte, size)
+		if _, err := f.ReadAt(data, offset+headerSize); err != nil && err != io.EOF {
+			return records, offset, errCorrupt
+		}
+		if crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(header[4:8]) {
+			return records, offset, errCorrupt
+		}
+		records = append(records, data)
+		offset += headerSize + size
+	}
+	return records, offset, nil
+}
+
+// commit 记录已确认的位置并删除之前的段
+func (s *store) commit(pos position) error {
+	b, _ := json.Marshal(pos)
+	tmp := filepath.Join(s.dir, checkpointFile+".tmp")
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(s.dir, checkpointFile)); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.segs) > 1 && s.segs[0] < pos.Seg {
+		s.remove()
+	}
+	return nil
+}
+
+func (s *store) loadCheckpoint() (position, error) {
+	var pos position
+	b, err := ioutil.ReadFile(filepath.Join(s.dir, checkpointFile))
+	if os.IsNotExist(err) {
+		return pos, nil
+	}
+	if err != nil {
+		return pos, err
+	}
+	if err := json.Unmarshal(b, &pos); err != nil {
+		return position{}, fmt.Errorf("invalid wal checkpoint: %w", err)
+	}
+	return pos, nil
+}
@@ -0,0 +1,197 @@
+package wal
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func appendN(t *testing.T, s *store, prefix string, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if _, err := s.append([]byte(fmt.Sprintf("%s-%d", prefix, i))); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+}
+
+func readStrings(t *testing.T, s *store, pos position, n int) ([]string, position) {
+	t.Helper()
+	data, next, err := s.read(pos, n)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	out := make([]string, len(data))
+	for i, b := range data {
+		out[i] = string(b)
+	}
+	return out, next
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// TestStoreTornFrame 进程退出时旧段末尾的帧不完整或校验失败, 重启后跳过该段剩余部分, 之前的记录和新段中的记录正常读取
+func TestStoreTornFrame(t *testing.T) {
+	tests := []struct {
+		name string
+		tear func(t *testing.T, path string, size int64)
+		want []string
+	}{
+		{"truncated header", func(t *testing.T, path string, size int64) {
+			// 追加半个帧头
+			f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.Write([]byte{0, 0, 0})
+			f.Close()
+		}, []string{"old-0", "old-1", "old-2", "new-0", "new-1"}},
+		{"truncated payload", func(t *testing.T, path string, size int64) {
+			if err := os.Truncate(path, size-2); err != nil {
+				t.Fatal(err)
+			}
+		}, []string{"old-0", "old-1", "new-0", "new-1"}},
+		{"bad crc", func(t *testing.T, path string, size int64) {
+			f, err := os.OpenFile(path, os.O_WRONLY, 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.WriteAt([]byte{'X'}, size-1)
+			f.Close()
+		}, []string{"old-0", "old-1", "new-0", "new-1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			s, _, err := openStore(dir, 1<<20, 16<<20)
+			if err != nil {
+				t.Fatal(err)
+			}
+			appendN(t, s, "old", 3)
+			seg := s.active()
+			size := s.sizes[seg]
+			s.close()
+			tt.tear(t, s.path(seg), size)
+
+			s, pos, err := openStore(dir, 1<<20, 16<<20)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.close()
+			appendN(t, s, "new", 2)
+
+			got, _ := readStrings(t, s, pos, 10)
+			if !equal(got, tt.want) {
+				t.Fatalf("read = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestStoreCheckpointRestart 重启后从确认的位置继续读取, 确认位置之前的段被删除
+func TestStoreCheckpointRestart(t *testing.T) {
+	tests := []struct {
+		name   string
+		commit int // 重启前确认的条数
+		want   []string
+	}{
+		{"no checkpoint", 0, []string{"a-0", "a-1", "a-2", "a-3", "b-0"}},
+		{"middle of segment", 2, []string{"a-2", "a-3", "b-0"}},
+		{"end of segment", 4, []string{"b-0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			s, _, err := openStore(dir, 1<<20, 16<<20)
+			if err != nil {
+				t.Fatal(err)
+			}
+			appendN(t, s, "a", 4)
+			if tt.commit > 0 {
+				_, next := readStrings(t, s, position{}, tt.commit)
+				if err := s.commit(next); err != nil {
+					t.Fatal(err)
+				}
+			}
+			s.close()
+
+			s, pos, err := openStore(dir, 1<<20, 16<<20)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.close()
+			appendN(t, s, "b", 1)
+
+			got, next := readStrings(t, s, pos, 10)
+			if !equal(got, tt.want) {
+				t.Fatalf("read = %v, want %v", got, tt.want)
+			}
+			// 读完后确认, 只保留正在写入的段
+			if err := s.commit(next); err != nil {
+				t.Fatal(err)
+			}
+			if len(s.segs) != 1 {
+				t.Fatalf("segments after commit = %v, want only the active one", s.segs)
+			}
+		})
+	}
+}
+
+// TestStoreEviction 超过 maxSize 时删除最旧的段, 读取从剩余最旧的段开始
+func TestStoreEviction(t *testing.T) {
+	frame := int64(headerSize + len("r-00"))
+	tests := []struct {
+		name        string
+		segmentSize int64 // 以帧数计
+		maxSize     int64 // 以帧数计
+		records     int
+		wantDropped int
+		wantFirst   string
+	}{
+		{"under max size", 4, 16, 10, 0, "r-00"},
+		{"evict oldest segments", 4, 8, 20, 3, "r-12"},
+		{"single segment is kept", 100, 2, 10, 0, "r-00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, err := openStore(t.TempDir(), tt.segmentSize*frame, tt.maxSize*frame)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.close()
+
+			dropped := 0
+			for i := 0; i < tt.records; i++ {
+				n, err := s.append([]byte(fmt.Sprintf("r-%02d", i)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				dropped += n
+			}
+			if dropped != tt.wantDropped {
+				t.Fatalf("dropped = %d, want %d", dropped, tt.wantDropped)
+			}
+			if s.total > tt.maxSize*frame && len(s.segs) > 1 {
+				t.Fatalf("total = %d exceeds max size %d", s.total, tt.maxSize*frame)
+			}
+
+			got, _ := readStrings(t, s, position{}, tt.records)
+			if len(got) == 0 || got[0] != tt.wantFirst {
+				t.Fatalf("first record = %v, want %s", got, tt.wantFirst)
+			}
+			if last := fmt.Sprintf("r-%02d", tt.records-1); got[len(got)-1] != last {
+				t.Fatalf("last record = %s, want %s", got[len(got)-1], last)
+			}
+		})
+	}
+}
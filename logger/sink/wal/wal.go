@@ -0,0 +1,286 @@
+// Package wal 为远程 sink 增加磁盘预写缓冲, 空导入后在 LoggerConfig.Sinks 中配置 type 为 wal, sink 为实际发送的 sink
+// 日志先追加到本地段文件, 后台按批写入 sink 并在 Sync 返回后确认, 进程重启或网络中断后从上次确认的位置重放
+// 确认前 sink 的 Failed、Dropped 计数增加或 Sync 返回错误时整批重发, 因此可能收到重复的日志
+//
+//	sinks:
+//	  - type: wal
+//	    options:
+//	      dir: wal/kafka
+//	      max_size_mb: 1024
+//	      sink: {type: kafka, options: {brokers: ["kafka:9092"], topic: app-logs}}
+package wal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+
+	log "basic-middle/logger"
+)
+
+func init() {
+	log.RegisterSink("wal", New)
+}
+
+// Options wal sink 的配置
+type Options struct {
+	Sink            log.SinkConfig `json:"sink"`              //实际发送的 sink
+	Dir             string         `json:"dir"`               //段文件目录, 相对路径时位于 OutPutDir 下, 默认 wal/<sink 类型>, 多个 wal sink 不能共用目录
+	MaxSizeMB       int            `json:"max_size_mb"`       //未确认日志占用的最大 MB 数, 超过后删除最旧的段, 默认 512
+	SegmentSizeMB   int            `json:"segment_size_mb"`   //单个段文件的 MB 数, 默认 16
+	BatchSize       int            `json:"batch_size"`        //每次写入 sink 的条数, 默认 500
+	RetryIntervalMs int            `json:"retry_interval_ms"` //写入 sink 失败后的重试间隔毫秒数, 默认 1000
+	CloseTimeoutMs  int            `json:"close_timeout_ms"`  //Close 时发送剩余日志的最长毫秒数, 未发送的下次启动后重放, 默认 5000
+}
+
+// New 按 sc.Options 打开段文件目录并创建 sink, 上次未确认的日志在后台开始重放
+func New(conf *log.LoggerConfig, sc log.SinkConfig) (log.Sink, error) {
+	var opts Options
+	if err := sc.Decode(&opts); err != nil {
+		return nil, err
+	}
+	if opts.Sink.Type == "" {
+		return nil, errors.New("sink is required")
+	}
+	if opts.Dir == "" {
+		opts.Dir = filepath.Join("wal", opts.Sink.Type)
+	}
+	if !filepath.IsAbs(opts.Dir) {
+		opts.Dir = filepath.Join(conf.OutPutDir, opts.Dir)
+	}
+	if opts.MaxSizeMB <= 0 {
+		opts.MaxSizeMB = 512
+	}
+	if opts.SegmentSizeMB <= 0 {
+		opts.SegmentSizeMB = 16
+	}
+	// 超过 maxSize 时按段删除, 段太大时删除的粒度太粗
+	if opts.SegmentSizeMB > opts.MaxSizeMB/4 {
+		opts.SegmentSizeMB = opts.MaxSizeMB / 4
+	}
+	if opts.SegmentSizeMB <= 0 {
+		opts.SegmentSizeMB = 1
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+	if opts.RetryIntervalMs <= 0 {
+		opts.RetryIntervalMs = 1000
+	}
+	if opts.CloseTimeoutMs <= 0 {
+		opts.CloseTimeoutMs = 5000
+	}
+
+	st, pos, err := openStore(opts.Dir, int64(opts.SegmentSizeMB)<<20, int64(opts.MaxSizeMB)<<20)
+	if err != nil {
+		return nil, fmt.Errorf("open wal %s: %w", opts.Dir, err)
+	}
+	target, err := log.NewSink(conf, opts.Sink)
+	if err != nil {
+		st.close()
+		return nil, err
+	}
+
+	s := &Sink{
+		name:      opts.Sink.Type,
+		store:     st,
+		target:    target,
+		pos:       pos,
+		batchSize: opts.BatchSize,
+		retry:     time.Duration(opts.RetryIntervalMs) * time.Millisecond,
+		timeout:   time.Duration(opts.CloseTimeoutMs) * time.Millisecond,
+		notify:    make(chan struct{}, 1),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go s.run()
+	return s, nil
+}
+
+// Sink 写入段文件后返回, 由后台 goroutine 写入实际的 sink
+type Sink struct {
+	name      string
+	store     *store
+	target    log.Sink
+	pos       position // 下一条待发送日志的位置, 只在 run 中访问
+	batchSize int
+	retry     time.Duration
+	timeout   time.Duration
+	dropped   int64
+
+	notify chan struct{}
+	stop   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+// record 段文件中的一条日志, 保留 Entry 供 sink 使用
+type record struct {
+	Level    zapcore.Level   `json:"level"`
+	Time     time.Time       `json:"time"`
+	Logger   string          `json:"logger,omitempty"`
+	Message  string          `json:"msg"`
+	Defined  bool            `json:"defined,omitempty"`
+	File     string          `json:"file,omitempty"`
+	Line     int             `json:"line,omitempty"`
+	Function string          `json:"func,omitempty"`
+	Stack    string          `json:"stack,omitempty"`
+	Data     json.RawMessage `json:"data"`
+}
+
+func (r *record) entry() zapcore.Entry {
+	return zapcore.Entry{
+		Level:      r.Level,
+		Time:       r.Time,
+		LoggerName: r.Logger,
+		Message:    r.Message,
+		Caller:     zapcore.EntryCaller{Defined: r.Defined, File: r.File, Line: r.Line, Function: r.Function},
+		Stack:      r.Stack,
+	}
+}
+
+func (s *Sink) Write(ent zapcore.Entry, line []byte) error {
+	b, err := json.Marshal(&record{
+		Level:    ent.Level,
+		Time:     ent.Time,
+		Logger:   ent.LoggerName,
+		Message:  ent.Message,
+		Defined:  ent.Caller.Defined,
+		File:     ent.Caller.File,
+		Line:     ent.Caller.Line,
+		Function: ent.Caller.Function,
+		Stack:    ent.Stack,
+		Data:     line,
+	})
+	if err != nil {
+		return err
+	}
+	n, err := s.store.append(b)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		atomic.AddInt64(&s.dropped, int64(n))
+		fmt.Fprintf(os.Stderr, "wal log sink: %s exceeds max size, drop %d oldest segments\n", s.name, n)
+	}
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Sync 将段文件刷到磁盘, 不等待发送
+func (s *Sink) Sync() error {
+	return s.store.sync()
+}
+
+// Close 在 CloseTimeoutMs 内继续发送剩余的日志, 之后关闭 sink 和段文件
+func (s *Sink) Close() error {
+	s.once.Do(func() { close(s.stop) })
+	<-s.done
+	return multierr.Combine(s.target.Sync(), s.target.Close(), s.store.close())
+}
+
+// Dropped 超过 MaxSizeMB 被删除的段数
+func (s *Sink) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+// run 从上次确认的位置读取日志写入 sink, 失败时按 RetryIntervalMs 重试同一批
+// Close 后不再等待新日志, 发送完或超过 CloseTimeoutMs 后退出
+func (s *Sink) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.retry)
+	defer ticker.Stop()
+	var deadline time.Time
+
+	for {
+		if deadline.IsZero() {
+			select {
+			case <-s.stop:
+				deadline = time.Now().Add(s.timeout)
+			default:
+			}
+		} else if time.Now().After(deadline) {
+			return
+		}
+
+		data, next, err := s.store.read(s.pos, s.batchSize)
+		if err == nil && len(data) > 0 {
+			err = s.deliver(data)
+		}
+		if err == nil && next != s.pos {
+			s.pos = next
+			err = s.store.commit(next)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "wal log sink: %s: %v\n", s.name, err)
+		} else if len(data) > 0 {
+			continue
+		}
+		if !deadline.IsZero() {
+			return
+		}
+
+		// 失败后按重试间隔等待, 否则有新日志时立即读取
+		notify := s.notify
+		if err != nil {
+			notify = nil
+		}
+		select {
+		case <-notify:
+		case <-ticker.C:
+		case <-s.stop:
+		}
+	}
+}
+
+// failedCounter、droppedCounter sink 统计的未送达日志数, 如 sink.Batcher 的 Failed 和 Dropped
+type (
+	failedCounter  interface{ Failed() int64 }
+	droppedCounter interface{ Dropped() int64 }
+)
+
+func lost(s log.Sink) int64 {
+	var n int64
+	if c, ok := s.(failedCounter); ok {
+		n += c.Failed()
+	}
+	if c, ok := s.(droppedCounter); ok {
+		n += c.Dropped()
+	}
+	return n
+}
+
+// deliver 写入一批日志并 Sync, sink 没有报告丢失时确认
+func (s *Sink) deliver(data [][]byte) error {
+	before := lost(s.target)
+	for _, b := range data {
+		var r record
+		if err := json.Unmarshal(b, &r); err != nil {
+			// 无法解析的记录重发也不会成功, 跳过
+			fmt.Fprintf(os.Stderr, "wal log sink: %s: skip invalid record: %v\n", s.name, err)
+			continue
+		}
+		if err := s.target.Write(r.entry(), r.Data); err != nil {
+			return err
+		}
+	}
+	if err := s.target.Sync(); err != nil {
+		return err
+	}
+	if n := lost(s.target) - before; n > 0 {
+		return fmt.Errorf("%d entries not delivered, resend batch", n)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package wal
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+
+	log "basic-middle/logger"
+)
+
+// fakeSink 记录写入的日志, 前 failSyncs 次 Sync 将本批计为 Failed
+type fakeSink struct {
+	mu        sync.Mutex
+	lines     []string
+	failSyncs int
+	failed    int64
+	pending   int
+	ok        int // Sync 成功的条数
+}
+
+var (
+	fakeMu    sync.Mutex
+	fakeSinks = make(map[string]*fakeSink)
+)
+
+func init() {
+	log.RegisterSink("waltest", func(conf *log.LoggerConfig, sc log.SinkConfig) (log.Sink, error) {
+		var opts struct {
+			Name string `json:"name"`
+		}
+		if err := sc.Decode(&opts); err != nil {
+			return nil, err
+		}
+		fakeMu.Lock()
+		defer fakeMu.Unlock()
+		return fakeSinks[opts.Name], nil
+	})
+}
+
+func (s *fakeSink) Write(ent zapcore.Entry, line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lines = append(s.lines, string(line))
+	s.pending++
+	return nil
+}
+
+func (s *fakeSink) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failSyncs > 0 {
+		s.failSyncs--
+		s.failed += int64(s.pending)
+	} else {
+		s.ok += s.pending
+	}
+	s.pending = 0
+	return nil
+}
+
+func (s *fakeSink) Close() error { return nil }
+
+func (s *fakeSink) Failed() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.failed
+}
+
+// delivered 收到的日志以及其中 Sync 成功的条数
+func (s *fakeSink) delivered() ([]string, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.lines...), s.ok
+}
+
+func newTestSink(t *testing.T, dir string, target *fakeSink) log.Sink {
+	t.Helper()
+	fakeMu.Lock()
+	fakeSinks[t.Name()] = target
+	fakeMu.Unlock()
+
+	name, _ := json.Marshal(t.Name())
+	opts := `{"dir":"` + dir + `","retry_interval_ms":10,"close_timeout_ms":1000,"sink":{"type":"waltest","options":{"name":` + string(name) + `}}}`
+	s, err := New(&log.LoggerConfig{}, log.SinkConfig{Type: "wal", Options: json.RawMessage(opts)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// waitDelivered 等待 target 成功收到 n 条日志, 返回收到的全部日志, 包括计为 Failed 的
+func waitDelivered(t *testing.T, target *fakeSink, n int) []string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got, ok := target.delivered()
+		if ok >= n {
+			return got
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("received %v, want %d delivered entries", got, n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// TestSinkResend sink 报告 Failed 时整批重发, 送达后确认, 重启后不再重放
+func TestSinkResend(t *testing.T) {
+	tests := []struct {
+		name       string
+		failSyncs  int
+		wantResent bool
+	}{
+		{"delivered", 0, false},
+		{"resend after failure", 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			target := &fakeSink{failSyncs: tt.failSyncs}
+			s := newTestSink(t, dir, target)
+			for _, msg := range []string{`"a"`, `"b"`} {
+				if err := s.Write(zapcore.Entry{Message: msg, Time: time.Now()}, []byte(msg)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// 两条日志可能分在两批发送, 失败的一批可能只有一条
+			got := waitDelivered(t, target, 2)
+			if err := s.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if resent := len(got) > 2; resent != tt.wantResent {
+				t.Fatalf("received %v, want resent %v", got, tt.wantResent)
+			}
+			if got[len(got)-1] != `"b"` {
+				t.Fatalf("received %v, want the batch ending with \"b\" delivered last", got)
+			}
+
+			restarted := &fakeSink{}
+			s = newTestSink(t, dir, restarted)
+			s.Write(zapcore.Entry{Message: "c", Time: time.Now()}, []byte(`"c"`))
+			got = waitDelivered(t, restarted, 1)
+			s.Close()
+			if !equal(got, []string{`"c"`}) {
+				t.Fatalf("after restart received %v, want only the new entry", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Slog 返回基于全局 logger 的 *slog.Logger, 与 Logger() 共用输出、等级、轮转和公共字段
+func Slog() *slog.Logger {
+	return slog.New(NewSlogHandler(Desugared()))
+}
+
+// NewSlogHandler 返回写入 l 的 slog.Handler
+// 调用位置取自 slog.Record, 因此不受 CallerSkip 影响
+func NewSlogHandler(l *zap.Logger) slog.Handler {
+	return &slogHandler{core: l.Core()}
+}
+
+type slogHandler struct {
+	core zapcore.Core
+}
+
+func (h *slogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.core.Enabled(slogLevel(level))
+}
+
+func (h *slogHandler) Handle(_ context.Context, r slog.Record) error {
+	ent := zapcore.Entry{
+		Level:   slogLevel(r.Level),
+		Time:    r.Time,
+		Message: r.Message,
+	}
+	if r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		ent.Caller = zapcore.NewEntryCaller(frame.PC, frame.File, frame.Line, true)
+	}
+
+	ce := h.core.Check(ent, nil)
+	if ce == nil {
+		return nil
+	}
+	fields := make([]zapcore.Field, 0, r.NumAttrs())
+	r.Attrs(func(attr slog.Attr) bool {
+		fields = appendAttr(fields, attr)
+		return true
+	})
+	ce.Write(fields...)
+	return nil
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := make([]zapcore.Field, 0, len(attrs))
+	for _, attr := range attrs {
+		fields = appendAttr(fields, attr)
+	}
+	return &slogHandler{core: h.core.With(fields)}
+}
+
+// WithGroup 之后的字段都放到 name 对象下
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &slogHandler{core: h.core.With([]zapcore.Field{zap.Namespace(name)})}
+}
+
+func slogLevel(level slog.Level) zapcore.Level {
+	switch {
+	case level < slog.LevelInfo:
+		return zapcore.DebugLevel
+	case level < slog.LevelWarn:
+		return zapcore.InfoLevel
+	case level < slog.LevelError:
+		return zapcore.WarnLevel
+	default:
+		return zapcore.ErrorLevel
+	}
+}
+
+func appendAttr(fields []zapcore.Field, attr slog.Attr) []zapcore.Field {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return fields
+	}
+
+	v := attr.Value
+	switch v.Kind() {
+	case slog.KindString:
+		return append(fields, zap.String(attr.Key, v.String()))
+	case slog.KindInt64:
+		return append(fields, zap.Int64(attr.Key, v.Int64()))
+	case slog.KindUint64:
+		return append(fields, zap.Uint64(attr.Key, v.Uint64()))
+	case slog.KindFloat64:
+		return append(fields, zap.Float64(attr.Key, v.Float64()))
+	case slog.KindBool:
+		return append(fields, zap.Bool(attr.Key, v.Bool()))
+	case slog.KindDuration:
+		return append(fields, zap.Duration(attr.Key, v.Duration()))
+	case slog.KindTime:
+		return append(fields, zap.Time(attr.Key, v.Time()))
+	case slog.KindGroup:
+		group := v.Group()
+		if len(group) == 0 {
+			return fields
+		}
+		// 没有 key 的 group 直接展开到当前层级
+		if attr.Key == "" {
+			for _, a := range group {
+				fields = appendAttr(fields, a)
+			}
+			return fields
+		}
+		return append(fields, zap.Object(attr.Key, slogGroup(group)))
+	default:
+		return append(fields, zap.Any(attr.Key, v.Any()))
+	}
+}
+
+type slogGroup []slog.Attr
+
+func (g slogGroup) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	var fields []zapcore.Field
+	for _, a := range g {
+		fields = appendAttr(fields, a)
+	}
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	return nil
+}
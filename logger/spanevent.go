@@ -0,0 +1,133 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap/zapcore"
+)
+
+// spanHolder FromContext 以 Skip 类型的字段把 ctx 中正在记录的 span 传给 spanEventCore, 其他 core 编码时忽略
+type spanHolder struct {
+	span trace.Span
+}
+
+func spanField(span trace.Span) zapcore.Field {
+	return zapcore.Field{Type: zapcore.SkipType, Interface: spanHolder{span: span}}
+}
+
+// spanEventCore LoggerConfig.SpanEventLevel 及以上的日志作为 ctx 中 span 的 event 记录, 日志消息为 event 名, 字段为属性
+// 与其他输出 core 一样经过脱敏, trace_id、span_id 字段与 span 重复, 不作为属性
+type spanEventCore struct {
+	zapcore.LevelEnabler
+	span   trace.Span
+	fields []zapcore.Field
+}
+
+func newSpanEventCore(conf *LoggerConfig) zapcore.Core {
+	if conf.SpanEventLevel == "" || conf.SpanEventLevel == LevelOff {
+		return nil
+	}
+	return &spanEventCore{LevelEnabler: ZapLevel(conf.SpanEventLevel)}
+}
+
+func (c *spanEventCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := &spanEventCore{LevelEnabler: c.LevelEnabler, span: c.span, fields: append([]zapcore.Field(nil), c.fields...)}
+	for _, f := range fields {
+		if h, ok := f.Interface.(spanHolder); ok && f.Type == zapcore.SkipType {
+			clone.span = h.span
+			continue
+		}
+		if f.Key == TraceIDKey || f.Key == SpanIDKey {
+			continue
+		}
+		clone.fields = append(clone.fields, f)
+	}
+	return clone
+}
+
+func (c *spanEventCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.span != nil && c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+// Write span 在 Check 之后可能已经结束
+func (c *spanEventCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if c.span == nil || !c.span.IsRecording() {
+		return nil
+	}
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range c.fields {
+		f.AddTo(enc)
+	}
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+
+	attrs := make([]attribute.KeyValue, 0, len(enc.Fields)+2)
+	attrs = append(attrs, attribute.String("log.severity", ent.Level.String()))
+	if ent.LoggerName != "" {
+		attrs = append(attrs, attribute.String("log.logger", ent.LoggerName))
+	}
+	keys := make([]string, 0, len(enc.Fields))
+	for k := range enc.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		attrs = append(attrs, toAttribute(k, enc.Fields[k]))
+	}
+	c.span.AddEvent(ent.Message, trace.WithAttributes(attrs...), trace.WithTimestamp(ent.Time))
+	return nil
+}
+
+func (c *spanEventCore) Sync() error {
+	return nil
+}
+
+// toAttribute 基本类型转换为对应的属性类型, 对象和数组转换为 JSON 字符串
+func toAttribute(k string, v interface{}) attribute.KeyValue {
+	switch v := v.(type) {
+	case string:
+		return attribute.String(k, v)
+	case bool:
+		return attribute.Bool(k, v)
+	case int:
+		return attribute.Int(k, v)
+	case int8:
+		return attribute.Int64(k, int64(v))
+	case int16:
+		return attribute.Int64(k, int64(v))
+	case int32:
+		return attribute.Int64(k, int64(v))
+	case int64:
+		return attribute.Int64(k, v)
+	case uint8:
+		return attribute.Int64(k, int64(v))
+	case uint16:
+		return attribute.Int64(k, int64(v))
+	case uint32:
+		return attribute.Int64(k, int64(v))
+	case float32:
+		return attribute.Float64(k, float64(v))
+	case float64:
+		return attribute.Float64(k, v)
+	case time.Duration:
+		return attribute.String(k, v.String())
+	case time.Time:
+		return attribute.String(k, v.Format(time.RFC3339Nano))
+	case fmt.Stringer:
+		return attribute.String(k, v.String())
+	case map[string]interface{}, []interface{}:
+		if b, err := json.Marshal(v); err == nil {
+			return attribute.String(k, string(b))
+		}
+	}
+	return attribute.String(k, fmt.Sprint(v))
+}
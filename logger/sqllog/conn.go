@@ -0,0 +1,309 @@
+package sqllog
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type wrappedDriver struct {
+	driver.Driver
+	l *logger
+}
+
+func (d *wrappedDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{Conn: c, l: d.l}, nil
+}
+
+// OpenConnector database/sql 优先通过 Connector 创建连接
+func (d *wrappedDriver) OpenConnector(name string) (driver.Connector, error) {
+	c, err := openConnector(d.Driver, name)
+	if err != nil {
+		return nil, err
+	}
+	return &connector{Connector: c, l: d.l}, nil
+}
+
+type connector struct {
+	driver.Connector
+	l *logger
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	start := time.Now()
+	dc, err := c.Connector.Connect(ctx)
+	if err != nil {
+		c.l.log(ctx, "sql connect", "", nil, start, err)
+		return nil, err
+	}
+	return &conn{Conn: dc, l: c.l}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return &wrappedDriver{Driver: c.Connector.Driver(), l: c.l}
+}
+
+// Close DB.Close 时关闭实现了 io.Closer 的 Connector
+func (c *connector) Close() error {
+	if cl, ok := c.Connector.(io.Closer); ok {
+		return cl.Close()
+	}
+	return nil
+}
+
+// conn 包装连接, 驱动未实现的可选接口返回 driver.ErrSkip 或使用不带 context 的方法, 与 database/sql 的处理相同
+type conn struct {
+	driver.Conn
+	l *logger
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	start := time.Now()
+	var (
+		s   driver.Stmt
+		err error
+	)
+	if cp, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		s, err = cp.PrepareContext(ctx, query)
+	} else {
+		s, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		c.l.log(ctx, "sql prepare", query, nil, start, err)
+		return nil, err
+	}
+	return &stmt{Stmt: s, conn: c, query: query, l: c.l}, nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	var (
+		res driver.Result
+		err error
+	)
+	switch e := c.Conn.(type) {
+	case driver.ExecerContext:
+		res, err = e.ExecContext(ctx, query, args)
+	case driver.Execer:
+		var vals []driver.Value
+		if vals, err = values(args); err == nil {
+			res, err = e.Exec(query, vals)
+		}
+	default:
+		return nil, driver.ErrSkip
+	}
+	c.l.log(ctx, "sql exec", query, args, start, err, rowsAffected(res)...)
+	return res, err
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	var (
+		rows driver.Rows
+		err  error
+	)
+	switch q := c.Conn.(type) {
+	case driver.QueryerContext:
+		rows, err = q.QueryContext(ctx, query, args)
+	case driver.Queryer:
+		var vals []driver.Value
+		if vals, err = values(args); err == nil {
+			rows, err = q.Query(query, vals)
+		}
+	default:
+		return nil, driver.ErrSkip
+	}
+	c.l.log(ctx, "sql query", query, args, start, err)
+	return rows, err
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	start := time.Now()
+	var (
+		t   driver.Tx
+		err error
+	)
+	if cb, ok := c.Conn.(driver.ConnBeginTx); ok {
+		t, err = cb.BeginTx(ctx, opts)
+	} else if opts.Isolation != driver.IsolationLevel(0) || opts.ReadOnly {
+		// 与 database/sql 相同, 不支持 ConnBeginTx 的驱动不能设置隔离级别和只读
+		err = errors.New("sql: driver does not support non-default isolation level or read-only transactions")
+	} else {
+		t, err = c.Conn.Begin()
+	}
+	if err != nil {
+		c.l.log(ctx, "sql begin", "", nil, start, err)
+		return nil, err
+	}
+	return &tx{Tx: t, ctx: ctx, start: start, l: c.l}, nil
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *conn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *conn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (c *conn) CheckNamedValue(nv *driver.NamedValue) error {
+	if nc, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return nc.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// stmt 包装预编译语句, 日志记录 Prepare 时的 SQL
+type stmt struct {
+	driver.Stmt
+	conn  *conn
+	query string
+	l     *logger
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), named(args))
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	var (
+		res driver.Result
+		err error
+	)
+	if se, ok := s.Stmt.(driver.StmtExecContext); ok {
+		res, err = se.ExecContext(ctx, args)
+	} else {
+		var vals []driver.Value
+		if vals, err = values(args); err == nil {
+			res, err = s.Stmt.Exec(vals)
+		}
+	}
+	s.l.log(ctx, "sql exec", s.query, args, start, err, rowsAffected(res)...)
+	return res, err
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), named(args))
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	var (
+		rows driver.Rows
+		err  error
+	)
+	if sq, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = sq.QueryContext(ctx, args)
+	} else {
+		var vals []driver.Value
+		if vals, err = values(args); err == nil {
+			rows, err = s.Stmt.Query(vals)
+		}
+	}
+	s.l.log(ctx, "sql query", s.query, args, start, err)
+	return rows, err
+}
+
+// CheckNamedValue 与 database/sql 的顺序相同: 语句的 NamedValueChecker, 没有时使用连接的, 返回 ErrSkip 后使用语句的 ColumnConverter
+// 都没有时返回 ErrSkip, 由 database/sql 使用默认的转换
+func (s *stmt) CheckNamedValue(nv *driver.NamedValue) error {
+	var err error
+	if c, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		err = c.CheckNamedValue(nv)
+	} else {
+		err = s.conn.CheckNamedValue(nv)
+	}
+	if err != driver.ErrSkip {
+		return err
+	}
+	if c, ok := s.Stmt.(driver.ColumnConverter); ok {
+		v, err := c.ColumnConverter(nv.Ordinal - 1).ConvertValue(nv.Value)
+		if err != nil {
+			return err
+		}
+		nv.Value = v
+		return nil
+	}
+	return driver.ErrSkip
+}
+
+// tx 包装事务, 提交和回滚时记录事务的耗时
+type tx struct {
+	driver.Tx
+	ctx   context.Context
+	start time.Time
+	l     *logger
+}
+
+func (t *tx) Commit() error {
+	err := t.Tx.Commit()
+	t.l.log(t.ctx, "sql commit", "", nil, t.start, err)
+	return err
+}
+
+func (t *tx) Rollback() error {
+	err := t.Tx.Rollback()
+	t.l.log(t.ctx, "sql rollback", "", nil, t.start, err)
+	return err
+}
+
+func rowsAffected(res driver.Result) []interface{} {
+	if res == nil {
+		return nil
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil
+	}
+	return []interface{}{zap.Int64("rows", n)}
+}
+
+// values 不带 context 的方法不支持命名参数
+func values(args []driver.NamedValue) ([]driver.Value, error) {
+	vals := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errors.New("sql: driver does not support the use of Named Parameters")
+		}
+		vals[i] = arg.Value
+	}
+	return vals, nil
+}
+
+func named(args []driver.Value) []driver.NamedValue {
+	nvs := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		nvs[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return nvs
+}
@@ -0,0 +1,190 @@
+// Package sqllog 包装 database/sql 的驱动, 记录每条 query、exec 以及事务的耗时和错误, 可用于 database/sql 和 sqlx
+// 日志使用 ctx 中的 logger, 通过 QueryContext、ExecContext 等执行的语句带有请求 ID、trace_id 等字段
+// 正常的语句以 debug 等级记录, 超过慢查询阈值的为 warn, 出错时为 error; query 的耗时不含读取结果集的时间
+//
+//	db, err := sqllog.Open("mysql", dsn, sqllog.Config{SlowThresholdMs: 200, LogArgs: true, RedactArgs: []string{"password"}})
+//	dbx := sqlx.NewDb(db, "mysql")
+package sqllog
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	log "basic-middle/logger"
+)
+
+// Config 语句日志的配置
+type Config struct {
+	SlowThresholdMs int      `json:"slow_threshold_ms"` //耗时超过该值的语句以 warn 等级记录, 默认 200, 小于 0 时不区分
+	LogArgs         bool     `json:"log_args"`          //记录语句的参数, 默认不记录, 参数中可能有敏感信息
+	RedactArgs      []string `json:"redact_args"`       //LogArgs 时替换为 *** 的命名参数(sql.Named), 不区分大小写
+	MaxArgLen       int      `json:"max_arg_len"`       //LogArgs 时字符串参数的最大长度, 超过时截断, 默认 64
+
+	// Redact 自定义参数的记录方式, 返回记录到日志中的参数, 设置后忽略 RedactArgs 和 MaxArgLen
+	Redact func(query string, args []driver.NamedValue) []interface{} `json:"-"`
+}
+
+// logger 按 Config 记录语句
+type logger struct {
+	slow      time.Duration
+	logArgs   bool
+	redact    map[string]bool
+	maxArgLen int
+	redactFn  func(query string, args []driver.NamedValue) []interface{}
+}
+
+func newLogger(conf Config) *logger {
+	l := &logger{
+		logArgs:   conf.LogArgs,
+		redact:    make(map[string]bool, len(conf.RedactArgs)),
+		maxArgLen: conf.MaxArgLen,
+		redactFn:  conf.Redact,
+	}
+	switch {
+	case conf.SlowThresholdMs == 0:
+		l.slow = 200 * time.Millisecond
+	case conf.SlowThresholdMs > 0:
+		l.slow = time.Duration(conf.SlowThresholdMs) * time.Millisecond
+	}
+	for _, name := range conf.RedactArgs {
+		l.redact[strings.ToLower(name)] = true
+	}
+	if l.maxArgLen <= 0 {
+		l.maxArgLen = 64
+	}
+	return l
+}
+
+// Wrap 包装驱动, 用于 sql.Register 注册新的驱动名
+func Wrap(d driver.Driver, conf Config) driver.Driver {
+	return &wrappedDriver{Driver: d, l: newLogger(conf)}
+}
+
+// WrapConnector 包装 Connector, 用于 sql.OpenDB
+func WrapConnector(c driver.Connector, conf Config) driver.Connector {
+	return &connector{Connector: c, l: newLogger(conf)}
+}
+
+// Register 以 name 注册包装 driverName 后的驱动, 之后可以通过 sql.Open(name, dsn) 或 sqlx.Open(name, dsn) 使用
+// 与 sql.Register 相同, 重复注册同一个 name 时 panic
+func Register(name, driverName string, conf Config) error {
+	d, err := lookup(driverName)
+	if err != nil {
+		return err
+	}
+	sql.Register(name, Wrap(d, conf))
+	return nil
+}
+
+// Open 打开包装后的 driverName 驱动, 与 sql.Open 相同, 不会连接数据库
+func Open(driverName, dsn string, conf Config) (*sql.DB, error) {
+	d, err := lookup(driverName)
+	if err != nil {
+		return nil, err
+	}
+	c, err := openConnector(d, dsn)
+	if err != nil {
+		return nil, err
+	}
+	return sql.OpenDB(WrapConnector(c, conf)), nil
+}
+
+// lookup 查找已注册的驱动, sql.Open 只创建 DB 而不连接
+func lookup(driverName string) (driver.Driver, error) {
+	db, err := sql.Open(driverName, "")
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	return db.Driver(), nil
+}
+
+func openConnector(d driver.Driver, dsn string) (driver.Connector, error) {
+	if dc, ok := d.(driver.DriverContext); ok {
+		return dc.OpenConnector(dsn)
+	}
+	return dsnConnector{dsn: dsn, driver: d}, nil
+}
+
+// dsnConnector 驱动没有实现 driver.DriverContext 时使用, 与 database/sql 中的相同
+type dsnConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// log 记录一条语句, driver.ErrSkip 表示驱动不支持该调用, 由 database/sql 改用其他方式执行, 不记录
+func (l *logger) log(ctx context.Context, msg, query string, args []driver.NamedValue, start time.Time, err error, fields ...interface{}) {
+	if errors.Is(err, driver.ErrSkip) {
+		return
+	}
+	latency := time.Since(start)
+	slow := l.slow > 0 && latency >= l.slow
+	var kv []interface{}
+	if query != "" {
+		kv = append(kv, zap.String("query", query))
+	}
+	if l.logArgs && len(args) > 0 {
+		kv = append(kv, zap.Any("args", l.args(query, args)))
+	}
+	fields = append(append(kv, fields...), zap.Duration("latency", latency))
+	if err != nil {
+		fields = append(fields, zap.Error(err))
+	}
+	if slow {
+		fields = append(fields, zap.Bool("slow", true))
+	}
+
+	zl := log.FromContext(ctx).Named("sql").WithOptions(zap.WithCaller(false)).With("source", log.CallerOutside("/database/sql/", "/jmoiron/sqlx"))
+	switch {
+	// 连接失效由 database/sql 换连接重试, 取消和超时由调用方决定
+	case errors.Is(err, driver.ErrBadConn), errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		zl.Warnw(msg, fields...)
+	case err != nil:
+		zl.Errorw(msg, fields...)
+	case slow:
+		zl.Warnw(msg, fields...)
+	default:
+		zl.Debugw(msg, fields...)
+	}
+}
+
+// args 返回记录到日志中的参数
+func (l *logger) args(query string, args []driver.NamedValue) []interface{} {
+	if l.redactFn != nil {
+		return l.redactFn(query, args)
+	}
+	vals := make([]interface{}, len(args))
+	for i, arg := range args {
+		if l.redact[strings.ToLower(arg.Name)] {
+			vals[i] = "***"
+			continue
+		}
+		switch v := arg.Value.(type) {
+		case string:
+			if len(v) > l.maxArgLen {
+				v = strings.ToValidUTF8(v[:l.maxArgLen], "") + "..."
+			}
+			vals[i] = v
+		case []byte:
+			vals[i] = fmt.Sprintf("<%d bytes>", len(v))
+		default:
+			vals[i] = v
+		}
+	}
+	return vals
+}
@@ -0,0 +1,14 @@
+package log
+
+import (
+	"go.uber.org/zap"
+)
+
+// RedirectStdLog 将标准库 log 包(包括使用它的第三方库)的输出重定向到全局 logger
+// level 为写入时使用的日志等级, 返回的函数用于恢复标准库 log 原来的输出
+//
+//	restore, err := log.RedirectStdLog("info")
+//	defer restore()
+func RedirectStdLog(level string) (func(), error) {
+	return zap.RedirectStdLogAt(Desugared(), ZapLevel(level))
+}
@@ -0,0 +1,80 @@
+package log
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// zap 的 SugaredLogger 遇到不成对的 key/value 时输出的错误日志
+const (
+	oddNumberErrMsg    = "Ignored key without a value."
+	nonStringKeyErrMsg = "Ignored key-value pairs with non-string keys."
+)
+
+// logPkgPath 本包的导入路径, 查找调用方时跳过
+var logPkgPath = reflect.TypeOf(strictKVCore{}).PkgPath()
+
+// strictKVCore 将 zap 对错误 key/value 的报错改写为 logging_bug 警告
+// zap 记录的 caller 是 sugar.go 内部, 这里改为真正写错参数的调用位置, 便于定位
+type strictKVCore struct {
+	zapcore.Core
+}
+
+func newStrictKVCore(core zapcore.Core, strict bool) zapcore.Core {
+	if !strict {
+		return core
+	}
+	return &strictKVCore{Core: core}
+}
+
+func (c *strictKVCore) With(fields []zapcore.Field) zapcore.Core {
+	return &strictKVCore{Core: c.Core.With(fields)}
+}
+
+func (c *strictKVCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if ent.Level != zapcore.ErrorLevel || (ent.Message != oddNumberErrMsg && ent.Message != nonStringKeyErrMsg) {
+		return c.Core.Check(ent, ce)
+	}
+	return ce.AddCore(ent, c)
+}
+
+func (c *strictKVCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	reason := ent.Message
+	ent.Level = zapcore.WarnLevel
+	ent.Message = "logging_bug"
+	if caller, ok := userCaller(); ok {
+		ent.Caller = caller
+	}
+
+	ce := c.Core.Check(ent, nil)
+	if ce == nil {
+		return nil
+	}
+	ce.Write(append([]zapcore.Field{zap.String("reason", reason)}, fields...)...)
+	return nil
+}
+
+// userCaller 跳过 runtime、zap 以及本包的栈帧, 返回第一个业务代码的位置
+func userCaller() (zapcore.EntryCaller, bool) {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		f, more := frames.Next()
+		if !internalFrame(f.Function) {
+			return zapcore.EntryCaller{Defined: true, PC: f.PC, File: f.File, Line: f.Line, Function: f.Function}, true
+		}
+		if !more {
+			return zapcore.EntryCaller{}, false
+		}
+	}
+}
+
+func internalFrame(function string) bool {
+	return strings.HasPrefix(function, "runtime.") ||
+		strings.HasPrefix(function, "go.uber.org/zap") ||
+		strings.HasPrefix(function, logPkgPath+".")
+}
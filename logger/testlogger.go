@@ -0,0 +1,37 @@
+package log
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// TestingT NewTestLogger 使用的 testing.TB 的方法, 不引用 testing 包, 避免使用 log 的程序链接测试框架
+type TestingT interface {
+	zaptest.TestingT
+	Helper()
+	Cleanup(func())
+}
+
+// NewTestLogger 创建单元测试用的 logger, 不写文件, t 一般为 *testing.T
+// 日志同时输出到 t.Log 并记录在返回的 ObservedLogs 中用于断言
+// 测试期间替换全局 logger, 因此使用 Logger()、FromContext 的代码也会写到这里, 测试结束后恢复
+// 全局 logger 的读取没有加锁, 调用了 t.Parallel() 的测试不要使用
+//
+//	_, logs := log.NewTestLogger(t)
+//	doSomething(ctx)
+//	if logs.FilterMessage("order created").Len() != 1 { t.Fatal(...) }
+func NewTestLogger(t TestingT) (*zap.SugaredLogger, *observer.ObservedLogs) {
+	t.Helper()
+
+	level := zap.NewAtomicLevelAt(zapcore.DebugLevel)
+	observed, logs := observer.New(zapcore.DebugLevel)
+	modules := newModuleLevels(nil)
+	core := newLevelCore(zapcore.NewTee(observed, zaptest.NewLogger(t).Core()), level, modules)
+
+	prev := std
+	std = newInstance(zap.New(core, zap.AddCaller(), zap.Hooks(runHooks)), level, modules, nil, nil)
+	t.Cleanup(func() { std = prev })
+	return std.sugar, logs
+}
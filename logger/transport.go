@@ -0,0 +1,194 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.uber.org/zap"
+
+	"basic-middle/logger/requestid"
+)
+
+// Transport 记录发出的 HTTP 请求的 http.RoundTripper, 日志使用请求 ctx 中的 logger
+// 请求头会带上 ctx 中的请求 ID(X-Request-Id)以及 W3C traceparent、baggage, 下游服务的日志可以关联
+//
+//	client := &http.Client{Transport: log.NewTransport(nil)}
+type Transport struct {
+	Base            http.RoundTripper //实际发送请求的 RoundTripper, 为空时使用 http.DefaultTransport
+	SlowThresholdMs int               //耗时超过该值的请求以 warn 等级记录, 为 0 时不区分
+	Retries         int               //网络错误或 429、502、503、504 时的重试次数, 只重试幂等方法且请求体可以重读(GetBody)的请求
+	RetryWaitMs     int               //首次重试前等待的毫秒数, 之后每次加倍, 默认 100
+	CaptureBody     bool              //debug 等级开启时日志附带请求体和响应体, 流式响应会等到读满 MaxBodyBytes, 注意其中可能有敏感信息
+	MaxBodyBytes    int               //附带的请求体、响应体最大字节数, 默认 4096
+}
+
+// NewTransport 包装 base, base 为空时使用 http.DefaultTransport
+func NewTransport(base http.RoundTripper) *Transport {
+	return &Transport{Base: base}
+}
+
+var transportPropagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// RoundTrip 发送请求并记录一条日志, 5xx 或出错时为 error 等级, 超过 SlowThresholdMs 时为 warn 等级
+// latency 为收到响应头的耗时, 不含读取响应体
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	ctx := req.Context()
+	// RoundTripper 不能修改调用方的请求
+	r := req.Clone(ctx)
+	requestid.SetHeader(ctx, r.Header)
+	transportPropagator.Inject(ctx, propagation.HeaderCarrier(r.Header))
+
+	l := FromContext(ctx).Named("http")
+	capture := t.CaptureBody && l.Desugar().Core().Enabled(zap.DebugLevel)
+	var reqBody string
+	if capture {
+		reqBody = t.readRequestBody(r)
+	}
+
+	resp, retries, err := t.send(r)
+
+	fields := []interface{}{
+		zap.String("method", r.Method),
+		zap.String("url", r.URL.Redacted()),
+	}
+	if resp != nil {
+		fields = append(fields, zap.Int("status", resp.StatusCode))
+	}
+	latency := time.Since(start)
+	fields = append(fields, zap.Duration("latency", latency))
+	if retries > 0 {
+		fields = append(fields, zap.Int("retries", retries))
+	}
+	if reqBody != "" {
+		fields = append(fields, zap.String("req_body", reqBody))
+	}
+	if capture && resp != nil {
+		if body := t.readResponseBody(resp); body != "" {
+			fields = append(fields, zap.String("resp_body", body))
+		}
+	}
+	if err != nil {
+		fields = append(fields, zap.Error(err))
+	}
+
+	slow := t.SlowThresholdMs > 0 && latency >= time.Duration(t.SlowThresholdMs)*time.Millisecond
+	switch {
+	case errors.Is(err, context.Canceled):
+		l.Warnw("http client request", fields...)
+	case err != nil || resp.StatusCode >= http.StatusInternalServerError:
+		l.Errorw("http client request", fields...)
+	case slow:
+		l.Warnw("http client request", append(fields, zap.Bool("slow", true))...)
+	default:
+		l.Infow("http client request", fields...)
+	}
+	return resp, err
+}
+
+// send 发送请求, 按 Retries 重试, 返回重试的次数
+func (t *Transport) send(r *http.Request) (*http.Response, int, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	wait := time.Duration(t.RetryWaitMs) * time.Millisecond
+	if wait <= 0 {
+		wait = 100 * time.Millisecond
+	}
+
+	for retries := 0; ; retries++ {
+		resp, err := base.RoundTrip(r)
+		if retries >= t.Retries || !retryable(r, resp, err) {
+			return resp, retries, err
+		}
+		body, gerr := r.GetBody()
+		if gerr != nil {
+			return resp, retries, err
+		}
+		if resp != nil {
+			// 读完响应体才能复用连接
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-r.Context().Done():
+			timer.Stop()
+			body.Close()
+			return nil, retries, r.Context().Err()
+		case <-timer.C:
+		}
+		wait *= 2
+		r.Body = body
+	}
+}
+
+// retryable 请求是否可以重试, 没有请求体时 GetBody 为空, 补上返回 http.NoBody 的 GetBody
+func retryable(r *http.Request, resp *http.Response, err error) bool {
+	if r.Context().Err() != nil {
+		return false
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+	default:
+		return false
+	}
+	if r.GetBody == nil {
+		if r.Body != nil && r.Body != http.NoBody {
+			return false
+		}
+		r.GetBody = func() (io.ReadCloser, error) { return http.NoBody, nil }
+	}
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func (t *Transport) maxBodyBytes() int {
+	if t.MaxBodyBytes <= 0 {
+		return 4096
+	}
+	return t.MaxBodyBytes
+}
+
+// readRequestBody 优先通过 GetBody 读取请求体的副本, 否则读取的部分放回 r.Body
+func (t *Transport) readRequestBody(r *http.Request) string {
+	if r.Body == nil || r.Body == http.NoBody {
+		return ""
+	}
+	max := int64(t.maxBodyBytes())
+	if r.GetBody != nil {
+		body, err := r.GetBody()
+		if err != nil {
+			return ""
+		}
+		defer body.Close()
+		buf, _ := io.ReadAll(io.LimitReader(body, max))
+		return string(buf)
+	}
+	buf, _ := io.ReadAll(io.LimitReader(r.Body, max))
+	r.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(buf), r.Body), Closer: r.Body}
+	return string(buf)
+}
+
+// readResponseBody 读取响应体的开头, 读取的部分放回 resp.Body 供调用方使用
+func (t *Transport) readResponseBody(resp *http.Response) string {
+	if resp.Body == nil || resp.Body == http.NoBody {
+		return ""
+	}
+	buf, _ := io.ReadAll(io.LimitReader(resp.Body, int64(t.maxBodyBytes())))
+	resp.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(buf), resp.Body), Closer: resp.Body}
+	return string(buf)
+}
@@ -0,0 +1,41 @@
+package log
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Field 强类型的日志字段
+type Field = zap.Field
+
+// 常用字段构造函数, 与 zap 中的同名函数一致
+func Str(key, val string) Field                    { return zap.String(key, val) }
+func Int(key string, val int) Field                { return zap.Int(key, val) }
+func Int64(key string, val int64) Field            { return zap.Int64(key, val) }
+func Bool(key string, val bool) Field              { return zap.Bool(key, val) }
+func Duration(key string, val time.Duration) Field { return zap.Duration(key, val) }
+func Err(err error) Field                          { return zap.Error(err) }
+func Any(key string, val interface{}) Field        { return zap.Any(key, val) }
+
+// DebugF 使用全局 logger 记录强类型字段的日志, 热点路径中避免 sugar 接口的装箱和内存分配
+//
+//	log.InfoF("order created", log.Str("order_id", id), log.Int("amount", amount))
+func DebugF(msg string, fields ...Field) { typed().Debug(msg, fields...) }
+
+// InfoF 同 DebugF, info 等级
+func InfoF(msg string, fields ...Field) { typed().Info(msg, fields...) }
+
+// WarnF 同 DebugF, warn 等级
+func WarnF(msg string, fields ...Field) { typed().Warn(msg, fields...) }
+
+// ErrorF 同 DebugF, error 等级
+func ErrorF(msg string, fields ...Field) { typed().Error(msg, fields...) }
+
+func typed() *zap.Logger {
+	if std == nil {
+		panic("nil logger")
+	}
+
+	return std.typed
+}
@@ -0,0 +1,43 @@
+package log
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// withDiscardLogger 将全局 logger 替换为编码后丢弃输出的 logger, 只衡量记录日志本身的开销
+func withDiscardLogger(b *testing.B) {
+	saved := std
+	level := zap.NewAtomicLevelAt(zapcore.DebugLevel)
+	core := zapcore.NewCore(newEncoder(&LoggerConfig{}), zapcore.AddSync(io.Discard), level)
+	std = newInstance(zap.New(core), level, newModuleLevels(nil), nil, nil)
+	b.Cleanup(func() { std = saved })
+}
+
+// 字段值在运行时产生, 常量装箱时编译器不会分配内存, 无法体现差别
+var (
+	benchOrderIDs = []string{"a1b2c3", "d4e5f6", "g7h8i9"}
+	benchLatency  = 3 * time.Millisecond
+)
+
+func BenchmarkInfoF(b *testing.B) {
+	withDiscardLogger(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		InfoF("order created", Str("order_id", benchOrderIDs[i%len(benchOrderIDs)]), Int("amount", 1000+i), Duration("latency", benchLatency), Bool("paid", i%2 == 0))
+	}
+}
+
+func BenchmarkInfow(b *testing.B) {
+	withDiscardLogger(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Logger().Infow("order created", "order_id", benchOrderIDs[i%len(benchOrderIDs)], "amount", 1000+i, "latency", benchLatency, "paid", i%2 == 0)
+	}
+}
@@ -0,0 +1,292 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	rotatelogs "github.com/lestrrat-go/file-rotatelogs"
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// 输出目标, 除此之外的值视为 OutPutDir 下的文件名, 带 scheme 的值按 URL 处理, 见 RegisterScheme
+const (
+	OutputFile   = "file"
+	OutputStdout = "stdout"
+	OutputStderr = "stderr"
+)
+
+// 日志文件轮转方式
+const (
+	RotateByTime = "time"
+	RotateBySize = "size"
+)
+
+// stdWriter stdout/stderr 不需要 Sync, 对终端或管道调用 Sync 会返回 EINVAL
+type stdWriter struct {
+	io.Writer
+}
+
+func (stdWriter) Sync() error { return nil }
+
+// outputSet 一次 newLogger 中打开的输出, 同名输出只打开一次, 多个 core 共用
+type outputSet struct {
+	conf    *LoggerConfig
+	opened  map[string]zapcore.WriteSyncer
+	files   []*reopenWriter
+	closers []io.Closer // 在关闭文件之前关闭, 如异步写入的 goroutine
+}
+
+func newOutputSet(conf *LoggerConfig) *outputSet {
+	return &outputSet{conf: conf, opened: make(map[string]zapcore.WriteSyncer)}
+}
+
+// open 打开单个输出: stdout、stderr、file(即 conf.Filename)、file:// URL 或 OutPutDir 下的其他文件名
+func (s *outputSet) open(output string) (zapcore.WriteSyncer, error) {
+	switch output {
+	case "":
+		return nil, fmt.Errorf("empty log output")
+	case OutputStdout:
+		return zapcore.Lock(stdWriter{os.Stdout}), nil
+	case OutputStderr:
+		return zapcore.Lock(stdWriter{os.Stderr}), nil
+	case OutputFile:
+		return s.openFile(OutputFile, func(c *LoggerConfig) string { return c.Filename })
+	}
+	if strings.HasPrefix(output, "file://") {
+		filename, err := fileURLTarget(output)
+		if err != nil {
+			return nil, err
+		}
+		return s.openFile(output, filename)
+	}
+	return s.openFile(output, func(*LoggerConfig) string { return output })
+}
+
+// openFile 以 key 去重打开轮转文件, filename 在 Reload 时按新配置重新求值
+func (s *outputSet) openFile(key string, filename func(conf *LoggerConfig) string) (zapcore.WriteSyncer, error) {
+	if ws, ok := s.opened[key]; ok {
+		return ws, nil
+	}
+
+	w, err := newReopenWriter(s.conf, filename)
+	if err != nil {
+		return nil, err
+	}
+	s.files = append(s.files, w)
+	ws := zapcore.AddSync(w)
+	s.opened[key] = ws
+	return ws, nil
+}
+
+// syncer 组合多个输出, 未配置时只输出到文件
+func (s *outputSet) syncer(outputs []string) (zapcore.WriteSyncer, error) {
+	if len(outputs) == 0 {
+		outputs = []string{OutputFile}
+	}
+
+	syncers := make([]zapcore.WriteSyncer, 0, len(outputs))
+	for _, output := range outputs {
+		ws, err := s.open(output)
+		if err != nil {
+			return nil, err
+		}
+		syncers = append(syncers, ws)
+	}
+	return s.async(zapcore.NewMultiWriteSyncer(syncers...)), nil
+}
+
+// async 开启异步时每个 core 的输出各自使用一个写入队列
+func (s *outputSet) async(ws zapcore.WriteSyncer) zapcore.WriteSyncer {
+	if s.conf.Async == nil {
+		return ws
+	}
+	w := newAsyncWriter(ws, s.conf.Async)
+	s.closers = append(s.closers, w)
+	return w
+}
+
+// close 构建失败时释放已打开的输出
+func (s *outputSet) close() error {
+	return multierr.Append(closeAll(s.closers), closeFiles(s.files))
+}
+
+// reopenWriter 可在运行时重新打开的日志文件, 用于 SIGHUP 后切换文件或目录
+// 已创建的子 logger 持有的是 reopenWriter 本身, 重新打开后无需重建
+type reopenWriter struct {
+	mu       sync.RWMutex
+	w        io.WriteCloser
+	filename func(conf *LoggerConfig) string
+}
+
+func newReopenWriter(conf *LoggerConfig, filename func(conf *LoggerConfig) string) (*reopenWriter, error) {
+	w, err := getWriter(conf, filename(conf))
+	if err != nil {
+		return nil, err
+	}
+	return &reopenWriter{w: w, filename: filename}, nil
+}
+
+func (r *reopenWriter) Write(p []byte) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.w.Write(p)
+}
+
+func (r *reopenWriter) Close() error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.w.Close()
+}
+
+// reopen 按新的配置打开文件, 成功后关闭旧文件; 新文件名为空时保持不变
+func (r *reopenWriter) reopen(conf *LoggerConfig) error {
+	filename := r.filename(conf)
+	if filename == "" {
+		return nil
+	}
+	w, err := getWriter(conf, filename)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	old := r.w
+	r.w = w
+	r.mu.Unlock()
+	return old.Close()
+}
+
+func closeAll(closers []io.Closer) error {
+	var err error
+	for _, c := range closers {
+		err = multierr.Append(err, c.Close())
+	}
+	return err
+}
+
+func closeFiles(files []*reopenWriter) error {
+	var err error
+	for _, f := range files {
+		err = multierr.Append(err, f.Close())
+	}
+	return err
+}
+
+// getWriter 按 conf.RotateBy 创建 filename 的轮转 writer, 默认按时间轮转
+func getWriter(conf *LoggerConfig, filename string) (io.WriteCloser, error) {
+	// 文件名为绝对路径时不使用 OutPutDir, 如 file:///var/log/app/demo.log
+	if filepath.IsAbs(filename) {
+		c := *conf
+		c.OutPutDir, filename = filepath.Split(filename)
+		conf = &c
+	}
+	if err := checkDir(conf.OutPutDir); err != nil {
+		return nil, err
+	}
+
+	switch conf.RotateBy {
+	case "", RotateByTime:
+		return getTimeWriter(conf, filename)
+	case RotateBySize:
+		if conf.Archive != nil {
+			return nil, fmt.Errorf("log archive requires rotate_by %q", RotateByTime)
+		}
+		return getSizeWriter(conf, filename), nil
+	default:
+		return nil, fmt.Errorf("unknown log rotate_by: %q", conf.RotateBy)
+	}
+}
+
+func getTimeWriter(conf *LoggerConfig, filename string) (io.WriteCloser, error) {
+	rotation := time.Duration(conf.RotationHours) * time.Hour
+	if rotation <= 0 {
+		rotation = 24 * time.Hour
+	}
+	// 按小时轮转时文件名需要带上小时, 否则同一天的文件会互相覆盖
+	pattern := "%Y-%m-%d"
+	if rotation%(24*time.Hour) != 0 {
+		pattern = "%Y-%m-%d-%H"
+	}
+
+	// 保留时长与保留个数 rotatelogs 只能二选一, 配置了 MaxRotatedFiles 时以其为准
+	retention := rotatelogs.WithMaxAge(maxAge(conf))
+	if conf.MaxRotatedFiles > 0 {
+		retention = rotatelogs.WithRotationCount(uint(conf.MaxRotatedFiles))
+	}
+
+	// 生成rotatelogs的Logger 实际生成的文件名 YYYY-mm-dddemo.log
+	// demo.log是指向最新日志的链接
+	// 默认保存7天内的日志，每天(零点)分割一次日志
+	// 没有使用go风格反人类的format格式
+	path := conf.OutPutDir + pattern + filename
+	opts := []rotatelogs.Option{
+		rotatelogs.WithLinkName(filename),
+		retention,
+		rotatelogs.WithRotationTime(rotation),
+	}
+	archiver, err := newArchiver(conf)
+	if err != nil {
+		return nil, err
+	}
+	if conf.Compress || archiver != nil {
+		opts = append(opts, rotatelogs.WithHandler(newRotateHandler(path, conf, archiver)))
+	}
+	hook, err := rotatelogs.New(path, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return hook, nil
+}
+
+// getSizeWriter 按文件大小轮转, 防止短时间内大量日志写满磁盘
+// 当前文件为 outputDir/filename, 轮转后的文件名带上时间戳
+func getSizeWriter(conf *LoggerConfig, filename string) io.WriteCloser {
+	maxSize := conf.MaxSizeMB
+	if maxSize <= 0 {
+		maxSize = 100
+	}
+	maxBackups := conf.MaxBackups
+	if maxBackups <= 0 {
+		maxBackups = conf.MaxRotatedFiles
+	}
+	return &lumberjack.Logger{
+		Filename:   filepath.Join(conf.OutPutDir, filename),
+		MaxSize:    maxSize,
+		MaxBackups: maxBackups,
+		MaxAge:     int(maxAge(conf) / (24 * time.Hour)),
+		LocalTime:  true,
+		Compress:   conf.Compress,
+	}
+}
+
+// maxAge 日志文件保留时长, 默认 7 天
+func maxAge(conf *LoggerConfig) time.Duration {
+	if conf.MaxAgeDays <= 0 {
+		return 7 * 24 * time.Hour
+	}
+	return time.Duration(conf.MaxAgeDays) * 24 * time.Hour
+}
+
+// checkDir rotatelogs 在首次写入时才创建文件, 这里提前确认目录存在且可写
+func checkDir(dir string) error {
+	if dir == "" {
+		dir = "."
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(dir, ".probe")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
@@ -0,0 +1,102 @@
+package trace
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/jaeger"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.uber.org/zap"
+
+	log "basic-middle/logger"
+)
+
+// newExporter 按 Exporter 创建 span 的导出器, none 时返回 nil
+func newExporter(conf *Config) (sdktrace.SpanExporter, error) {
+	switch conf.Exporter {
+	case "", "otlp":
+		return newOTLPExporter(conf)
+	case "jaeger":
+		return newJaegerExporter(conf.Jaeger)
+	case "stdout":
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown trace exporter: %q", conf.Exporter)
+	}
+}
+
+// newOTLPExporter 未配置的项使用 OTEL_EXPORTER_OTLP_* 环境变量或默认值, 创建时不连接
+func newOTLPExporter(conf *Config) (sdktrace.SpanExporter, error) {
+	var client otlptrace.Client
+	switch conf.Protocol {
+	case "", "grpc":
+		var opts []otlptracegrpc.Option
+		if conf.Endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(conf.Endpoint))
+		}
+		if conf.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		if len(conf.Headers) > 0 {
+			opts = append(opts, otlptracegrpc.WithHeaders(conf.Headers))
+		}
+		client = otlptracegrpc.NewClient(opts...)
+	case "http":
+		var opts []otlptracehttp.Option
+		if conf.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(conf.Endpoint))
+		}
+		if conf.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		if len(conf.Headers) > 0 {
+			opts = append(opts, otlptracehttp.WithHeaders(conf.Headers))
+		}
+		client = otlptracehttp.NewClient(opts...)
+	default:
+		return nil, fmt.Errorf("unknown otlp protocol: %q", conf.Protocol)
+	}
+	return otlptrace.New(context.Background(), client)
+}
+
+// newJaegerExporter agent 模式下发送失败等错误写入 jaeger 子 logger
+func newJaegerExporter(conf *JaegerConfig) (sdktrace.SpanExporter, error) {
+	if conf == nil {
+		conf = &JaegerConfig{}
+	}
+	if conf.CollectorEndpoint != "" {
+		timeout := 10 * time.Second
+		if conf.TimeoutMs > 0 {
+			timeout = time.Duration(conf.TimeoutMs) * time.Millisecond
+		}
+		opts := []jaeger.CollectorEndpointOption{
+			jaeger.WithEndpoint(conf.CollectorEndpoint),
+			jaeger.WithHTTPClient(&http.Client{Timeout: timeout}),
+		}
+		if conf.Username != "" {
+			opts = append(opts, jaeger.WithUsername(conf.Username), jaeger.WithPassword(conf.Password))
+		}
+		return jaeger.New(jaeger.WithCollectorEndpoint(opts...))
+	}
+
+	opts := []jaeger.AgentEndpointOption{jaeger.WithLogger(zap.NewStdLog(log.Desugared().Named("jaeger")))}
+	if conf.AgentHost != "" {
+		opts = append(opts, jaeger.WithAgentHost(conf.AgentHost))
+	}
+	if conf.AgentPort != "" {
+		opts = append(opts, jaeger.WithAgentPort(conf.AgentPort))
+	}
+	if conf.MaxPacketSize > 0 {
+		opts = append(opts, jaeger.WithMaxPacketSize(conf.MaxPacketSize))
+	}
+	return jaeger.New(jaeger.WithAgentEndpoint(opts...))
+}
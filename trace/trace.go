@@ -0,0 +1,187 @@
+// Package trace 初始化 OpenTelemetry 的 TracerProvider 并提供创建 span 的方法
+// 通过 Start 创建的 ctx 传给 log.FromContext 时日志自动带有 trace_id、span_id 字段
+//
+//	if err := trace.Init(&trace.Config{Namespace: "shop", Project: "order", Endpoint: "otel-collector:4317", Insecure: true}); err != nil {
+//		log.Logger().Errorw("init trace", "err", err)
+//	}
+//	defer trace.Close()
+//
+//	ctx, span := trace.Start(ctx, "create order")
+//	defer func() { trace.End(span, err) }()
+//	log.FromContext(ctx).Infow("order created") // 带有 trace_id、span_id
+package trace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+
+	log "basic-middle/logger"
+)
+
+// Config 链路追踪的配置
+type Config struct {
+	Namespace   string            `json:"namespace"`    //命名空间, 作为资源属性 service.namespace
+	Project     string            `json:"project"`      //项目名称, 作为资源属性 service.name, 也是 tracer 的名称
+	Exporter    string            `json:"exporter"`     //导出方式 otlp|jaeger|stdout|none, 默认 otlp, jaeger 已废弃, Jaeger 后端改用 otlp
+	Protocol    string            `json:"protocol"`     //OTLP 协议 grpc|http, 默认 grpc
+	Endpoint    string            `json:"endpoint"`     //OTLP 地址, 默认 grpc 为 localhost:4317, http 为 localhost:4318
+	Insecure    bool              `json:"insecure"`     //OTLP 不使用 TLS
+	Headers     map[string]string `json:"headers"`      //OTLP 请求附加的 header, 如鉴权 token
+	Sampler     string            `json:"sampler"`      //采样方式 always_on|always_off|ratio, 默认 ratio, 有父 span 时沿用父 span 的采样结果
+	SampleRatio float64           `json:"sample_ratio"` //ratio 的采样比例 0~1, 默认 1
+	Attributes  map[string]string `json:"attributes"`   //附加的资源属性, 如 deployment.environment、service.version
+	Jaeger      *JaegerConfig     `json:"jaeger"`       //Exporter 为 jaeger 时的配置, 为空时发送到本机 agent, 已废弃, 见 JaegerConfig
+}
+
+// JaegerConfig Jaeger 导出的配置, 用于还未接入 OTLP 的 Jaeger 后端
+// 配置了 CollectorEndpoint 时通过 HTTP 发送到 collector, 否则通过 UDP 发送到 agent
+// 未配置的项使用 OTEL_EXPORTER_JAEGER_* 环境变量或默认值
+//
+//	exporter: jaeger
+//	jaeger: {collector_endpoint: "http://jaeger-collector:14268/api/traces"}
+//
+// Deprecated: OpenTelemetry 已停止维护 jaeger exporter(最后版本 v1.17.0), 之后会随 OTel 升级移除
+// Jaeger 1.35 起原生支持 OTLP, 迁移时改为 exporter: otlp, endpoint 指向 collector 的 4317(grpc) 或 4318(http) 端口,
+// 如 endpoint: jaeger-collector:4317, 旧版本的 Jaeger 先升级或在前面部署 OTel Collector 转发
+type JaegerConfig struct {
+	CollectorEndpoint string `json:"collector_endpoint"` //collector 的地址, 如 http://jaeger-collector:14268/api/traces
+	Username          string `json:"username"`           //collector 的 basic auth 用户名
+	Password          string `json:"password"`           //collector 的 basic auth 密码
+	TimeoutMs         int    `json:"timeout_ms"`         //发送到 collector 的超时毫秒数, 默认 10000
+	AgentHost         string `json:"agent_host"`         //agent 的地址, 默认 localhost
+	AgentPort         string `json:"agent_port"`         //agent 的端口, 默认 6831
+	MaxPacketSize     int    `json:"max_packet_size"`    //发送到 agent 的 UDP 包最大字节数, 默认 65000, 超过时拆分为多个包
+}
+
+var (
+	mu       sync.Mutex
+	provider *sdktrace.TracerProvider
+	name     string
+)
+
+// Init 按配置创建全局 TracerProvider, 并设置 W3C traceparent 和 baggage 的 propagator
+// 导出失败等 OTel 内部错误写入 otel 子 logger; 重复调用时关闭之前的 TracerProvider
+func Init(conf *Config) error {
+	if conf.Project == "" {
+		return errors.New("trace project is required")
+	}
+	exp, err := newExporter(conf)
+	if err != nil {
+		return fmt.Errorf("create %s trace exporter: %w", conf.Exporter, err)
+	}
+	res, err := newResource(conf)
+	if err != nil {
+		return err
+	}
+
+	opts := []sdktrace.TracerProviderOption{sdktrace.WithResource(res), sdktrace.WithSampler(newSampler(conf))}
+	if exp != nil {
+		opts = append(opts, sdktrace.WithBatcher(exp))
+	}
+	tp := sdktrace.NewTracerProvider(opts...)
+
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		log.Named("otel").Warnw("opentelemetry error", "err", err)
+	}))
+
+	mu.Lock()
+	old := provider
+	provider, name = tp, conf.Project
+	mu.Unlock()
+	if old != nil {
+		return shutdown(old)
+	}
+	return nil
+}
+
+// Close 导出剩余的 span 并关闭 TracerProvider, 最多等待 5 秒
+func Close() error {
+	mu.Lock()
+	tp := provider
+	provider = nil
+	mu.Unlock()
+	if tp == nil {
+		return nil
+	}
+	return shutdown(tp)
+}
+
+func shutdown(tp *sdktrace.TracerProvider) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return tp.Shutdown(ctx)
+}
+
+func newResource(conf *Config) (*resource.Resource, error) {
+	attrs := []attribute.KeyValue{semconv.ServiceName(conf.Project)}
+	if conf.Namespace != "" {
+		attrs = append(attrs, semconv.ServiceNamespace(conf.Namespace))
+	}
+	for k, v := range conf.Attributes {
+		attrs = append(attrs, attribute.String(k, v))
+	}
+	// resource.Default 包含 SDK 信息和 OTEL_RESOURCE_ATTRIBUTES, 配置的属性优先
+	return resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, attrs...))
+}
+
+func newSampler(conf *Config) sdktrace.Sampler {
+	var s sdktrace.Sampler
+	switch conf.Sampler {
+	case "always_on":
+		s = sdktrace.AlwaysSample()
+	case "always_off":
+		s = sdktrace.NeverSample()
+	default:
+		ratio := conf.SampleRatio
+		if ratio <= 0 {
+			ratio = 1
+		}
+		s = sdktrace.TraceIDRatioBased(ratio)
+	}
+	return sdktrace.ParentBased(s)
+}
+
+// Tracer 名称为 Project 的 tracer, Init 之前返回的 tracer 不记录 span
+func Tracer() oteltrace.Tracer {
+	mu.Lock()
+	n := name
+	mu.Unlock()
+	return otel.Tracer(n)
+}
+
+// Start 创建 span, 返回的 ctx 携带该 span, 需要调用 span.End 或 End 结束
+func Start(ctx context.Context, spanName string, opts ...oteltrace.SpanStartOption) (context.Context, oteltrace.Span) {
+	return Tracer().Start(ctx, spanName, opts...)
+}
+
+// End err 不为空时记录错误并将 span 状态设为 Error, 之后结束 span
+func End(span oteltrace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// TraceID ctx 中的 trace ID, 没有 span 时返回空字符串
+func TraceID(ctx context.Context) string {
+	sc := oteltrace.SpanContextFromContext(ctx)
+	if !sc.HasTraceID() {
+		return ""
+	}
+	return sc.TraceID().String()
+}